@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"os"
+	"strings"
+)
+
+// overrides - повторяемый флаг -set key=value.
+type overrides []string
+
+func (o *overrides) String() string {
+	return strings.Join(*o, ", ")
+}
+
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// loadConfig разбирает флаги и собирает конфиг. С -print-config печатает его
+// с источником каждого значения и завершает процесс.
+func loadConfig() (*config.Config, config.Options) {
+	var options config.Options
+	flag.StringVar(&options.Path, "config", "", "config file, by default CONFIG_PATH or configs/config.yaml")
+	flag.Var((*overrides)(&options.Overrides), "set", "override a config key, e.g. -set tcp_server.port=9000 (repeatable)")
+	printConfig := flag.Bool("print-config", false, "print the effective config with the source of each value and exit")
+	flag.Parse()
+
+	if options.Path == "" {
+		path, err := config.Path()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot get working directory: %v\n", err)
+			os.Exit(1)
+		}
+		options.Path = path
+	}
+	cfg, sources, err := config.LoadSources(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config %s:\n%v\n", options.Path, err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, cfg, sources); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	return cfg, options
+}
@@ -1,21 +1,196 @@
 package main
 
 import (
-	"fmt"
+	"errors"
+	"github.com/Tagakama/ServerManager/internal/admin"
 	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/game-server/control"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
 	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+	"github.com/Tagakama/ServerManager/internal/history"
+	"github.com/Tagakama/ServerManager/internal/maintenance"
+	join_token "github.com/Tagakama/ServerManager/internal/matchmaking/join-token"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"github.com/Tagakama/ServerManager/internal/store"
 	handlers "github.com/Tagakama/ServerManager/internal/tcp-server/handlers/tcp/handle-connection"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/handlers/tcp/start-manager"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/auth"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/ratelimit"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/workers"
+	"github.com/Tagakama/ServerManager/internal/tracing"
+	"github.com/Tagakama/ServerManager/internal/webhooks"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 func main() {
-	cfg := config.MustLoad()
-	serverLauncher := server_launcher.New(cfg)
+	cfg, configOptions := loadConfig()
+	if err := logger.Setup(cfg, os.Stderr); err != nil {
+		panic(err)
+	}
+	log := logger.For("main")
+
+	registry, err := versions.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	roomLogs, err := room_logs.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	joinTokens, err := join_token.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	stateStore, err := store.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer stateStore.Close()
+	// Тикеты и комнаты прошлого запуска закрываются до приёма новых запросов
+	recovery := store.Recover(stateStore)
+	go store.RunCompaction(stateStore, time.Duration(cfg.Store.RetentionHours)*time.Hour, time.Hour, nil)
+
+	serverLauncher := server_launcher.New(cfg, registry, roomLogs)
+	serverLauncher.JoinKeys = joinTokens
+	// Логи комнаты с живым сервером не удаляются; game.log ротируется раз в минуту
+	roomLogs.Active = func(uuid string) bool {
+		return serverLauncher.FindRoom(uuid) != nil
+	}
+	go roomLogs.RunCleanup(time.Minute, nil)
+	for _, orphan := range recovery.Orphans {
+		if err := serverLauncher.StopOrphan(orphan.PID, orphan.Executable); err != nil {
+			log.Info("orphaned game server is not running", logger.RoomUUID(orphan.UUID), logger.PID(orphan.PID), logger.Err(err))
+		}
+	}
 	newMatchmaker := matchmaker.New(serverLauncher)
+	newMatchmaker.RestoreRoomCounter(recovery.NextRoomID)
+	serverLauncher.Events = newMatchmaker.Bus
+	store.Subscribe(stateStore, newMatchmaker.Bus)
+	matchHistory, err := history.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer matchHistory.Close()
+	go matchHistory.RunCleanup(24*time.Hour, nil)
+	matchHistory.Subscribe(newMatchmaker.Bus)
+	notifier, err := webhooks.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer notifier.Close()
+	go notifier.Run(nil)
+	notifier.Subscribe(newMatchmaker.Bus)
+	newMatchmaker.JoinTokens = joinTokens
+	newMatchmaker.Versions = registry
+	newMatchmaker.Modes = cfg.Modes
+
+	node := maintenance.New(cfg)
+	newMatchmaker.Maintenance = node
+
+	workerPool, err := workers.New(cfg, newMatchmaker)
+	if err != nil {
+		panic(err)
+	}
+	// Drain ждёт матчи, запускающиеся серверы, запросы в очереди пула и набирающиеся комнаты
+	node.Active = func() int {
+		return len(serverLauncher.Servers()) + serverLauncher.Launching() + workerPool.Pending() + newMatchmaker.Filling()
+	}
+
+	authenticator, err := auth.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	handler := handlers.New(workerPool, authenticator)
+	handler.Limits = ratelimit.New(cfg)
+	handler.Store = stateStore
+	handler.Rooms = newMatchmaker
+	handler.ReadTimeout = time.Duration(cfg.Timeout) * time.Second
+	handler.IdleTimeout = time.Duration(cfg.IdleTimeout) * time.Second
+	handler.MaxMessageSize = cfg.MaxMessageSize
+	banList, err := bans.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	handler.Bans = banList
+	tracer, err := tracing.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer tracer.Close()
+	handler.Tracer = tracer
+	metrics.Default.GaugeFunc("sm_connections_active", "Open client connections, including those waiting for a room.", func() float64 {
+		return float64(handler.Limits.Connections())
+	})
+
+	// Без control API серверы не сообщат о готовности, поэтому адрес занимается до старта
+	controlServer := control.New(cfg, serverLauncher)
+	controlListener, err := controlServer.Listen()
+	if err != nil {
+		panic(err)
+	}
+	go func() {
+		if err := controlServer.Serve(controlListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("control API stopped", logger.Err(err))
+		}
+	}()
+	defer controlServer.Close()
+
+	metricsServer := metrics.NewServer(cfg)
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil {
+			log.Warn("metrics endpoint is disabled", logger.Err(err))
+		}
+	}()
+	defer metricsServer.Close()
 
-	workerPool := workers.NewWorkerPool(cfg.WorkerCount, newMatchmaker)
+	adminServer := admin.New(cfg)
+	adminServer.Logs = roomLogs
+	adminServer.Bans = banList
+	adminServer.Matchmaker = newMatchmaker
+	adminServer.Servers = serverLauncher
+	adminServer.Versions = registry
+	adminServer.Node = node
+	adminServer.Store = stateStore
+	adminServer.History = matchHistory
+
+	// Режимы, таймауты, лимиты, баны и логи меняются без рестарта
+	var currentHandler atomic.Pointer[handlers.Handler]
+	currentHandler.Store(handler)
+	configReloader := &reloader{
+		options:    configOptions,
+		handler:    &currentHandler,
+		limits:     handler.Limits,
+		bans:       banList,
+		matchmaker: newMatchmaker,
+		current:    cfg,
+	}
+	adminServer.Reload = configReloader.Reload
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			configReloader.Reload()
+		}
+	}()
+	go config.Watch(configOptions.Path, 2*time.Second, nil, func() { configReloader.Reload() })
+	go func() {
+		if err := adminServer.ListenAndServe(); err != nil {
+			log.Warn("admin API is disabled", logger.Err(err))
+		}
+	}()
+	defer adminServer.Close()
 
 	serverManager, err := startManager.New(cfg)
 	if err != nil {
@@ -24,13 +199,48 @@ func main() {
 
 	defer serverManager.Close()
 
-	for {
-		conn, err := serverManager.Accept()
-		if err != nil {
-			fmt.Println("Error accepting connection:", err)
-			continue
+	// Первый SIGTERM/SIGINT выводит ноду из ротации, второй - не ждёт окончания матчей
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		for sig := range signals {
+			err := node.Drain("signal", maintenance.Options{})
+			if errors.Is(err, maintenance.ErrAlreadyDraining) {
+				log.Warn("second signal, exiting without waiting for matches", "signal", sig.String())
+				node.Stop()
+			}
 		}
-		go handlers.HandleConnection(conn, workerPool)
-	}
+	}()
+
+	// Во время drain соединения по-прежнему принимаются: игроки доукомплектовывают
+	// набирающиеся комнаты, остальные получают "maintenance"
+	go func() {
+		for {
+			conn, err := serverManager.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				log.Error("failed to accept connection", logger.Err(err))
+				continue
+			}
+			go currentHandler.Load().Handle(conn)
+		}
+	}()
 
+	<-node.Done()
+	// Новые соединения больше не принимаются, а всем уже принятым запросам уходит ответ до выхода
+	serverManager.Close()
+	reason := node.Err()
+	if cancelled := newMatchmaker.CancelFilling(reason.Error()); cancelled > 0 {
+		log.Warn("cancelled filling rooms", "rooms", cancelled)
+	}
+	if err := workerPool.Shutdown(reason); err != nil {
+		log.Warn("failed to close worker pool", logger.Err(err))
+	}
+	if node.Expired() {
+		serverLauncher.StopAll()
+	}
+	newMatchmaker.Bus.Wait()
+	log.Info("server manager stopped")
 }
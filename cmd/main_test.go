@@ -6,11 +6,14 @@ import (
 	handlers "github.com/Tagakama/ServerManager/internal/tcp-server/handlers/tcp/handle-connection"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/Tagakama/ServerManager/internal/config"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
 	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/workers"
 	"github.com/stretchr/testify/require"
@@ -24,7 +27,8 @@ const (
 func TestFullCycle(t *testing.T) {
 	// 1. Подготовка тестовой конфигурации
 	cfg := &config.Config{
-		VersionPath:    "./test_versions/",
+		VersionPath:    t.TempDir(),
+		Logs:           config.Logs{Path: t.TempDir()},
 		ExecutableName: "test_game_server",
 		TCPServer: config.TCPServer{
 			Address:     testAddress,
@@ -33,10 +37,21 @@ func TestFullCycle(t *testing.T) {
 		},
 	}
 
+	// Сборка-заглушка: сообщает о запуске в stdout и живёт пару секунд
+	buildDir := filepath.Join(cfg.VersionPath, "v1.0")
+	require.NoError(t, os.MkdirAll(buildDir, 0o755))
+	script := "#!/bin/sh\necho \"Server started on port\"\nsleep 2\n"
+	require.NoError(t, os.WriteFile(filepath.Join(buildDir, cfg.ExecutableName), []byte(script), 0o755))
+
 	// 2. Инициализация компонентов
-	sl := server_launcher.New(cfg)
+	registry, err := versions.New(cfg)
+	require.NoError(t, err)
+	roomLogs, err := room_logs.New(cfg)
+	require.NoError(t, err)
+	sl := server_launcher.New(cfg, registry, roomLogs)
 	mm := matchmaker.New(sl)
-	wp := workers.NewWorkerPool(cfg.WorkerCount, mm)
+	wp, err := workers.NewWorkerPool(cfg.WorkerCount, mm)
+	require.NoError(t, err)
 
 	// 3. Запуск сервера с таймаутом
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -100,7 +115,7 @@ func TestFullCycle(t *testing.T) {
 	// 5. Проверяем, что сервер корректно обработал соединение
 	require.Eventually(t, func() bool {
 		return len(mm.CurrentRooms) > 0
-	}, time.Second, 100*time.Millisecond, "No rooms created after client connection")
+	}, 3*time.Second, 100*time.Millisecond, "No rooms created after client connection")
 
 	// 6. Завершаем тест
 	cancel()
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
+	handlers "github.com/Tagakama/ServerManager/internal/tcp-server/handlers/tcp/handle-connection"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/ratelimit"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reloader перечитывает конфиг по SIGHUP, изменению файла или POST /config/reload
+// с теми же флагами -set, что и при старте. Фрагменты из include перечитываются вместе
+// с файлом, но за их изменением следит только SIGHUP и smctl reload. Невалидный конфиг не применяется совсем,
+// работающий сервис остаётся на прежних настройках.
+type reloader struct {
+	options    config.Options
+	handler    *atomic.Pointer[handlers.Handler]
+	limits     *ratelimit.Limiter
+	bans       *bans.List
+	matchmaker *matchmaker.Matchmaker
+
+	mu      sync.Mutex
+	current *config.Config
+}
+
+func (r *reloader) Reload() error {
+	log := logger.For("main")
+	next, _, err := config.LoadSources(r.options)
+	if err != nil {
+		log.Error("config is not reloaded", "path", r.options.Path, logger.Err(err))
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	applied, restart := config.Reload(r.current, next)
+	// Сначала всё, что может не получиться; применяется только когда готовы все части
+	applyBans, err := r.bans.PrepareReload(applied)
+	if err != nil {
+		log.Error("config is not reloaded", "path", r.options.Path, logger.Err(err))
+		return err
+	}
+	applyLogging, err := logger.Prepare(applied, os.Stderr)
+	if err != nil {
+		log.Error("config is not reloaded", "path", r.options.Path, logger.Err(err))
+		return err
+	}
+
+	applyBans()
+	applyLogging()
+	r.limits.Update(applied)
+	r.matchmaker.SetModes(applied.Modes)
+
+	// Соединения читают таймауты при приёме: уже открытые доживают со старыми
+	handler := *r.handler.Load()
+	handler.ReadTimeout = time.Duration(applied.Timeout) * time.Second
+	handler.IdleTimeout = time.Duration(applied.IdleTimeout) * time.Second
+	handler.MaxMessageSize = applied.MaxMessageSize
+	r.handler.Store(&handler)
+
+	r.current = applied
+	if len(restart) > 0 {
+		log.Warn("changed settings take effect after restart", "settings", restart)
+	}
+	log.Info("config reloaded", "path", r.options.Path)
+	return nil
+}
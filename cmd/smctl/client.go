@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client - тонкая обёртка над admin API.
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// apiError - ответ admin API с кодом не 2xx.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("admin API returned %d: %s", e.Status, e.Message)
+}
+
+func newClient(addr, token string, timeout time.Duration) *client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &client{
+		base:  strings.TrimRight(addr, "/"),
+		token: token,
+		http:  &http.Client{Timeout: timeout},
+	}
+}
+
+// get декодирует JSON-ответ в out.
+func (c *client) get(path string, out any) error {
+	return c.call(http.MethodGet, path, nil, out)
+}
+
+func (c *client) post(path string, body, out any) error {
+	return c.call(http.MethodPost, path, body, out)
+}
+
+func (c *client) delete(path string) error {
+	return c.call(http.MethodDelete, path, nil, nil)
+}
+
+func (c *client) call(method, path string, body, out any) error {
+	response, err := c.do(c.http, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// stream копирует тело ответа как есть, например лог комнаты с ?follow=1.
+// Таймаут клиента на него не действует: follow длится, пока его не прервут.
+func (c *client) stream(path string, w io.Writer) error {
+	streaming := *c.http
+	streaming.Timeout = 0
+	response, err := c.do(&streaming, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+func (c *client) do(httpClient *http.Client, method, path string, body any) (*http.Response, error) {
+	var payload io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(content)
+	}
+	request, err := http.NewRequest(method, c.base+path, payload)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		defer response.Body.Close()
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		content, _ := io.ReadAll(io.LimitReader(response.Body, 1<<16))
+		if json.Unmarshal(content, &errBody) != nil || errBody.Error == "" {
+			errBody.Error = strings.TrimSpace(string(content))
+		}
+		return nil, &apiError{Status: response.StatusCode, Message: errBody.Error}
+	}
+	return response, nil
+}
+
+func escape(segment string) string {
+	return url.PathEscape(segment)
+}
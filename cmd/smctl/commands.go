@@ -0,0 +1,548 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tagakama/ServerManager/internal/admin"
+	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/history"
+	"github.com/Tagakama/ServerManager/internal/maintenance"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/store"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+)
+
+func (c *cli) pools() error {
+	var pools []matchmaker.PoolInfo
+	if err := c.api.get("/pools", &pools); err != nil {
+		return err
+	}
+	return c.out.print(pools, func() []table {
+		t := table{header: []string{"MODE", "MAP", "VERSION", "ROOMS", "FILLING", "WAITING", "MAX PLAYERS", "TEAMS"}}
+		for _, pool := range pools {
+			t.add(pool.Mode, pool.Map, orDash(pool.Version), itoa(pool.Rooms), itoa(pool.FillingRooms),
+				itoa(pool.WaitingPlayers), itoa(pool.MaxPlayers), itoa(pool.Teams))
+		}
+		return []table{t}
+	})
+}
+
+func (c *cli) rooms(sub string, args []string, stderr io.Writer) error {
+	switch sub {
+	case "list":
+		var rooms []room.Info
+		if err := c.api.get("/rooms", &rooms); err != nil {
+			return err
+		}
+		return c.out.print(rooms, func() []table {
+			t := table{header: []string{"UUID", "ID", "MODE", "MAP", "VERSION", "STATE", "PLAYERS", "AGE"}}
+			for _, info := range rooms {
+				t.add(info.UUID, itoa(info.ID), info.Mode, info.Map, orDash(info.Version), orDash(info.State),
+					fmt.Sprintf("%d/%d", info.Reserved, info.MaxPlayers), formatSeconds(info.AgeSeconds))
+			}
+			return []table{t}
+		})
+	case "describe":
+		uuid, err := argument(args)
+		if err != nil {
+			return err
+		}
+		var info room.Info
+		if err := c.api.get("/rooms/"+escape(uuid), &info); err != nil {
+			return err
+		}
+		return c.out.print(info, func() []table {
+			players := table{header: []string{"CLIENT", "TEAM", "SIZE", "CONNECTED"}}
+			for _, player := range info.Players {
+				players.add(player.ClientID, itoa(player.Team), itoa(player.Size), formatBool(player.Connected))
+			}
+			return []table{fields(
+				"UUID", info.UUID,
+				"ID", itoa(info.ID),
+				"Mode", info.Mode,
+				"Map", info.Map,
+				"Version", orDash(info.Version),
+				"State", orDash(info.State),
+				"Filling", formatBool(info.Filling),
+				"Players", fmt.Sprintf("%d/%d", info.Reserved, info.MaxPlayers),
+				"Created", formatTime(info.CreatedAt),
+				"Age", formatSeconds(info.AgeSeconds),
+			), players}
+		})
+	case "start":
+		uuid, err := argument(args)
+		if err != nil {
+			return err
+		}
+		return c.done(c.api.post("/rooms/"+escape(uuid)+"/start", nil, nil), "room %s started", uuid)
+	case "cancel":
+		flags := flag.NewFlagSet("rooms cancel", flag.ContinueOnError)
+		reason := flags.String("reason", "", "message sent to waiting players")
+		positional, err := parseFlags(flags, args, stderr)
+		if err != nil {
+			return err
+		}
+		uuid, err := argument(positional)
+		if err != nil {
+			return err
+		}
+		body := map[string]string{"reason": *reason}
+		return c.done(c.api.post("/rooms/"+escape(uuid)+"/cancel", body, nil), "room %s cancelled", uuid)
+	case "kick":
+		if len(args) != 2 {
+			return errUsage
+		}
+		err := c.api.delete("/rooms/" + escape(args[0]) + "/players/" + escape(args[1]))
+		return c.done(err, "player %s removed from room %s", args[1], args[0])
+	}
+	return errUsage
+}
+
+func (c *cli) servers(sub string, args []string) error {
+	switch sub {
+	case "list":
+		var servers []server_launcher.ServerInfo
+		if err := c.api.get("/servers", &servers); err != nil {
+			return err
+		}
+		return c.out.print(servers, func() []table {
+			t := table{header: []string{"ROOM UUID", "ROOM ID", "PID", "PORT", "VERSION", "STATE", "DRAINING", "UPTIME"}}
+			for _, server := range servers {
+				t.add(server.RoomUUID, itoa(server.RoomID), itoa(server.PID), itoa(server.Port), orDash(server.Version),
+					orDash(server.State), formatBool(server.Draining), formatSeconds(server.UptimeSeconds))
+			}
+			return []table{t}
+		})
+	case "describe":
+		uuid, err := argument(args)
+		if err != nil {
+			return err
+		}
+		var servers []server_launcher.ServerInfo
+		if err := c.api.get("/servers", &servers); err != nil {
+			return err
+		}
+		for _, server := range servers {
+			if server.RoomUUID != uuid {
+				continue
+			}
+			return c.out.print(server, func() []table {
+				return []table{fields(
+					"Room UUID", server.RoomUUID,
+					"Room ID", itoa(server.RoomID),
+					"PID", itoa(server.PID),
+					"Port", itoa(server.Port),
+					"Version", orDash(server.Version),
+					"State", orDash(server.State),
+					"Draining", formatBool(server.Draining),
+					"Started", formatTime(server.StartedAt),
+					"Uptime", formatSeconds(server.UptimeSeconds),
+				)}
+			})
+		}
+		return fmt.Errorf("game server %s is not running", uuid)
+	case "drain", "kill":
+		uuid, err := argument(args)
+		if err != nil {
+			return err
+		}
+		return c.done(c.api.post("/servers/"+escape(uuid)+"/"+sub, nil, nil), "game server %s: %s requested", uuid, sub)
+	}
+	return errUsage
+}
+
+func (c *cli) versions(sub string, args []string, stderr io.Writer) error {
+	switch sub {
+	case "list":
+		var list []admin.VersionInfo
+		if err := c.api.get("/versions", &list); err != nil {
+			return err
+		}
+		return c.out.print(list, func() []table {
+			t := table{header: []string{"VERSION", "STATUS", "DEFAULT", "MAPS"}}
+			for _, version := range list {
+				t.add(version.Version, version.Status, formatBool(version.Default), mapsList(version.Maps))
+			}
+			return []table{t}
+		})
+	case "describe":
+		version, err := argument(args)
+		if err != nil {
+			return err
+		}
+		var info admin.VersionInfo
+		if err := c.api.get("/versions/"+escape(version), &info); err != nil {
+			return err
+		}
+		return c.printVersion(info)
+	case "install":
+		flags := flag.NewFlagSet("versions install", flag.ContinueOnError)
+		version := flags.String("version", "", "version name")
+		source := flags.String("source", "", "archive path on the node or http(s) URL")
+		sum := flags.String("sha256", "", "archive checksum")
+		makeDefault := flags.Bool("default", false, "make the build the default version")
+		positional, err := parseFlags(flags, args, stderr)
+		if err != nil {
+			return err
+		}
+		if len(positional) > 0 || *version == "" || *source == "" || *sum == "" {
+			return errUsage
+		}
+		var info admin.VersionInfo
+		body := map[string]any{"version": *version, "source": *source, "sha256": *sum, "default": *makeDefault}
+		if err := c.api.post("/versions", body, &info); err != nil {
+			return err
+		}
+		return c.printVersion(info)
+	case "retire", "default":
+		version, err := argument(args)
+		if err != nil {
+			return err
+		}
+		var info admin.VersionInfo
+		if err := c.api.post("/versions/"+escape(version)+"/"+sub, nil, &info); err != nil {
+			return err
+		}
+		return c.printVersion(info)
+	case "status":
+		if len(args) != 2 {
+			return errUsage
+		}
+		var info admin.VersionInfo
+		if err := c.api.post("/versions/"+escape(args[0])+"/status", map[string]string{"status": args[1]}, &info); err != nil {
+			return err
+		}
+		return c.printVersion(info)
+	}
+	return errUsage
+}
+
+func (c *cli) printVersion(info admin.VersionInfo) error {
+	return c.out.print(info, func() []table {
+		return []table{fields(
+			"Version", info.Version,
+			"Status", info.Status,
+			"Default", formatBool(info.Default),
+			"Maps", mapsList(info.Maps),
+			"Executable", info.Executable,
+			"Dir", info.Dir,
+		)}
+	})
+}
+
+func mapsList(maps []string) string {
+	if len(maps) == 0 {
+		return "any"
+	}
+	return strings.Join(maps, ",")
+}
+
+func (c *cli) bans(sub string, args []string, stderr io.Writer) error {
+	switch sub {
+	case "list":
+		var list []bans.Ban
+		if err := c.api.get("/bans", &list); err != nil {
+			return err
+		}
+		return c.out.print(list, func() []table {
+			t := table{header: []string{"ID", "KIND", "VALUE", "REASON", "CREATED", "EXPIRES"}}
+			for _, ban := range list {
+				t.add(ban.ID, ban.Kind, ban.Value, ban.Reason, formatTime(ban.CreatedAt), formatTime(ban.ExpiresAt))
+			}
+			return []table{t}
+		})
+	case "add":
+		flags := flag.NewFlagSet("bans add", flag.ContinueOnError)
+		kind := flags.String("kind", bans.KindClient, "client, ip or cidr")
+		value := flags.String("value", "", "client id, address or network")
+		reason := flags.String("reason", "", "why the ban was added")
+		duration := flags.Duration("duration", 0, "ban duration, permanent if not set")
+		positional, err := parseFlags(flags, args, stderr)
+		if err != nil {
+			return err
+		}
+		if len(positional) > 0 || *value == "" || *reason == "" {
+			return errUsage
+		}
+		body := map[string]any{"kind": *kind, "value": *value, "reason": *reason}
+		if *duration > 0 {
+			body["duration"] = int((*duration + time.Second - 1) / time.Second)
+		}
+		var ban bans.Ban
+		if err := c.api.post("/bans", body, &ban); err != nil {
+			return err
+		}
+		return c.out.print(ban, func() []table {
+			return []table{fields(
+				"ID", ban.ID,
+				"Kind", ban.Kind,
+				"Value", ban.Value,
+				"Reason", ban.Reason,
+				"Expires", formatTime(ban.ExpiresAt),
+			)}
+		})
+	case "remove":
+		id, err := argument(args)
+		if err != nil {
+			return err
+		}
+		return c.done(c.api.delete("/bans/"+escape(id)), "ban %s removed", id)
+	}
+	return errUsage
+}
+
+// logs печатает лог как есть: -o на него не влияет.
+func (c *cli) logs(args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	file := flags.String("file", "game", "game or output")
+	tail := flags.Int("tail", -1, "print only the last N lines")
+	follow := flags.Bool("f", false, "keep printing new lines")
+	positional, err := parseFlags(flags, args, stderr)
+	if err != nil {
+		return err
+	}
+	uuid, err := argument(positional)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"file": {*file}}
+	if *tail >= 0 {
+		query.Set("tail", strconv.Itoa(*tail))
+	}
+	if *follow {
+		query.Set("follow", "1")
+	}
+	return c.api.stream("/rooms/"+escape(uuid)+"/logs?"+query.Encode(), c.out.out)
+}
+
+// matches: -since - насколько назад смотреть от текущего момента, по началу матча.
+func (c *cli) matches(sub string, args []string, stderr io.Writer) error {
+	if sub == "describe" {
+		uuid, err := argument(args)
+		if err != nil {
+			return err
+		}
+		var match history.Match
+		if err := c.api.get("/matches/"+escape(uuid), &match); err != nil {
+			return err
+		}
+		return c.out.print(match, func() []table {
+			players := table{header: []string{"CLIENT", "TEAM", "SIZE"}}
+			for _, player := range match.Players {
+				players.add(player.ClientID, itoa(player.Team), itoa(player.Size))
+			}
+			results := "-"
+			if len(match.Results) > 0 {
+				results = string(match.Results)
+			}
+			return []table{fields(
+				"Room UUID", match.RoomUUID,
+				"Room ID", itoa(match.RoomID),
+				"Mode", match.Mode,
+				"Map", match.Map,
+				"Version", orDash(match.Version),
+				"Started", formatTime(match.StartedAt),
+				"Ended", formatTime(match.EndedAt),
+				"Duration", formatSeconds(match.DurationSeconds),
+				"Outcome", orDash(match.Outcome),
+				"Exit error", orDash(match.ExitError),
+				"Connected", orDash(strings.Join(match.Connected, " ")),
+				"Results", results,
+			), players}
+		})
+	}
+	if sub != "list" && sub != "export" {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("matches "+sub, flag.ContinueOnError)
+	client := flags.String("client", "", "client id")
+	roomID := flags.String("room", "", "room ID or UUID")
+	since := flags.Duration("since", 0, "only matches started within this time")
+	limit := flags.Int("limit", 0, "print only the last N matches")
+	format := flags.String("format", history.FormatJSONL, "export format: jsonl or csv")
+	positional, err := parseFlags(flags, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+	query := url.Values{}
+	if *client != "" {
+		query.Set("client", *client)
+	}
+	if *roomID != "" {
+		query.Set("room", *roomID)
+	}
+	if *since > 0 {
+		query.Set("since", time.Now().Add(-*since).UTC().Format(time.RFC3339))
+	}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+
+	// Выгрузка печатается как есть: -o на неё не влияет
+	if sub == "export" {
+		query.Set("format", *format)
+		return c.api.stream("/matches/export?"+query.Encode(), c.out.out)
+	}
+	var matches []history.Match
+	if err := c.api.get("/matches?"+query.Encode(), &matches); err != nil {
+		return err
+	}
+	return c.out.print(matches, func() []table {
+		t := table{header: []string{"ROOM UUID", "MODE", "MAP", "VERSION", "PLAYERS", "STARTED", "DURATION", "OUTCOME"}}
+		for _, match := range matches {
+			duration, outcome := "-", "in progress"
+			if match.Finished() {
+				duration, outcome = formatSeconds(match.DurationSeconds), match.Outcome
+			}
+			t.add(match.RoomUUID, match.Mode, match.Map, orDash(match.Version), itoa(len(match.Players)),
+				formatTime(match.StartedAt), duration, outcome)
+		}
+		return []table{t}
+	})
+}
+
+// audit: -since - насколько назад смотреть от текущего момента.
+func (c *cli) audit(args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	ticket := flags.String("ticket", "", "ticket id")
+	client := flags.String("client", "", "client id")
+	room := flags.String("room", "", "room UUID")
+	since := flags.Duration("since", 0, "only events newer than this")
+	limit := flags.Int("limit", 0, "print only the last N events")
+	positional, err := parseFlags(flags, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+
+	query := url.Values{}
+	for name, value := range map[string]string{"ticket": *ticket, "client": *client, "room": *room} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if *since > 0 {
+		query.Set("since", time.Now().Add(-*since).UTC().Format(time.RFC3339))
+	}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+	var events []store.Event
+	if err := c.api.get("/audit?"+query.Encode(), &events); err != nil {
+		return err
+	}
+	return c.out.print(events, func() []table {
+		t := table{header: []string{"TIME", "KIND", "TICKET", "CLIENT", "ROOM", "DETAILS"}}
+		for _, event := range events {
+			t.add(formatTime(event.Time), event.Kind, orDash(event.TicketID), orDash(event.ClientID), orDash(event.RoomUUID), eventDetails(event))
+		}
+		return []table{t}
+	})
+}
+
+func eventDetails(event store.Event) string {
+	var details []string
+	add := func(name, value string) {
+		if value != "" && value != "0" {
+			details = append(details, name+"="+value)
+		}
+	}
+	add("state", event.State)
+	add("team", itoa(event.Team))
+	add("pid", itoa(event.PID))
+	add("port", itoa(event.Port))
+	add("version", event.Version)
+	add("code", event.Code)
+	add("message", event.Message)
+	if len(details) == 0 {
+		return "-"
+	}
+	return strings.Join(details, " ")
+}
+
+// drain: нулевые -deadline и -redirect берутся из секции maintenance конфига ноды.
+func (c *cli) drain(args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("drain", flag.ContinueOnError)
+	deadline := flags.Duration("deadline", 0, "how long to wait for running matches")
+	redirect := flags.String("redirect", "", "host:port of the node that takes new requests")
+	retryAt := flags.String("retry-at", "", "RFC 3339 time clients are told to retry at")
+	positional, err := parseFlags(flags, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+	body := map[string]any{"redirect": *redirect}
+	if *deadline > 0 {
+		body["deadline"] = int((*deadline + time.Second - 1) / time.Second)
+	}
+	if *retryAt != "" {
+		at, err := time.Parse(time.RFC3339, *retryAt)
+		if err != nil {
+			return fmt.Errorf("invalid -retry-at: %w", err)
+		}
+		body["retry_at"] = at
+	}
+	var status maintenance.Status
+	if err := c.api.post("/node/drain", body, &status); err != nil {
+		return err
+	}
+	return c.printNode(status)
+}
+
+func (c *cli) node() error {
+	var status maintenance.Status
+	if err := c.api.get("/node", &status); err != nil {
+		return err
+	}
+	return c.printNode(status)
+}
+
+func (c *cli) printNode(status maintenance.Status) error {
+	return c.out.print(status, func() []table {
+		if !status.Draining {
+			return []table{fields("Draining", "no", "Active", itoa(status.Active))}
+		}
+		return []table{fields(
+			"Draining", "yes",
+			"Reason", orDash(status.Reason),
+			"Started", formatTime(status.StartedAt),
+			"Deadline", formatTime(status.Deadline),
+			"Retry at", formatTime(status.RetryAt),
+			"Redirect", orDash(status.Redirect),
+			"Active", itoa(status.Active),
+		)}
+	})
+}
+
+func (c *cli) reload() error {
+	return c.done(c.api.post("/config/reload", nil, nil), "configuration reloaded")
+}
+
+// done печатает подтверждение команды без тела ответа.
+func (c *cli) done(err error, format string, args ...any) error {
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf(format, args...)
+	return c.out.print(map[string]string{"result": message}, func() []table {
+		t := table{}
+		t.add(message)
+		return []table{t}
+	})
+}
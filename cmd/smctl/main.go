@@ -0,0 +1,189 @@
+// smctl - консольный клиент admin API менеджера серверов для дежурных.
+//
+//	smctl [-addr host:port] [-token T] [-o table|json] <command> [args]
+//
+// Адрес и токен можно задать через SMCTL_ADDR и ADMIN_TOKEN.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"io"
+	"os"
+	"time"
+)
+
+const usage = `usage: smctl [-addr host:port] [-token T] [-o table|json] [-timeout D] <command> [args]
+
+commands:
+  pools                                  list room pools
+  rooms [list]                           list rooms
+  rooms describe UUID                    show a room and its players
+  rooms start UUID                       start a filling room with the players it has
+  rooms cancel UUID [-reason TEXT]       cancel a filling room
+  rooms kick UUID CLIENT                 remove a player from a filling room
+  servers [list]                         list game server processes
+  servers describe UUID                  show a game server
+  servers drain UUID                     stop a game server after its match ends
+  servers kill UUID                      kill a game server now
+  versions [list]                        list installed builds
+  versions describe VERSION              show a build
+  versions install -version V -source PATH|URL -sha256 SUM [-default]
+  versions retire VERSION                stop creating rooms on a build
+  versions status VERSION STATUS         set active|deprecated|draining|retired
+  versions default VERSION               make a build the default
+  bans [list]                            list bans
+  bans add -kind client|ip|cidr -value V -reason TEXT [-duration D]
+  bans remove ID                         lift a ban
+  logs UUID [-file game|output] [-tail N] [-f]
+                                         print a room's game log
+  matches [list] [-client ID] [-room ID|UUID] [-since D] [-limit N]
+                                         list played matches
+  matches describe UUID                  show a match and its results
+  matches export [-format jsonl|csv] [-client ID] [-room ID|UUID] [-since D]
+                                         print matches for analytics
+  audit [-ticket ID] [-client ID] [-room UUID] [-since D] [-limit N]
+                                         show the journal of tickets, rooms and servers
+  node                                   show maintenance status
+  drain [-deadline D] [-redirect host:port] [-retry-at RFC3339]
+                                         stop creating rooms and exit after running matches
+  reload                                 reload the configuration file
+  config check FILE                      validate a configuration file, works offline
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("smctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	addr := flags.String("addr", envOr("SMCTL_ADDR", "127.0.0.1:8090"), "admin API address")
+	token := flags.String("token", os.Getenv("ADMIN_TOKEN"), "admin API token")
+	format := flags.String("o", formatTable, "output format: table or json")
+	timeout := flags.Duration("timeout", 30*time.Second, "request timeout, logs -f is not limited")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != formatTable && *format != formatJSON {
+		fmt.Fprintf(stderr, "smctl: unknown output format %q\n", *format)
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	// Проверка конфига не ходит в admin API: файл проверяется до выкладки
+	if flags.Arg(0) == "config" {
+		return checkConfig(flags.Args()[1:], stdout, stderr)
+	}
+	if *token == "" {
+		fmt.Fprintln(stderr, "smctl: admin token is required, use -token or ADMIN_TOKEN")
+		return 2
+	}
+
+	cli := &cli{
+		api: newClient(*addr, *token, *timeout),
+		out: printer{format: *format, out: stdout},
+	}
+	err := cli.dispatch(flags.Arg(0), flags.Args()[1:], stderr)
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "smctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type cli struct {
+	api *client
+	out printer
+}
+
+func (c *cli) dispatch(command string, args []string, stderr io.Writer) error {
+	sub, rest := "list", args
+	if len(args) > 0 {
+		sub, rest = args[0], args[1:]
+	}
+
+	switch command {
+	case "pools":
+		return c.pools()
+	case "rooms":
+		return c.rooms(sub, rest, stderr)
+	case "servers":
+		return c.servers(sub, rest)
+	case "versions":
+		return c.versions(sub, rest, stderr)
+	case "bans":
+		return c.bans(sub, rest, stderr)
+	case "logs":
+		return c.logs(args, stderr)
+	case "matches":
+		return c.matches(sub, rest, stderr)
+	case "audit":
+		return c.audit(args, stderr)
+	case "node":
+		return c.node()
+	case "drain":
+		return c.drain(args, stderr)
+	case "reload":
+		return c.reload()
+	}
+	return errUsage
+}
+
+// argument возвращает единственный позиционный аргумент команды.
+func argument(args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", errUsage
+	}
+	return args[0], nil
+}
+
+// parseFlags разбирает флаги подкоманды; позиционные аргументы могут идти до флагов.
+func parseFlags(flags *flag.FlagSet, args []string, stderr io.Writer) ([]string, error) {
+	flags.SetOutput(stderr)
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// checkConfig проверяет файл так же, как менеджер при старте и перечитывании,
+// включая значения по умолчанию и переменные окружения.
+func checkConfig(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 2 || args[0] != "check" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if _, err := config.Load(args[1]); err != nil {
+		fmt.Fprintf(stderr, "smctl: %s is invalid:\n%v\n", args[1], err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s is valid\n", args[1])
+	return 0
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/admin"
+	"github.com/Tagakama/ServerManager/internal/config"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+	"github.com/Tagakama/ServerManager/internal/maintenance"
+	"github.com/Tagakama/ServerManager/internal/store"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+)
+
+const (
+	testToken = "test-token"
+	roomUUID  = "0f8fad5b-d9cb-469f-a165-70867728950e"
+)
+
+func newAdminServer(t *testing.T) (*admin.Server, string) {
+	cfg := &config.Config{
+		Admin: config.Admin{Token: testToken},
+		Logs:  config.Logs{Path: t.TempDir()},
+	}
+	logs, err := room_logs.New(cfg)
+	require.NoError(t, err)
+	banList, err := bans.New(&config.Config{Bans: config.Bans{Path: filepath.Join(t.TempDir(), "bans.json")}})
+	require.NoError(t, err)
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "v1"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "v1", "Server.x86_64"), []byte("#!/bin/sh\n"), 0o755))
+	registry, err := versions.New(&config.Config{VersionPath: root, ExecutableName: "Server.x86_64"})
+	require.NoError(t, err)
+
+	srv := admin.New(cfg)
+	srv.Logs = logs
+	srv.Bans = banList
+	srv.Versions = registry
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+	return srv, httpServer.URL
+}
+
+func smctl(t *testing.T, url string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-addr", url, "-token", testToken}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestSmctl_Bans(t *testing.T) {
+	_, url := newAdminServer(t)
+
+	code, out, errOut := smctl(t, url, "bans", "add", "-value", "cheater", "-reason", "aimbot", "-duration", "1h")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "cheater")
+
+	code, out, _ = smctl(t, url, "-o", "json", "bans")
+	require.Equal(t, 0, code)
+	var list []bans.Ban
+	require.NoError(t, json.Unmarshal([]byte(out), &list))
+	require.Len(t, list, 1)
+	assert.False(t, list[0].ExpiresAt.IsZero())
+
+	code, out, _ = smctl(t, url, "bans", "list")
+	require.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "ID"))
+	assert.Contains(t, lines[1], "aimbot")
+
+	code, _, _ = smctl(t, url, "bans", "remove", list[0].ID)
+	assert.Equal(t, 0, code)
+	code, _, errOut = smctl(t, url, "bans", "remove", list[0].ID)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "404")
+}
+
+func TestSmctl_Versions(t *testing.T) {
+	_, url := newAdminServer(t)
+
+	code, out, errOut := smctl(t, url, "versions", "default", "v1")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "Default:")
+
+	code, out, _ = smctl(t, url, "-o", "json", "versions", "retire", "v1")
+	require.Equal(t, 0, code)
+	var info admin.VersionInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &info))
+	assert.Equal(t, versions.StatusRetired, info.Status)
+
+	code, _, _ = smctl(t, url, "versions", "install", "-version", "v2")
+	assert.Equal(t, 2, code, "source and checksum are required")
+}
+
+func TestSmctl_LogsAndNode(t *testing.T) {
+	srv, url := newAdminServer(t)
+
+	path, err := srv.Logs.Path(roomUUID, room_logs.GameLog)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644))
+
+	code, out, errOut := smctl(t, url, "logs", roomUUID, "-tail", "2")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, "two\nthree\n", out)
+
+	code, _, errOut = smctl(t, url, "drain")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "not supported")
+
+	node := maintenance.New(&config.Config{Maintenance: config.Maintenance{Deadline: 60}})
+	node.Active = func() int { return 1 }
+	srv.Node = node
+	code, out, errOut = smctl(t, url, "drain", "-redirect", "10.0.0.2:8080", "-deadline", "10m")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "10.0.0.2:8080")
+	defer node.Stop()
+
+	code, out, _ = smctl(t, url, "-o", "json", "node")
+	require.Equal(t, 0, code)
+	var status maintenance.Status
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	assert.True(t, status.Draining)
+	assert.Equal(t, "admin", status.Reason)
+	assert.InDelta(t, 10*time.Minute, status.Deadline.Sub(status.StartedAt), float64(time.Second))
+
+	code, _, errOut = smctl(t, url, "drain")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "409")
+
+	srv.Reload = func() error { return nil }
+	code, out, _ = smctl(t, url, "reload")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "reloaded")
+}
+
+func TestSmctl_Audit(t *testing.T) {
+	srv, url := newAdminServer(t)
+	journal := store.NewMemory()
+	srv.Store = journal
+	require.NoError(t, journal.Append(store.Event{Kind: store.TicketQueued, TicketID: "t1", ClientID: "alice"}))
+	require.NoError(t, journal.Append(store.Event{Kind: store.TicketRejected, TicketID: "t1", ClientID: "alice", Code: "banned"}))
+	require.NoError(t, journal.Append(store.Event{Kind: store.TicketQueued, TicketID: "t2", ClientID: "bob"}))
+
+	code, out, errOut := smctl(t, url, "audit", "-client", "alice", "-since", "1h")
+	require.Equal(t, 0, code, errOut)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[2], "code=banned")
+
+	code, out, _ = smctl(t, url, "-o", "json", "audit", "-limit", "1")
+	require.Equal(t, 0, code)
+	var events []store.Event
+	require.NoError(t, json.Unmarshal([]byte(out), &events))
+	require.Len(t, events, 1)
+	assert.Equal(t, "bob", events[0].ClientID)
+}
+
+func TestSmctl_Usage(t *testing.T) {
+	_, url := newAdminServer(t)
+
+	code, _, errOut := smctl(t, url, "rooms", "describe")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "usage:")
+
+	code, _, _ = smctl(t, url, "unknown")
+	assert.Equal(t, 2, code)
+
+	var stderr bytes.Buffer
+	t.Setenv("ADMIN_TOKEN", "")
+	assert.Equal(t, 2, run([]string{"-addr", url, "rooms"}, &bytes.Buffer{}, &stderr))
+	assert.Contains(t, stderr.String(), "token is required")
+
+	code, _, errOut = smctl(t, url, "rooms")
+	assert.Equal(t, 1, code, "matchmaker is not attached to the test server")
+	assert.Contains(t, errOut, "503")
+}
+
+func TestSmctl_ConfigCheck(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	t.Setenv("ADMIN_TOKEN", "")
+	code := run([]string{"config", "check", "../../configs/config.yaml"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "is valid")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("tcp_server:\n  addres: \"0.0.0.0\"\n  worker_count: 0\n"), 0o644))
+	stderr.Reset()
+	code = run([]string{"config", "check", path}, &bytes.Buffer{}, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "field addres not found")
+	assert.Contains(t, stderr.String(), "tcp_server.worker_count: must be at least 1")
+}
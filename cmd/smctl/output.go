@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// table - вывод для людей. Для -o json печатается исходный ответ API.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+func (t *table) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(t.header) > 0 {
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+	}
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+type printer struct {
+	format string
+	out    io.Writer
+}
+
+// print выводит value как JSON или строит из него одну или несколько таблиц.
+func (p printer) print(value any, tables func() []table) error {
+	if p.format == formatJSON {
+		encoder := json.NewEncoder(p.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	for i, t := range tables() {
+		if i > 0 {
+			fmt.Fprintln(p.out)
+		}
+		if err := t.write(p.out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fields - таблица "поле: значение" для describe.
+func fields(pairs ...string) table {
+	t := table{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		t.add(pairs[i]+":", pairs[i+1])
+	}
+	return t
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func itoa(value int) string {
+	return strconv.Itoa(value)
+}
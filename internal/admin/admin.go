@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/Tagakama/ServerManager/internal/config"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
+	"github.com/Tagakama/ServerManager/internal/history"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
+	"github.com/Tagakama/ServerManager/internal/store"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var log = logger.For("admin")
+
+// Server - HTTP API для операторов. Все запросы требуют заголовок
+// "Authorization: Bearer <admin.token>".
+type Server struct {
+	Logs       *room_logs.Manager
+	Bans       *bans.List
+	Matchmaker *matchmaker.Matchmaker
+	Servers    GameServers
+	Versions   Versions
+	Node       Node
+	Store      store.Store
+	History    *history.History
+	// Reload подключает main. Пока его нет, запрос получает 501.
+	Reload func() error
+
+	token      string
+	mux        *http.ServeMux
+	httpServer *http.Server
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func New(cfg *config.Config) *Server {
+	s := &Server{
+		token: cfg.Admin.Token,
+		mux:   http.NewServeMux(),
+	}
+	s.httpServer = &http.Server{
+		Addr:              cfg.Admin.Address,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.mux.HandleFunc("GET /rooms/{uuid}/logs", s.roomLog)
+	s.mux.HandleFunc("GET /rooms/{uuid}/logs/archive", s.roomLogArchive)
+	s.mux.HandleFunc("GET /bans", s.listBans)
+	s.mux.HandleFunc("POST /bans", s.addBan)
+	s.mux.HandleFunc("DELETE /bans/{id}", s.removeBan)
+	s.mux.HandleFunc("GET /pools", s.listPools)
+	s.mux.HandleFunc("GET /rooms", s.listRooms)
+	s.mux.HandleFunc("GET /rooms/{uuid}", s.getRoom)
+	s.mux.HandleFunc("POST /rooms/{uuid}/start", s.startRoom)
+	s.mux.HandleFunc("POST /rooms/{uuid}/cancel", s.cancelRoom)
+	s.mux.HandleFunc("DELETE /rooms/{uuid}/players/{client}", s.kickPlayer)
+	s.mux.HandleFunc("GET /servers", s.listServers)
+	s.mux.HandleFunc("POST /servers/{uuid}/kill", s.killServer)
+	s.mux.HandleFunc("POST /servers/{uuid}/drain", s.drainServer)
+	s.mux.HandleFunc("GET /versions", s.listVersions)
+	s.mux.HandleFunc("POST /versions", s.installVersion)
+	s.mux.HandleFunc("GET /versions/{version}", s.getVersion)
+	s.mux.HandleFunc("POST /versions/{version}/status", s.setVersionStatus)
+	s.mux.HandleFunc("POST /versions/{version}/retire", s.retireVersion)
+	s.mux.HandleFunc("POST /versions/{version}/default", s.setDefaultVersion)
+	s.mux.HandleFunc("GET /node", s.nodeStatus)
+	s.mux.HandleFunc("POST /node/drain", s.drainNode)
+	s.mux.HandleFunc("POST /config/reload", s.reloadConfig)
+	s.mux.HandleFunc("GET /audit", s.audit)
+	s.mux.HandleFunc("GET /tickets/{id}", s.getTicket)
+	s.mux.HandleFunc("GET /matches", s.listMatches)
+	s.mux.HandleFunc("GET /matches/export", s.exportMatches)
+	s.mux.HandleFunc("GET /matches/{uuid}", s.getMatch)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) ListenAndServe() error {
+	if s.token == "" {
+		return errors.New("admin token is not configured")
+	}
+	log.Info("admin API is listening", "address", s.httpServer.Addr)
+	return s.httpServer.ListenAndServe()
+}
+
+func (s *Server) Close() error {
+	return s.httpServer.Close()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
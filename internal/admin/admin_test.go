@@ -0,0 +1,375 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/admin"
+	"github.com/Tagakama/ServerManager/internal/config"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
+	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+	"github.com/Tagakama/ServerManager/internal/history"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/store"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+const (
+	testToken = "test-token"
+	roomUUID  = "0f8fad5b-d9cb-469f-a165-70867728950e"
+)
+
+func newTestServer(t *testing.T) (*admin.Server, *httptest.Server) {
+	cfg := &config.Config{
+		Admin: config.Admin{Token: testToken},
+		Logs:  config.Logs{Path: t.TempDir()},
+	}
+	logs, err := room_logs.New(cfg)
+	require.NoError(t, err)
+
+	banList, err := bans.New(&config.Config{Bans: config.Bans{Path: filepath.Join(t.TempDir(), "bans.json")}})
+	require.NoError(t, err)
+
+	srv := admin.New(cfg)
+	srv.Logs = logs
+	srv.Bans = banList
+	httpServer := httptest.NewServer(srv)
+	t.Cleanup(httpServer.Close)
+	return srv, httpServer
+}
+
+func get(t *testing.T, url, token string) (int, string) {
+	return do(t, http.MethodGet, url, token, "")
+}
+
+func do(t *testing.T, method, url, token, payload string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(payload))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+	_, httpServer := newTestServer(t)
+
+	status, _ := get(t, httpServer.URL+"/rooms/"+roomUUID+"/logs", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = get(t, httpServer.URL+"/rooms/"+roomUUID+"/logs", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestAdmin_RoomLogs(t *testing.T) {
+	srv, httpServer := newTestServer(t)
+
+	path, err := srv.Logs.Prepare(roomUUID)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644))
+
+	status, body := get(t, httpServer.URL+"/rooms/"+roomUUID+"/logs", testToken)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "one\ntwo\nthree\n", body)
+
+	status, body = get(t, httpServer.URL+"/rooms/"+roomUUID+"/logs?tail=2", testToken)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "two\nthree\n", body)
+
+	status, _ = get(t, httpServer.URL+"/rooms/"+roomUUID+"/logs?file=output", testToken)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = get(t, httpServer.URL+"/rooms/not-a-uuid/logs", testToken)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestAdmin_Bans(t *testing.T) {
+	srv, httpServer := newTestServer(t)
+
+	status, body := do(t, http.MethodPost, httpServer.URL+"/bans", testToken, `{"kind":"cidr","value":"10.1.0.0/16","reason":"abuse","duration":3600}`)
+	require.Equal(t, http.StatusCreated, status, body)
+	var created bans.Ban
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), created.ExpiresAt, time.Minute)
+	assert.Error(t, srv.Bans.CheckIP(net.ParseIP("10.1.2.3"), time.Now()))
+
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/bans", testToken, `{"kind":"ip","value":"bad","reason":"abuse"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/bans", testToken, `{"kind":"client","value":"cheater"}`)
+	assert.Equal(t, http.StatusBadRequest, status, "reason is required")
+
+	status, body = get(t, httpServer.URL+"/bans", testToken)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, created.ID)
+
+	status, _ = do(t, http.MethodDelete, httpServer.URL+"/bans/"+created.ID, testToken, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = do(t, http.MethodDelete, httpServer.URL+"/bans/"+created.ID, testToken, "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+// stubServers запускает комнаты без процессов и запоминает, что с ними делали.
+type stubServers struct {
+	mu      sync.Mutex
+	stopped []string
+	drained []string
+}
+
+func (s *stubServers) LaunchGameServer(*room.Room) error { return nil }
+
+func (s *stubServers) StopGameServer(uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = append(s.stopped, uuid)
+	return nil
+}
+
+func (s *stubServers) Servers() []server_launcher.ServerInfo {
+	return []server_launcher.ServerInfo{{RoomUUID: roomUUID, PID: 4242, Port: 7777, Version: "v1", State: room.StateInMatch}}
+}
+
+func (s *stubServers) KillGameServer(uuid string) error {
+	if uuid != roomUUID {
+		return server_launcher.ErrNotRunning
+	}
+	return nil
+}
+
+func (s *stubServers) DrainGameServer(uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drained = append(s.drained, uuid)
+	return nil
+}
+
+// waitingPlayer ставит игрока в очередь и возвращает канал с ответом, который он получит.
+func waitingPlayer(mm *matchmaker.Matchmaker, clientID string) <-chan _type.ErrorResponse {
+	server, client := net.Pipe()
+	responses := make(chan _type.ErrorResponse, 1)
+	go func() {
+		defer client.Close()
+		var response _type.ErrorResponse
+		json.NewDecoder(client).Decode(&response)
+		responses <- response
+	}()
+	mm.InviteInRoom(&_type.PendingConnection{Conn: server, ConnectedMessage: _type.Message{
+		ClientID: clientID, Message: "duel", NumberOfPlayers: 1, MapName: "Forest", AppVersion: "v1",
+	}})
+	return responses
+}
+
+func TestAdmin_RoomsAndServers(t *testing.T) {
+	srv, httpServer := newTestServer(t)
+	servers := &stubServers{}
+	mm := matchmaker.New(servers)
+	mm.Modes = map[string]config.Mode{"duel": {MaxPlayers: 4, Teams: 2}}
+	srv.Matchmaker = mm
+	srv.Servers = servers
+
+	kicked := waitingPlayer(mm, "client-1")
+	cancelled := waitingPlayer(mm, "client-2")
+	require.Len(t, mm.CurrentRooms, 1)
+	uuid := mm.CurrentRooms[0].UUID
+
+	status, body := get(t, httpServer.URL+"/pools", testToken)
+	require.Equal(t, http.StatusOK, status)
+	var pools []matchmaker.PoolInfo
+	require.NoError(t, json.Unmarshal([]byte(body), &pools))
+	assert.Equal(t, []matchmaker.PoolInfo{{Mode: "duel", Map: "Forest", Version: "v1", MaxPlayers: 4, Teams: 2, Rooms: 1, FillingRooms: 1, WaitingPlayers: 2}}, pools)
+
+	status, body = get(t, httpServer.URL+"/rooms", testToken)
+	require.Equal(t, http.StatusOK, status)
+	var rooms []room.Info
+	require.NoError(t, json.Unmarshal([]byte(body), &rooms))
+	require.Len(t, rooms, 1)
+	assert.Equal(t, uuid, rooms[0].UUID)
+	assert.True(t, rooms[0].Filling)
+	assert.Len(t, rooms[0].Players, 2)
+
+	status, _ = do(t, http.MethodDelete, httpServer.URL+"/rooms/"+uuid+"/players/client-1", testToken, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Equal(t, matchmaker.CodeKicked, (<-kicked).Code)
+	status, _ = do(t, http.MethodDelete, httpServer.URL+"/rooms/"+uuid+"/players/client-1", testToken, "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/rooms/"+uuid+"/cancel", testToken, `{"reason":"maintenance"}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	response := <-cancelled
+	assert.Equal(t, matchmaker.CodeRoomCancelled, response.Code)
+	assert.Equal(t, "maintenance", response.Message)
+	assert.Equal(t, []string{uuid}, servers.stopped)
+	assert.Empty(t, mm.CurrentRooms)
+
+	// Досрочный старт: второй раз комнату уже не запустить
+	waitingPlayer(mm, "client-3")
+	require.Len(t, mm.CurrentRooms, 1)
+	started := mm.CurrentRooms[0].UUID
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/rooms/"+started+"/start", testToken, "")
+	assert.Equal(t, http.StatusAccepted, status)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/rooms/"+started+"/start", testToken, "")
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/rooms/missing/cancel", testToken, "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body = get(t, httpServer.URL+"/servers", testToken)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"pid":4242`)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/servers/"+roomUUID+"/kill", testToken, "")
+	assert.Equal(t, http.StatusAccepted, status)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/servers/missing/kill", testToken, "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/servers/"+started+"/drain", testToken, "")
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, []string{started}, servers.drained)
+}
+
+func TestAdmin_Versions(t *testing.T) {
+	root := t.TempDir()
+	for _, version := range []string{"v1", "v2"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, version), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, version, "Server.x86_64"), []byte("#!/bin/sh\n"), 0o755))
+	}
+	registry, err := versions.New(&config.Config{VersionPath: root, ExecutableName: "Server.x86_64"})
+	require.NoError(t, err)
+	srv, httpServer := newTestServer(t)
+	srv.Versions = registry
+
+	status, body := do(t, http.MethodPost, httpServer.URL+"/versions/v2/default", testToken, "")
+	require.Equal(t, http.StatusOK, status, body)
+
+	status, body = get(t, httpServer.URL+"/versions", testToken)
+	require.Equal(t, http.StatusOK, status)
+	var list []admin.VersionInfo
+	require.NoError(t, json.Unmarshal([]byte(body), &list))
+	require.Len(t, list, 2)
+	assert.False(t, list[0].Default)
+	assert.True(t, list[1].Default)
+	assert.Equal(t, []string{}, list[1].Maps)
+
+	status, body = do(t, http.MethodPost, httpServer.URL+"/versions/v2/retire", testToken, "")
+	require.Equal(t, http.StatusOK, status, body)
+	var info admin.VersionInfo
+	require.NoError(t, json.Unmarshal([]byte(body), &info))
+	assert.Equal(t, versions.StatusRetired, info.Status)
+	assert.False(t, info.Default, "retired build stops being the default")
+
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/versions/v3/retire", testToken, "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/versions/v1/status", testToken, `{"status":"broken"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/versions", testToken, `{"version":"v3","source":"/missing.zip","sha256":"00"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/node/drain", testToken, "")
+	assert.Equal(t, http.StatusNotImplemented, status)
+	reloaded := false
+	srv.Reload = func() error { reloaded = true; return nil }
+	status, _ = do(t, http.MethodPost, httpServer.URL+"/config/reload", testToken, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.True(t, reloaded)
+}
+
+func TestAdmin_Audit(t *testing.T) {
+	srv, httpServer := newTestServer(t)
+	status, _ := get(t, httpServer.URL+"/audit", testToken)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	journal := store.NewMemory()
+	srv.Store = journal
+	mm := matchmaker.New(&stubServers{})
+	mm.Modes = map[string]config.Mode{"duel": {MaxPlayers: 2}}
+	store.Subscribe(journal, mm.Bus)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+	mm.InviteInRoom(&_type.PendingConnection{Conn: server, TicketID: "ticket-1", ConnectedMessage: _type.Message{
+		ClientID: "client-1", Message: "duel", NumberOfPlayers: 1, MapName: "Forest", AppVersion: "v1",
+	}})
+	require.Len(t, mm.CurrentRooms, 1)
+	uuid := mm.CurrentRooms[0].UUID
+	require.NoError(t, mm.KickPlayer(uuid, "client-1"))
+	mm.Bus.Wait()
+
+	status, body := get(t, httpServer.URL+"/audit?client=client-1", testToken)
+	require.Equal(t, http.StatusOK, status)
+	var events []store.Event
+	require.NoError(t, json.Unmarshal([]byte(body), &events))
+	require.Len(t, events, 2)
+	assert.Equal(t, store.TicketAssigned, events[0].Kind)
+	assert.Equal(t, uuid, events[0].RoomUUID)
+	assert.Equal(t, store.TicketRejected, events[1].Kind)
+	assert.Equal(t, matchmaker.CodeKicked, events[1].Code)
+
+	status, body = get(t, httpServer.URL+"/audit?room="+uuid+"&limit=1", testToken)
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, json.Unmarshal([]byte(body), &events))
+	require.Len(t, events, 1)
+
+	status, body = get(t, httpServer.URL+"/tickets/ticket-1", testToken)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"state":"rejected"`)
+	status, _ = get(t, httpServer.URL+"/audit?since=yesterday", testToken)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestAdmin_Matches(t *testing.T) {
+	srv, httpServer := newTestServer(t)
+	matches, err := history.New(&config.Config{})
+	require.NoError(t, err)
+	srv.History = matches
+
+	played, err := room.New(_type.RoomSettings{ID: 3, MaxPlayers: 2, Mode: "duel", CurrentMap: "Forest", AppVersion: "v1"})
+	require.NoError(t, err)
+	played.Timer.Stop()
+	played.AddPlayer(&_type.PendingConnection{ConnectedMessage: _type.Message{ClientID: "client-1", NumberOfPlayers: 1}})
+	matches.Start(played)
+	played.End(json.RawMessage(`{"winner":"client-1"}`))
+	matches.Finish(played)
+
+	status, body := get(t, httpServer.URL+"/matches?client=client-1", testToken)
+	require.Equal(t, http.StatusOK, status)
+	var list []history.Match
+	require.NoError(t, json.Unmarshal([]byte(body), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, history.OutcomeCompleted, list[0].Outcome)
+
+	status, body = get(t, httpServer.URL+"/matches?room=3&client=client-2", testToken)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `[]`, body)
+
+	status, body = get(t, httpServer.URL+"/matches/"+played.UUID, testToken)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"winner":"client-1"`)
+	status, _ = get(t, httpServer.URL+"/matches/missing", testToken)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body = get(t, httpServer.URL+"/matches/export?format=csv&room="+played.UUID, testToken)
+	require.Equal(t, http.StatusOK, status)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], played.UUID+",3,duel,Forest,v1,"))
+	status, _ = get(t, httpServer.URL+"/matches/export?format=xml", testToken)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
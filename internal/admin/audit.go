@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Tagakama/ServerManager/internal/store"
+)
+
+// Без limit аудит отдаёт последние defaultAuditLimit событий.
+const defaultAuditLimit = 500
+
+// audit - история тикетов, комнат и серверов из журнала. Параметры: ticket, client,
+// room (UUID), since/until (RFC3339) и limit.
+func (s *Server) audit(w http.ResponseWriter, r *http.Request) {
+	if s.Store == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("state store is not available"))
+		return
+	}
+	query := r.URL.Query()
+	filter := store.Filter{
+		TicketID: query.Get("ticket"),
+		ClientID: query.Get("client"),
+		RoomUUID: query.Get("room"),
+		Limit:    defaultAuditLimit,
+	}
+	var err error
+	if filter.Since, err = parseTime(query.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("since: %w", err))
+		return
+	}
+	if filter.Until, err = parseTime(query.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("until: %w", err))
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be a non-negative number"))
+			return
+		}
+	}
+
+	events := s.Store.Events(filter)
+	if events == nil {
+		events = []store.Event{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) getTicket(w http.ResponseWriter, r *http.Request) {
+	if s.Store == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("state store is not available"))
+		return
+	}
+	ticket, ok := s.Store.Ticket(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("ticket not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, ticket)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
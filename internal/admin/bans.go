@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+)
+
+// banRequest: duration в секундах или expires_at; без обоих бан бессрочный.
+type banRequest struct {
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason"`
+	Duration  int       `json:"duration"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (s *Server) listBans(w http.ResponseWriter, r *http.Request) {
+	if s.Bans == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("ban list is not available"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Bans.List(time.Now()))
+}
+
+func (s *Server) addBan(w http.ResponseWriter, r *http.Request) {
+	if s.Bans == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("ban list is not available"))
+		return
+	}
+	var request banRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if request.Reason == "" {
+		writeError(w, http.StatusBadRequest, errors.New("reason is required"))
+		return
+	}
+
+	ban := bans.Ban{Kind: request.Kind, Value: request.Value, Reason: request.Reason, ExpiresAt: request.ExpiresAt}
+	if request.Duration > 0 {
+		ban.ExpiresAt = time.Now().UTC().Add(time.Duration(request.Duration) * time.Second)
+	}
+	added, err := s.Bans.Add(ban)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, added)
+}
+
+func (s *Server) removeBan(w http.ResponseWriter, r *http.Request) {
+	if s.Bans == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("ban list is not available"))
+		return
+	}
+	err := s.Bans.Remove(r.PathValue("id"))
+	if errors.Is(err, bans.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package admin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
+)
+
+const followInterval = 500 * time.Millisecond
+
+// roomLog: ?file=game|output, ?tail=N - последние N строк, ?follow=1 - дописывать новые строки.
+func (s *Server) roomLog(w http.ResponseWriter, r *http.Request) {
+	if s.Logs == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("room logs are not available"))
+		return
+	}
+	uuid := r.PathValue("uuid")
+	name := room_logs.GameLog
+	if r.URL.Query().Get("file") == "output" {
+		name = room_logs.OutputLog
+	}
+
+	path, err := s.Logs.Path(uuid, name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, errors.New("log not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if tailParam := r.URL.Query().Get("tail"); tailParam != "" {
+		lines, err := strconv.Atoi(tailParam)
+		if err != nil || lines < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("tail must be a non-negative number"))
+			return
+		}
+		tail, err := s.Logs.Tail(uuid, name, lines)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(tail) > 0 {
+			io.WriteString(w, strings.Join(tail, "\n")+"\n")
+		}
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			return
+		}
+	} else if _, err := io.Copy(w, file); err != nil {
+		return
+	}
+
+	if r.URL.Query().Get("follow") != "1" {
+		return
+	}
+	follow(w, r, file, path)
+}
+
+// follow дописывает новые строки, пока клиент не отключится. После ротации файл переоткрывается.
+func follow(w http.ResponseWriter, r *http.Request, file *os.File, path string) {
+	flusher, _ := w.(http.Flusher)
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	first := file
+	defer func() {
+		if file != first {
+			file.Close()
+		}
+	}()
+
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		offset, _ := file.Seek(0, io.SeekCurrent)
+		if info, err := os.Stat(path); err == nil && info.Size() < offset {
+			reopened, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			// Первый файл закрывает вызывающий, переоткрытые - сами
+			if file != first {
+				file.Close()
+			}
+			file = reopened
+		}
+		if _, err := io.Copy(w, file); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) roomLogArchive(w http.ResponseWriter, r *http.Request) {
+	if s.Logs == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("room logs are not available"))
+		return
+	}
+	path, err := s.Logs.ArchivePath(r.PathValue("uuid"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		writeError(w, http.StatusNotFound, errors.New("archive not found"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Tagakama/ServerManager/internal/history"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+)
+
+// Без limit список матчей отдаёт последние defaultMatchesLimit; выгрузка не ограничена.
+const defaultMatchesLimit = 100
+
+// listMatches - история матчей. Параметры: client, room (ID или UUID),
+// since/until (RFC3339, по началу матча) и limit.
+func (s *Server) listMatches(w http.ResponseWriter, r *http.Request) {
+	if s.History == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("match history is not available"))
+		return
+	}
+	filter, err := matchFilter(r.URL.Query(), defaultMatchesLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.History.Matches(filter))
+}
+
+func (s *Server) getMatch(w http.ResponseWriter, r *http.Request) {
+	if s.History == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("match history is not available"))
+		return
+	}
+	match, ok := s.History.Match(r.PathValue("uuid"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("match not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, match)
+}
+
+// exportMatches отдаёт матчи файлом: format=jsonl (по умолчанию) или csv, фильтры как у listMatches.
+func (s *Server) exportMatches(w http.ResponseWriter, r *http.Request) {
+	if s.History == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("match history is not available"))
+		return
+	}
+	query := r.URL.Query()
+	filter, err := matchFilter(query, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	format := query.Get("format")
+	contentType := "application/x-ndjson"
+	switch format {
+	case "", history.FormatJSONL:
+		format = history.FormatJSONL
+	case history.FormatCSV:
+		contentType = "text/csv"
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown export format %q", format))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=matches."+format)
+	if err := history.Export(w, format, s.History.Matches(filter)); err != nil {
+		log.Warn("failed to export matches", "format", format, logger.Err(err))
+	}
+}
+
+func matchFilter(query url.Values, limit int) (history.Filter, error) {
+	filter := history.Filter{ClientID: query.Get("client"), Limit: limit}
+	if room := query.Get("room"); room != "" {
+		// ID комнаты повторяется между рестартами, UUID - нет
+		if id, err := strconv.Atoi(room); err == nil {
+			filter.RoomID = id
+		} else {
+			filter.RoomUUID = room
+		}
+	}
+	var err error
+	if filter.Since, err = parseTime(query.Get("since")); err != nil {
+		return filter, fmt.Errorf("since: %w", err)
+	}
+	if filter.Until, err = parseTime(query.Get("until")); err != nil {
+		return filter, fmt.Errorf("until: %w", err)
+	}
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit < 0 {
+			return filter, errors.New("limit must be a non-negative number")
+		}
+	}
+	return filter, nil
+}
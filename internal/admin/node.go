@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Tagakama/ServerManager/internal/maintenance"
+)
+
+// Node - режим обслуживания ноды.
+type Node interface {
+	Drain(reason string, options maintenance.Options) error
+	Status() maintenance.Status
+}
+
+// drainRequest: все поля необязательны, по умолчанию берутся из секции maintenance конфига.
+// deadline - секунды на окончание матчей.
+type drainRequest struct {
+	Deadline int       `json:"deadline"`
+	RetryAt  time.Time `json:"retry_at"`
+	Redirect string    `json:"redirect"`
+}
+
+func (s *Server) nodeStatus(w http.ResponseWriter, r *http.Request) {
+	if s.Node == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("node drain is not supported"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Node.Status())
+}
+
+func (s *Server) drainNode(w http.ResponseWriter, r *http.Request) {
+	if s.Node == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("node drain is not supported"))
+		return
+	}
+	var request drainRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if request.Deadline < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("deadline must not be negative"))
+		return
+	}
+
+	err = s.Node.Drain("admin", maintenance.Options{
+		Deadline: time.Duration(request.Deadline) * time.Second,
+		RetryAt:  request.RetryAt,
+		Redirect: request.Redirect,
+	})
+	if errors.Is(err, maintenance.ErrAlreadyDraining) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.Node.Status())
+}
+
+func (s *Server) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	if s.Reload == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("config reload is not supported"))
+		return
+	}
+	if err := s.Reload(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	log.Info("config reloaded by operator")
+	w.WriteHeader(http.StatusNoContent)
+}
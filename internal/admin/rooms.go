@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+)
+
+// GameServers - запущенные процессы игровых серверов.
+type GameServers interface {
+	Servers() []server_launcher.ServerInfo
+	KillGameServer(uuid string) error
+	DrainGameServer(uuid string) error
+}
+
+type cancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) listPools(w http.ResponseWriter, r *http.Request) {
+	if s.Matchmaker == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("matchmaker is not available"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Matchmaker.Pools())
+}
+
+func (s *Server) listRooms(w http.ResponseWriter, r *http.Request) {
+	if s.Matchmaker == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("matchmaker is not available"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Matchmaker.Rooms())
+}
+
+func (s *Server) getRoom(w http.ResponseWriter, r *http.Request) {
+	if s.Matchmaker == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("matchmaker is not available"))
+		return
+	}
+	info, err := s.Matchmaker.Room(r.PathValue("uuid"))
+	if err != nil {
+		writeControlError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) startRoom(w http.ResponseWriter, r *http.Request) {
+	if s.Matchmaker == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("matchmaker is not available"))
+		return
+	}
+	if err := s.Matchmaker.ForceStart(r.PathValue("uuid")); err != nil {
+		writeControlError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// cancelRoom: тело {"reason": "..."} необязательно, причина уходит ожидающим игрокам.
+func (s *Server) cancelRoom(w http.ResponseWriter, r *http.Request) {
+	if s.Matchmaker == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("matchmaker is not available"))
+		return
+	}
+	var request cancelRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.Matchmaker.CancelRoom(r.PathValue("uuid"), request.Reason); err != nil {
+		writeControlError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) kickPlayer(w http.ResponseWriter, r *http.Request) {
+	if s.Matchmaker == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("matchmaker is not available"))
+		return
+	}
+	if err := s.Matchmaker.KickPlayer(r.PathValue("uuid"), r.PathValue("client")); err != nil {
+		writeControlError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listServers(w http.ResponseWriter, r *http.Request) {
+	if s.Servers == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("game servers are not available"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Servers.Servers())
+}
+
+func (s *Server) killServer(w http.ResponseWriter, r *http.Request) {
+	if s.Servers == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("game servers are not available"))
+		return
+	}
+	if err := s.Servers.KillGameServer(r.PathValue("uuid")); err != nil {
+		writeControlError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// drainServer: если комната ещё набирается, матч стартует с теми, кто уже есть,
+// а сервер останавливается после его окончания.
+func (s *Server) drainServer(w http.ResponseWriter, r *http.Request) {
+	if s.Servers == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("game servers are not available"))
+		return
+	}
+	uuid := r.PathValue("uuid")
+	if s.Matchmaker != nil {
+		if err := s.Matchmaker.ForceStart(uuid); err != nil && !errors.Is(err, room.ErrClosed) && !errors.Is(err, matchmaker.ErrRoomNotFound) {
+			writeControlError(w, err)
+			return
+		}
+	}
+	if err := s.Servers.DrainGameServer(uuid); err != nil {
+		writeControlError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeControlError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, matchmaker.ErrRoomNotFound), errors.Is(err, room.ErrPlayerNotFound), errors.Is(err, server_launcher.ErrNotRunning):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, room.ErrClosed):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
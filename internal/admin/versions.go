@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+)
+
+// Versions - реестр сборок игрового сервера.
+type Versions interface {
+	List() []*versions.Build
+	Get(version string) (*versions.Build, bool)
+	Default() string
+	SetDefault(version string) error
+	SetStatus(version, status string) error
+	Install(ctx context.Context, req versions.InstallRequest) (*versions.Build, error)
+}
+
+// VersionInfo - сборка в ответах admin API.
+type VersionInfo struct {
+	Version    string   `json:"version"`
+	Status     string   `json:"status"`
+	Default    bool     `json:"default"`
+	Dir        string   `json:"dir"`
+	Executable string   `json:"executable"`
+	Maps       []string `json:"maps"`
+}
+
+// installRequest: source - локальный путь или http(s) URL архива, default - сразу сделать версией по умолчанию.
+type installRequest struct {
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	SHA256  string `json:"sha256"`
+	Default bool   `json:"default"`
+}
+
+type statusRequest struct {
+	Status string `json:"status"`
+}
+
+func (s *Server) versionInfo(build *versions.Build) VersionInfo {
+	maps := build.Maps
+	if maps == nil {
+		maps = []string{}
+	}
+	return VersionInfo{
+		Version:    build.Version,
+		Status:     build.Status,
+		Default:    build.Version == s.Versions.Default(),
+		Dir:        build.Dir,
+		Executable: build.Executable,
+		Maps:       maps,
+	}
+}
+
+func (s *Server) listVersions(w http.ResponseWriter, r *http.Request) {
+	if s.Versions == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("version registry is not available"))
+		return
+	}
+	builds := s.Versions.List()
+	result := make([]VersionInfo, 0, len(builds))
+	for _, build := range builds {
+		result = append(result, s.versionInfo(build))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getVersion(w http.ResponseWriter, r *http.Request) {
+	if s.Versions == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("version registry is not available"))
+		return
+	}
+	build, ok := s.Versions.Get(r.PathValue("version"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("version not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.versionInfo(build))
+}
+
+// installVersion ждёт окончания скачивания и распаковки, поэтому может занять время.
+func (s *Server) installVersion(w http.ResponseWriter, r *http.Request) {
+	if s.Versions == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("version registry is not available"))
+		return
+	}
+	var request installRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if request.Source == "" {
+		writeError(w, http.StatusBadRequest, errors.New("source is required"))
+		return
+	}
+
+	build, err := s.Versions.Install(r.Context(), versions.InstallRequest{
+		Version: request.Version,
+		Source:  request.Source,
+		SHA256:  request.SHA256,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	log.Info("version installed by operator", "version", build.Version, "source", request.Source)
+	if request.Default {
+		if err := s.Versions.SetDefault(build.Version); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusCreated, s.versionInfo(build))
+}
+
+// setVersionStatus: {"status": "active|deprecated|draining|retired"}.
+func (s *Server) setVersionStatus(w http.ResponseWriter, r *http.Request) {
+	var request statusRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.changeVersion(w, r.PathValue("version"), func(version string) error {
+		return s.Versions.SetStatus(version, request.Status)
+	})
+}
+
+// retireVersion - новые комнаты на версии не создаются, запущенные матчи доигрывают.
+func (s *Server) retireVersion(w http.ResponseWriter, r *http.Request) {
+	s.changeVersion(w, r.PathValue("version"), func(version string) error {
+		return s.Versions.SetStatus(version, versions.StatusRetired)
+	})
+}
+
+func (s *Server) setDefaultVersion(w http.ResponseWriter, r *http.Request) {
+	s.changeVersion(w, r.PathValue("version"), func(version string) error {
+		return s.Versions.SetDefault(version)
+	})
+}
+
+func (s *Server) changeVersion(w http.ResponseWriter, version string, change func(string) error) {
+	if s.Versions == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("version registry is not available"))
+		return
+	}
+	err := change(version)
+	var versionErr *versions.Error
+	if errors.As(err, &versionErr) && versionErr.Code == versions.CodeUnknownVersion {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	log.Info("version changed by operator", "version", version)
+	build, _ := s.Versions.Get(version)
+	writeJSON(w, http.StatusOK, s.versionInfo(build))
+}
@@ -0,0 +1,156 @@
+package bus
+
+import (
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"sync"
+)
+
+var log = logger.For("bus")
+
+var (
+	pendingEvents = metrics.Default.Gauge("sm_bus_events_pending", "Events waiting for a subscriber to handle them.", "subscriber")
+	handlerPanics = metrics.Default.Counter("sm_bus_handler_panics_total", "Subscriber handlers that panicked.", "subscriber")
+)
+
+// Event - сообщение шины. Key задаёт порядок: события с одним ключом (UUID комнаты,
+// ID тикета) подписчик получает по одному и в порядке публикации.
+type Event interface {
+	Key() string
+}
+
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Bus - шина событий внутри процесса. Каждый подписчик обрабатывает события
+// в своих горутинах: медленный подписчик не задерживает остальных и того, кто публикует.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	pending     sync.WaitGroup
+}
+
+type subscriber struct {
+	name     string
+	handlers []handler
+
+	mu     sync.Mutex
+	queues map[string][]Event
+}
+
+type handler struct {
+	accept func(event Event) bool
+	handle func(event Event)
+}
+
+func New() *Bus {
+	return &Bus{}
+}
+
+// Subscribe подписывает fn на события типа E. Подписки с одним name - один подписчик:
+// события одного ключа он получает по порядку, каких бы типов они ни были.
+func Subscribe[E Event](b *Bus, name string, fn func(event E)) {
+	h := handler{
+		accept: func(event Event) bool {
+			_, ok := event.(E)
+			return ok
+		},
+		handle: func(event Event) {
+			fn(event.(E))
+		},
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.subscribers {
+		if s.name == name {
+			s.handlers = append(s.handlers, h)
+			return
+		}
+	}
+	b.subscribers = append(b.subscribers, &subscriber{name: name, handlers: []handler{h}, queues: make(map[string][]Event)})
+}
+
+// Publish не ждёт обработчиков. Публиковать на nil-шину можно: событие никто не получит.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subscribers {
+		if s.accepts(event) {
+			b.pending.Add(1)
+			s.enqueue(b, event)
+		}
+	}
+}
+
+// Wait ждёт, пока будут обработаны все опубликованные события, включая
+// опубликованные самими обработчиками.
+func (b *Bus) Wait() {
+	if b == nil {
+		return
+	}
+	b.pending.Wait()
+}
+
+func (s *subscriber) enqueue(b *Bus, event Event) {
+	key := event.Key()
+	s.mu.Lock()
+	queue, running := s.queues[key]
+	s.queues[key] = append(queue, event)
+	s.mu.Unlock()
+	pendingEvents.Inc(s.name)
+	if !running {
+		go s.drain(b, key)
+	}
+}
+
+// drain - одна горутина на ключ, пока у него есть события.
+func (s *subscriber) drain(b *Bus, key string) {
+	for {
+		s.mu.Lock()
+		queue := s.queues[key]
+		if len(queue) == 0 {
+			delete(s.queues, key)
+			s.mu.Unlock()
+			return
+		}
+		event := queue[0]
+		s.queues[key] = queue[1:]
+		s.mu.Unlock()
+
+		b.mu.RLock()
+		handlers := s.handlers
+		b.mu.RUnlock()
+		for _, h := range handlers {
+			if h.accept(event) {
+				s.dispatch(h, event)
+			}
+		}
+		pendingEvents.Dec(s.name)
+		b.pending.Done()
+	}
+}
+
+// accepts вызывается под блокировкой шины.
+func (s *subscriber) accepts(event Event) bool {
+	for _, h := range s.handlers {
+		if h.accept(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *subscriber) dispatch(h handler, event Event) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			handlerPanics.Inc(s.name)
+			log.Error("event handler panicked", "subscriber", s.name, "event", fmt.Sprintf("%T", event), "key", event.Key(), "panic", recovered)
+		}
+	}()
+	h.handle(event)
+}
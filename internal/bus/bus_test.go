@@ -0,0 +1,127 @@
+package bus_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Tagakama/ServerManager/internal/bus"
+)
+
+type roomEvent struct {
+	room string
+	seq  int
+}
+
+func (e roomEvent) Key() string { return e.room }
+
+type otherEvent struct{}
+
+func (otherEvent) Key() string { return "" }
+
+type keyedOther struct {
+	room string
+}
+
+func (e keyedOther) Key() string { return e.room }
+
+func TestBus_OrdersEventsPerKey(t *testing.T) {
+	b := bus.New()
+	var mu sync.Mutex
+	received := make(map[string][]int)
+	bus.Subscribe(b, "test", func(e roomEvent) {
+		// Медленная обработка не должна перемешать события одной комнаты
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		received[e.room] = append(received[e.room], e.seq)
+	})
+
+	for seq := 0; seq < 20; seq++ {
+		b.Publish(roomEvent{room: "a", seq: seq})
+		b.Publish(roomEvent{room: "b", seq: seq})
+	}
+	b.Wait()
+
+	for _, room := range []string{"a", "b"} {
+		assert.Len(t, received[room], 20)
+		for i, seq := range received[room] {
+			assert.Equal(t, i, seq, room)
+		}
+	}
+}
+
+func TestBus_OrdersTypesOfOneSubscriber(t *testing.T) {
+	b := bus.New()
+	var mu sync.Mutex
+	var received []string
+	record := func(name string) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, name)
+	}
+	bus.Subscribe(b, "journal", func(roomEvent) { record("room") })
+	bus.Subscribe(b, "journal", func(keyedOther) { record("other") })
+
+	for i := 0; i < 5; i++ {
+		b.Publish(roomEvent{room: "a"})
+		b.Publish(keyedOther{room: "a"})
+	}
+	b.Wait()
+
+	for i, name := range received {
+		assert.Equal(t, []string{"room", "other"}[i%2], name)
+	}
+	assert.Len(t, received, 10)
+}
+
+func TestBus_DeliversByType(t *testing.T) {
+	b := bus.New()
+	var rooms, others, all int
+	var mu sync.Mutex
+	bus.Subscribe(b, "rooms", func(roomEvent) { mu.Lock(); rooms++; mu.Unlock() })
+	bus.Subscribe(b, "others", func(otherEvent) { mu.Lock(); others++; mu.Unlock() })
+	bus.Subscribe(b, "all", func(bus.Event) { mu.Lock(); all++; mu.Unlock() })
+
+	b.Publish(roomEvent{room: "a"})
+	b.Publish(otherEvent{})
+	b.Publish(roomEvent{room: "b"})
+	b.Wait()
+
+	assert.Equal(t, 2, rooms)
+	assert.Equal(t, 1, others)
+	assert.Equal(t, 3, all)
+}
+
+func TestBus_HandlerPanicDoesNotStopDelivery(t *testing.T) {
+	b := bus.New()
+	var mu sync.Mutex
+	var handled []int
+	bus.Subscribe(b, "panicky", func(e roomEvent) {
+		if e.seq == 0 {
+			panic("boom")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e.seq)
+	})
+	// Обработчик может публиковать сам: Wait дождётся и этих событий
+	bus.Subscribe(b, "chain", func(e roomEvent) {
+		if e.seq < 2 {
+			b.Publish(roomEvent{room: "chain", seq: e.seq + 10})
+		}
+	})
+
+	b.Publish(roomEvent{room: "a", seq: 0})
+	b.Publish(roomEvent{room: "a", seq: 1})
+	b.Wait()
+
+	assert.ElementsMatch(t, []int{1, 10, 11}, handled)
+
+	var nilBus *bus.Bus
+	nilBus.Publish(roomEvent{})
+	nilBus.Wait()
+}
@@ -1,47 +1,201 @@
 package config
 
-import (
-	"github.com/ilyakaznacheev/cleanenv"
-	"log"
-	"os"
-	"path/filepath"
-)
-
 type Config struct {
 	Env            string `yaml:"env" env-default:"development"`
 	VersionPath    string `yaml:"version_path" env-default:""`
 	ExecutableName string `yaml:"executable_name" env-default:""`
 	TCPServer      `yaml:"tcp_server"`
+	Launch         Launch          `yaml:"launch"`
+	Modes          map[string]Mode `yaml:"modes"`
+	Logs           Logs            `yaml:"logs"`
+	Admin          Admin           `yaml:"admin"`
+	Control        Control         `yaml:"control"`
+	JoinTokens     JoinTokens      `yaml:"join_tokens"`
+	Auth           Auth            `yaml:"auth"`
+	RateLimit      RateLimit       `yaml:"rate_limit"`
+	Bans           Bans            `yaml:"bans"`
+	Logging        Logging         `yaml:"logging"`
+	Metrics        Metrics         `yaml:"metrics"`
+	Tracing        Tracing         `yaml:"tracing"`
+	Maintenance    Maintenance     `yaml:"maintenance"`
+	Store          Store           `yaml:"store"`
+	History        History         `yaml:"history"`
+	Webhooks       Webhooks        `yaml:"webhooks"`
+	// Include - фрагменты конфига поверх файла, например каталог режимов modes.d/*.yaml.
+	// Пути относительно файла конфига, фрагменты применяются по алфавиту
+	Include []string `yaml:"include"`
 }
 
+// Сколько комната набирает игроков и сколько ждём готовности её сервера, секунды.
+// Клиент, вставший в очередь, ждёт ответа до их суммы, поэтому idle_timeout должен быть больше.
+const (
+	RoomFillTimeout = 30
+	LaunchTimeout   = 30
+)
+
 type TCPServer struct {
-	Address     string `yaml:"address" env-default:"0.0.0.0"`
-	Port        string `yaml:"port" env-default:"8080"`
-	Timeout     int    `yaml:"timeout" env-default:"6"`
-	IdleTimeout int    `yaml:"idle_timeout" env-default:"60"`
-	WorkerCount int    `yaml:"worker_count" env-default:"1"`
-}
-
-func MustLoad() *Config {
-	configPath := os.Getenv("CONFIG_PATH")
-
-	if configPath == "" {
-		wd, err := os.Getwd()
-		if err != nil {
-			log.Fatalf("cannot get working directory: %v", err)
-		}
-		configPath = filepath.Join(wd, "configs/config.yaml")
-	}
-
-	if _, err := os.Stat(configPath); err != nil {
-		log.Fatalf("config file not found: %s", configPath)
-	}
-
-	var cfg Config
-
-	err := cleanenv.ReadConfig(configPath, &cfg)
-	if err != nil {
-		log.Fatalf("error reading config file: %s", err)
-	}
-	return &cfg
+	Address        string `yaml:"address" env-default:"0.0.0.0"`
+	Port           string `yaml:"port" env-default:"8080"`
+	Timeout        int    `yaml:"timeout" env-default:"6"`
+	IdleTimeout    int    `yaml:"idle_timeout" env-default:"90"`
+	WorkerCount    int    `yaml:"worker_count" env-default:"1"`
+	MaxMessageSize int    `yaml:"max_message_size" env-default:"1024"`
+	Queue          Queue  `yaml:"queue"`
+	TLS            TLS    `yaml:"tls"`
+}
+
+// Queue - очередь запросов перед воркерами. Overflow - что делать, когда она полна:
+// reject - сразу отказ "Worker pool is full", block - ждать места до BlockTimeout секунд,
+// spill - поставить во вторую очередь на SpillSize запросов и отказать, только когда полна и она.
+type Queue struct {
+	Size         int    `yaml:"size" env-default:"100"`
+	Overflow     string `yaml:"overflow" env-default:"reject"`
+	BlockTimeout int    `yaml:"block_timeout" env-default:"2"`
+	SpillSize    int    `yaml:"spill_size" env-default:"1000"`
+}
+
+// TLS включается, если заданы cert и key. Файлы перечитываются при замене на диске.
+// ClientAuth: none, optional (сертификат проверяется, если предъявлен) или require.
+// Клиенты с проверенным сертификатом считаются доверенными и не проходят Auth.
+type TLS struct {
+	Cert       string `yaml:"cert" env:"TLS_CERT"`
+	Key        string `yaml:"key" env:"TLS_KEY"`
+	ClientCA   string `yaml:"client_ca"`
+	ClientAuth string `yaml:"client_auth" env-default:"none"`
+}
+
+// Launch - шаблоны аргументов и переменных окружения игрового сервера.
+// Манифест сборки может их переопределить.
+type Launch struct {
+	Region    string            `yaml:"region" env-default:"eu"`
+	Arguments []string          `yaml:"arguments"`
+	Env       map[string]string `yaml:"env"`
+}
+
+// Mode - режим игры. Режим выбирается по полю Message запроса, остальные запросы идут в "default".
+type Mode struct {
+	MaxPlayers int `yaml:"max_players"`
+	Teams      int `yaml:"teams"`
+}
+
+type Logs struct {
+	Path           string `yaml:"path" env-default:"Logs"`
+	MaxSizeMB      int    `yaml:"max_size_mb" env-default:"50"`
+	MaxBackups     int    `yaml:"max_backups" env-default:"3"`
+	RetentionHours int    `yaml:"retention_hours" env-default:"168"`
+}
+
+// Admin - HTTP API для операторов. Без токена API не поднимается.
+type Admin struct {
+	Address string `yaml:"address" env-default:"127.0.0.1:8090"`
+	Token   string `yaml:"token" env:"ADMIN_TOKEN"`
+}
+
+// Control - обратный канал от игровых серверов, слушает только loopback.
+type Control struct {
+	Address string `yaml:"address" env-default:"127.0.0.1:8091"`
+}
+
+// JoinTokens - подписанные токены входа на игровой сервер.
+// Algorithm: EdDSA (серверу отдаётся только публичный ключ) или HS256.
+// Без key_path ключ генерируется при старте.
+type JoinTokens struct {
+	Algorithm string `yaml:"algorithm" env-default:"EdDSA"`
+	KeyPath   string `yaml:"key_path" env:"JOIN_TOKEN_KEY_PATH"`
+	TTL       int    `yaml:"ttl" env-default:"120"`
+}
+
+// Auth - проверка клиентов matchmaking. Method: none, hmac, jwt или callback.
+// Учётные данные клиент передаёт шестым полем сообщения.
+type Auth struct {
+	Method          string   `yaml:"method" env-default:"none"`
+	Secret          string   `yaml:"secret" env:"AUTH_SECRET"`
+	MaxSkew         int      `yaml:"max_skew" env-default:"300"`
+	PublicKeys      []string `yaml:"public_keys"`
+	Issuer          string   `yaml:"issuer"`
+	Audience        string   `yaml:"audience"`
+	CallbackURL     string   `yaml:"callback_url"`
+	CallbackTimeout int      `yaml:"callback_timeout" env-default:"5"`
+}
+
+// RateLimit - лимиты входящих запросов, per_ip и per_client в запросах в секунду.
+// Ноль отключает лимит.
+type RateLimit struct {
+	PerIP               float64 `yaml:"per_ip" env-default:"5"`
+	PerIPBurst          int     `yaml:"per_ip_burst" env-default:"10"`
+	PerClient           float64 `yaml:"per_client" env-default:"1"`
+	PerClientBurst      int     `yaml:"per_client_burst" env-default:"3"`
+	MaxConnections      int     `yaml:"max_connections" env-default:"10000"`
+	MaxConnectionsPerIP int     `yaml:"max_connections_per_ip" env-default:"50"`
+}
+
+// Bans - файл бан-листа, управляется через admin API.
+type Bans struct {
+	Path string `yaml:"path" env-default:"bans.json"`
+}
+
+// Logging - журнал самого сервиса (логи игровых серверов - в Logs).
+// Пустые format и level берутся по Env: prod - json/info, иначе text/debug.
+// Components задаёт уровень отдельным подсистемам: matchmaker, launcher, handler и т.д.
+type Logging struct {
+	Format     string            `yaml:"format"`
+	Level      string            `yaml:"level"`
+	Components map[string]string `yaml:"components"`
+}
+
+// Metrics - эндпоинт /metrics в формате Prometheus.
+type Metrics struct {
+	Address string `yaml:"address" env-default:"127.0.0.1:9100"`
+}
+
+// Tracing - span'ы пути запроса от приёма до ответа. Exporter: none, file (JSON по строке в Path)
+// или otlp (OTLP/HTTP на Endpoint, например http://127.0.0.1:4318).
+type Tracing struct {
+	Exporter    string `yaml:"exporter" env-default:"none"`
+	Path        string `yaml:"path" env-default:"traces.jsonl"`
+	Endpoint    string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `yaml:"service_name" env-default:"server-manager"`
+}
+
+// Maintenance - вывод ноды из ротации перед деплоем. Deadline - сколько секунд ждать
+// окончания матчей; Redirect - адрес другой ноды для новых запросов, без него клиент
+// получает "maintenance" со временем повтора.
+type Maintenance struct {
+	Deadline int    `yaml:"deadline" env-default:"1800"`
+	Redirect string `yaml:"redirect"`
+}
+
+// Store - журнал тикетов, комнат и серверов для восстановления после рестарта и аудита.
+// Driver: file (JSON по строке в Path) или memory. Sync - fsync после каждой записи.
+type Store struct {
+	Driver         string `yaml:"driver" env-default:"file"`
+	Path           string `yaml:"path" env-default:"state/journal.jsonl"`
+	Sync           bool   `yaml:"sync"`
+	RetentionHours int    `yaml:"retention_hours" env-default:"168"`
+}
+
+// History - сыгранные матчи для поддержки и аналитики, JSON по строке в Path.
+// Матчи старше RetentionDays удаляются при старте и раз в сутки; ноль - хранить всё.
+type History struct {
+	Path          string `yaml:"path" env-default:"state/matches.jsonl"`
+	RetentionDays int    `yaml:"retention_days" env-default:"90"`
+}
+
+// Webhooks - уведомления бэкенда о жизни комнат и матчей. Без Endpoints ничего не отправляется.
+// Неотправленные события лежат в OutboxPath и переживают рестарт. Повторы идут с паузой
+// от Backoff до MaxBackoff секунд, удваиваясь; после MaxAttempts событие выбрасывается.
+type Webhooks struct {
+	Endpoints   []WebhookEndpoint `yaml:"endpoints"`
+	OutboxPath  string            `yaml:"outbox_path" env-default:"state/webhooks.jsonl"`
+	Timeout     int               `yaml:"timeout" env-default:"5"`
+	MaxAttempts int               `yaml:"max_attempts" env-default:"12"`
+	Backoff     int               `yaml:"backoff" env-default:"1"`
+	MaxBackoff  int               `yaml:"max_backoff" env-default:"600"`
+}
+
+// WebhookEndpoint - получатель. Secret обязателен и подписывает тело запроса; пустой Events - все события.
+type WebhookEndpoint struct {
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
 }
@@ -0,0 +1,196 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad_RepoConfigIsValid(t *testing.T) {
+	cfg, err := config.Load("../../configs/config.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.0.0", cfg.Address)
+	assert.Equal(t, 2, cfg.WorkerCount)
+}
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	path := writeConfig(t, `
+tcp_server:
+  addres: "0.0.0.0"
+  port: "http"
+  worker_count: 0
+  timeout: "soon"
+  idle_timeout: 30
+modes:
+  duel:
+    max_players: 3
+    teams: 2
+launch:
+  arguments: ["-port", "{{.Port}"]
+  env:
+    SM_REGION: "{{.Region}}"
+    SM_MAP: "{{if .Map}}"
+auth:
+  method: "password"
+logging:
+  level: "loud"
+maintenance:
+  deadline: 0
+webhooks:
+  endpoints:
+    - url: "backend.example.com/hooks"
+unknown_section: true
+`)
+	_, err := config.Load(path)
+	require.Error(t, err)
+	for _, problem := range []string{
+		"field addres not found in type config.TCPServer",
+		"field unknown_section not found",
+		"cannot unmarshal !!str `soon` into int",
+		`tcp_server.port: must be a port number from 1 to 65535, got "http"`,
+		"tcp_server.worker_count: must be at least 1, got 0",
+		"tcp_server.idle_timeout: must be 0 or more than 60 seconds",
+		"modes.duel: max_players 3 must divide evenly into 2 teams",
+		`launch.arguments[1]: invalid template "{{.Port}"`,
+		`launch.env.SM_MAP: invalid template "{{if .Map}}"`,
+		`auth.method: must be one of none, hmac, jwt, callback, got "password"`,
+		"logging.level: must be debug, info, warn or error",
+		"maintenance.deadline: must be at least 1, got 0",
+		"webhooks.endpoints[0].url: must be an http or https URL",
+		"webhooks.endpoints[0].secret: is required to sign payloads",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+	assert.NotContains(t, err.Error(), "launch.env.SM_REGION")
+}
+
+func TestLoad_Layers(t *testing.T) {
+	path := writeConfig(t, `
+include: ["modes.d/*.yaml"]
+admin:
+  token: "from-file"
+rate_limit:
+  per_ip: 0
+tcp_server:
+  worker_count: 2
+modes:
+  default:
+    max_players: 8
+`)
+	modes := filepath.Join(filepath.Dir(path), "modes.d")
+	require.NoError(t, os.MkdirAll(modes, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(modes, "duel.yaml"), []byte("modes:\n  duel:\n    max_players: 2\n    teams: 2\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(modes, "squad.yaml"), []byte("modes:\n  squad:\n    max_players: 4\n"), 0o644))
+	t.Setenv("ADMIN_TOKEN", "from-env")
+	t.Setenv("SM_TCP_SERVER_WORKER_COUNT", "4")
+	t.Setenv("SM_AUTH_PUBLIC_KEYS", "[a.pem, b.pem]")
+
+	cfg, sources, err := config.LoadSources(config.Options{
+		Path:      path,
+		Overrides: []string{"tcp_server.worker_count=6", "modes.squad.teams=2"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "8080", cfg.Port, "missing keys take defaults")
+	assert.Zero(t, cfg.RateLimit.PerIP, "explicit zero disables the limit instead of taking the default")
+	assert.Equal(t, 10, cfg.RateLimit.PerIPBurst)
+	assert.Equal(t, "from-env", cfg.Admin.Token, "environment overrides the file")
+	assert.Equal(t, []string{"a.pem", "b.pem"}, cfg.Auth.PublicKeys)
+	assert.Equal(t, 6, cfg.WorkerCount, "flags override the environment")
+	assert.Equal(t, map[string]config.Mode{
+		"default": {MaxPlayers: 8},
+		"duel":    {MaxPlayers: 2, Teams: 2},
+		"squad":   {MaxPlayers: 4, Teams: 2},
+	}, cfg.Modes, "fragments add modes to the file")
+
+	assert.Equal(t, "default", sources.Of("tcp_server.port"))
+	assert.Equal(t, "file "+path, sources.Of("rate_limit.per_ip"))
+	assert.Equal(t, "include "+filepath.Join(modes, "duel.yaml"), sources.Of("modes.duel.teams"))
+	assert.Equal(t, "env ADMIN_TOKEN", sources.Of("admin.token"))
+	assert.Equal(t, "env SM_AUTH_PUBLIC_KEYS", sources.Of("auth.public_keys"))
+	assert.Equal(t, "flag -set", sources.Of("tcp_server.worker_count"))
+
+	var out bytes.Buffer
+	require.NoError(t, config.Print(&out, cfg, sources))
+	assert.Regexp(t, `modes.squad.teams\s+2\s+flag -set`, out.String())
+	assert.Regexp(t, `admin.token\s+<hidden>\s+env ADMIN_TOKEN`, out.String())
+	assert.NotContains(t, out.String(), "from-env")
+}
+
+func TestLoad_RejectsBadLayers(t *testing.T) {
+	path := writeConfig(t, "include: [\"extra.yaml\"]\n")
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(path), "extra.yaml"), []byte("include: [\"more.yaml\"]\nmodes: {duel: {max_players: x}}\n"), 0o644))
+	t.Setenv("SM_TCP_SERVER_TIMEOUT", "soon")
+
+	_, _, err := config.LoadSources(config.Options{Path: path, Overrides: []string{"tcp_server.workers=2", "env"}})
+	require.Error(t, err)
+	for _, problem := range []string{
+		"extra.yaml: include is only allowed in the main config file",
+		"cannot unmarshal !!str `x` into int",
+		"env SM_TCP_SERVER_TIMEOUT: ",
+		`-set tcp_server.workers: unknown key "workers"`,
+		`-set "env": must be key=value`,
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
+
+func TestReload_AppliesSafeSettings(t *testing.T) {
+	current, err := config.Load(writeConfig(t, "tcp_server:\n  port: \"8080\"\n"))
+	require.NoError(t, err)
+	next, err := config.Load(writeConfig(t, `
+tcp_server:
+  port: "9090"
+  timeout: 10
+modes:
+  duel:
+    max_players: 2
+rate_limit:
+  per_client: 5
+admin:
+  token: "secret"
+`))
+	require.NoError(t, err)
+
+	applied, restart := config.Reload(current, next)
+	assert.Equal(t, 10, applied.Timeout)
+	assert.Equal(t, 2, applied.Modes["duel"].MaxPlayers)
+	assert.Equal(t, 5.0, applied.RateLimit.PerClient)
+	assert.Equal(t, "8080", applied.Port, "listener address needs a restart")
+	assert.Empty(t, applied.Admin.Token)
+	assert.ElementsMatch(t, []string{"tcp_server.port", "admin.token"}, restart)
+}
+
+func TestWatch(t *testing.T) {
+	path := writeConfig(t, "env: dev\n")
+	changed := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go config.Watch(path, 10*time.Millisecond, stop, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("env: production\n"), 0o644))
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("change is not noticed")
+	}
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// EnvPrefix - у каждого ключа есть переменная окружения: SM_ и путь ключа,
+// например SM_TCP_SERVER_PORT или SM_RATE_LIMIT_PER_IP. Значение - YAML:
+// SM_AUTH_PUBLIC_KEYS='[a.pem, b.pem]'. Старые имена из тегов env (ADMIN_TOKEN и др.) тоже работают.
+const EnvPrefix = "SM_"
+
+// Options - откуда брать конфиг. Overrides - "ключ=значение" с флагов -set,
+// ключ - путь в YAML: tcp_server.port, modes.duel.max_players.
+type Options struct {
+	Path      string
+	Overrides []string
+}
+
+// Sources - откуда взято значение ключа: default, file, include, env или flag.
+type Sources map[string]string
+
+// Of ищет источник ключа или ближайшего раздела над ним: список из файла
+// целиком помечен источником самого списка.
+func (s Sources) Of(path string) string {
+	for path != "" {
+		if source, ok := s[path]; ok {
+			return source
+		}
+		if strings.HasSuffix(path, "]") {
+			path = path[:strings.LastIndex(path, "[")]
+			continue
+		}
+		dot := strings.LastIndex(path, ".")
+		if dot < 0 {
+			break
+		}
+		path = path[:dot]
+	}
+	return "default"
+}
+
+// Path - CONFIG_PATH или configs/config.yaml в рабочей директории.
+func Path() (string, error) {
+	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
+		return configPath, nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(wd, "configs/config.yaml"), nil
+}
+
+func Load(path string) (*Config, error) {
+	cfg, _, err := LoadSources(Options{Path: path})
+	return cfg, err
+}
+
+// LoadSources собирает конфиг слоями, каждый следующий поверх предыдущего: значения
+// по умолчанию, файл, фрагменты из include, переменные окружения, флаги. Файлы читаются
+// строго: неизвестные ключи и значения неверного типа - ошибка. Явный ноль остаётся нулём,
+// а не значением по умолчанию. Возвращаются сразу все найденные ошибки, по одной на строку.
+func LoadSources(options Options) (*Config, Sources, error) {
+	var cfg Config
+	sources := Sources{}
+	root := reflect.ValueOf(&cfg).Elem()
+	setDefaults(root, "", sources)
+
+	problems, err := decodeFile(options.Path, "file "+options.Path, &cfg, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	includes := cfg.Include
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(options.Path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("include %q: %w", pattern, err))
+			continue
+		}
+		for _, match := range matches {
+			fragmentProblems, err := decodeFile(match, "include "+match, &cfg, sources)
+			if err != nil {
+				problems = append(problems, err)
+			}
+			problems = append(problems, fragmentProblems...)
+			if !reflect.DeepEqual(cfg.Include, includes) {
+				problems = append(problems, fmt.Errorf("%s: include is only allowed in the main config file", match))
+				cfg.Include = includes
+			}
+		}
+	}
+
+	problems = append(problems, readEnv(root, "", sources)...)
+
+	for _, override := range options.Overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			problems = append(problems, fmt.Errorf("-set %q: must be key=value", override))
+			continue
+		}
+		if err := assign(root, strings.Split(key, "."), value); err != nil {
+			problems = append(problems, fmt.Errorf("-set %s: %w", key, err))
+			continue
+		}
+		sources[key] = "flag -set"
+	}
+
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 {
+		return nil, nil, errors.Join(problems...)
+	}
+	return &cfg, sources, nil
+}
+
+// decodeFile кладёт файл поверх cfg. err - файл не прочитан совсем, problems - отдельные
+// ключи, остальные при этом применены.
+func decodeFile(path, source string, cfg *Config, sources Sources) (problems []error, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, message := range typeErr.Errors {
+			problems = append(problems, fmt.Errorf("%s: %s", path, message))
+		}
+	}
+	if len(document.Content) > 0 {
+		recordSources(document.Content[0], "", source, sources)
+	}
+	return problems, nil
+}
+
+// recordSources помечает заданные в файле ключи. Пустое значение (key:) ничего
+// не меняет, поэтому не помечается.
+func recordSources(node *yaml.Node, prefix, source string, sources Sources) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		path := joinPath(prefix, node.Content[i].Value)
+		value := node.Content[i+1]
+		switch {
+		case value.Kind == yaml.MappingNode:
+			recordSources(value, path, source, sources)
+		case value.Tag == "!!null":
+		default:
+			sources[path] = source
+		}
+	}
+}
+
+func setDefaults(v reflect.Value, prefix string, sources Sources) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		path := joinPath(prefix, yamlName(field))
+		if field.Type.Kind() == reflect.Struct {
+			setDefaults(v.Field(i), path, sources)
+			continue
+		}
+		if value, ok := field.Tag.Lookup("env-default"); ok && value != "" {
+			// Теги env-default пишем сами, TestLoad_Layers разбирает их все
+			decodeValue(value, v.Field(i))
+			sources[path] = "default"
+		}
+	}
+}
+
+func readEnv(v reflect.Value, prefix string, sources Sources) []error {
+	var problems []error
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		path := joinPath(prefix, yamlName(field))
+		if field.Type.Kind() == reflect.Struct {
+			problems = append(problems, readEnv(v.Field(i), path, sources)...)
+			continue
+		}
+		names := []string{EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))}
+		if alias := field.Tag.Get("env"); alias != "" {
+			names = append([]string{alias}, names...)
+		}
+		for _, name := range names {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if err := decodeValue(value, v.Field(i)); err != nil {
+				problems = append(problems, fmt.Errorf("env %s: %w", name, err))
+				continue
+			}
+			sources[path] = "env " + name
+		}
+	}
+	return problems
+}
+
+// assign задаёт значение по пути ключа; в отличие от переменных окружения путь
+// может идти внутрь карт: modes.duel.max_players.
+func assign(v reflect.Value, keys []string, value string) error {
+	if len(keys) == 0 {
+		return decodeValue(value, v)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if yamlName(v.Type().Field(i)) == keys[0] {
+				return assign(v.Field(i), keys[1:], value)
+			}
+		}
+		return fmt.Errorf("unknown key %q", keys[0])
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(keys[0])
+		element := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			element.Set(existing)
+		}
+		if err := assign(element, keys[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, element)
+		return nil
+	}
+	return fmt.Errorf("%q has no nested keys", keys[0])
+}
+
+// decodeValue разбирает значение как YAML, строки берутся как есть: в секретах
+// бывают символы, которые YAML понял бы иначе.
+func decodeValue(value string, target reflect.Value) error {
+	if target.Kind() == reflect.String {
+		target.SetString(value)
+		return nil
+	}
+	if value == "" {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(value))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target.Addr().Interface()); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return errors.New(strings.Join(typeErr.Errors, "; "))
+		}
+		return err
+	}
+	return nil
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Print выводит действующий конфиг по ключу на строку: путь, значение и источник.
+// Значения ключей с token и secret в имени скрыты.
+func Print(w io.Writer, cfg *Config, sources Sources) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	printValue(table, reflect.ValueOf(*cfg), "", sources)
+	return table.Flush()
+}
+
+func printValue(w io.Writer, v reflect.Value, path string, sources Sources) {
+	switch {
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			printValue(w, v.Field(i), joinPath(path, yamlName(v.Type().Field(i))), sources)
+		}
+		return
+	case v.Kind() == reflect.Map && v.Len() > 0:
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			printValue(w, v.MapIndex(reflect.ValueOf(key)), joinPath(path, key), sources)
+		}
+		return
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		for i := 0; i < v.Len(); i++ {
+			printValue(w, v.Index(i), fmt.Sprintf("%s[%d]", path, i), sources)
+		}
+		if v.Len() > 0 {
+			return
+		}
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\n", path, formatValue(path, v), sources.Of(path))
+}
+
+func formatValue(path string, v reflect.Value) string {
+	key := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	if (strings.Contains(key, "token") || strings.Contains(key, "secret")) && !v.IsZero() {
+		return "<hidden>"
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "{}"
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return "[]"
+		}
+		encoded, _ := json.Marshal(v.Interface())
+		return string(encoded)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Reload переносит из next в current то, что можно менять без рестарта: режимы,
+// таймауты и размер сообщения tcp_server, лимиты, бан-лист, уровни логов и список include.
+// Остальные изменившиеся ключи возвращаются в restart: они вступят в силу после рестарта.
+func Reload(current, next *Config) (applied *Config, restart []string) {
+	merged := *current
+	merged.Modes = next.Modes
+	merged.Timeout = next.Timeout
+	merged.IdleTimeout = next.IdleTimeout
+	merged.MaxMessageSize = next.MaxMessageSize
+	merged.RateLimit = next.RateLimit
+	merged.Bans = next.Bans
+	merged.Logging = next.Logging
+	merged.Include = next.Include
+	return &merged, changed("", reflect.ValueOf(merged), reflect.ValueOf(*next))
+}
+
+// changed - ключи YAML, значения которых различаются. Значения не выводятся: среди них есть секреты.
+func changed(prefix string, current, next reflect.Value) []string {
+	if current.Kind() != reflect.Struct {
+		if reflect.DeepEqual(current.Interface(), next.Interface()) {
+			return nil
+		}
+		return []string{prefix}
+	}
+	var keys []string
+	for i := 0; i < current.NumField(); i++ {
+		name, _, _ := strings.Cut(current.Type().Field(i).Tag.Get("yaml"), ",")
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		keys = append(keys, changed(name, current.Field(i), next.Field(i))...)
+	}
+	return keys
+}
+
+// Watch вызывает changed, когда у файла меняется время изменения или размер. Файл
+// проверяется раз в interval; пока его нет (замена через rename), ничего не происходит.
+func Watch(path string, interval time.Duration, stop <-chan struct{}, changed func()) {
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+			changed()
+		}
+	}
+}
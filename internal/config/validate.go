@@ -0,0 +1,202 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// problems - ошибки проверки, каждая с путём ключа в YAML.
+type problems []error
+
+func (p *problems) add(key, format string, args ...any) {
+	*p = append(*p, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (p *problems) atLeast(key string, value, minimum int) {
+	if value < minimum {
+		p.add(key, "must be at least %d, got %d", minimum, value)
+	}
+}
+
+// oneOf - пустое значение допустимо, только если "" есть в allowed; в сообщении его нет.
+func (p *problems) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		named := slices.DeleteFunc(slices.Clone(allowed), func(name string) bool { return name == "" })
+		p.add(key, "must be one of %s, got %q", strings.Join(named, ", "), value)
+	}
+}
+
+func (p *problems) address(key, value string) {
+	if value == "" {
+		return
+	}
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		p.add(key, "must be host:port, got %q", value)
+	}
+}
+
+func (p *problems) url(key, value string) {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		p.add(key, "must be an http or https URL, got %q", value)
+	}
+}
+
+// template ловит синтаксические ошибки шаблона запуска до старта первого сервера.
+func (p *problems) template(key, text string) {
+	if _, err := template.New(key).Option("missingkey=error").Parse(text); err != nil {
+		p.add(key, "invalid template %q: %v", text, err)
+	}
+}
+
+func (p *problems) level(key, value string) {
+	if value == "" {
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		p.add(key, "must be debug, info, warn or error, got %q", value)
+	}
+}
+
+// Validate проверяет значения после подстановки значений по умолчанию и окружения
+// и возвращает все ошибки сразу.
+func (c *Config) Validate() error {
+	var p problems
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		p.add("tcp_server.port", "must be a port number from 1 to 65535, got %q", c.Port)
+	}
+	p.atLeast("tcp_server.timeout", c.Timeout, 0)
+	p.atLeast("tcp_server.idle_timeout", c.IdleTimeout, 0)
+	if wait := RoomFillTimeout + LaunchTimeout; c.IdleTimeout > 0 && c.IdleTimeout <= wait {
+		p.add("tcp_server.idle_timeout", "must be 0 or more than %d seconds of room filling and server launch, got %d", wait, c.IdleTimeout)
+	}
+	p.atLeast("tcp_server.worker_count", c.WorkerCount, 1)
+	p.atLeast("tcp_server.max_message_size", c.MaxMessageSize, 1)
+	p.atLeast("tcp_server.queue.size", c.Queue.Size, 1)
+	p.oneOf("tcp_server.queue.overflow", c.Queue.Overflow, "reject", "block", "spill")
+	switch c.Queue.Overflow {
+	case "block":
+		p.atLeast("tcp_server.queue.block_timeout", c.Queue.BlockTimeout, 1)
+	case "spill":
+		p.atLeast("tcp_server.queue.spill_size", c.Queue.SpillSize, 1)
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		p.add("tcp_server.tls", "cert and key must be set together")
+	}
+	p.oneOf("tcp_server.tls.client_auth", c.TLS.ClientAuth, "", "none", "optional", "require")
+	if c.TLS.ClientAuth != "" && c.TLS.ClientAuth != "none" && c.TLS.ClientCA == "" {
+		p.add("tcp_server.tls.client_ca", "is required when client_auth is %q", c.TLS.ClientAuth)
+	}
+
+	names := make([]string, 0, len(c.Modes))
+	for name := range c.Modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mode := c.Modes[name]
+		key := "modes." + name
+		p.atLeast(key+".max_players", mode.MaxPlayers, 0)
+		p.atLeast(key+".teams", mode.Teams, 0)
+		if mode.MaxPlayers > 0 && mode.Teams > 0 && mode.MaxPlayers%mode.Teams != 0 {
+			p.add(key, "max_players %d must divide evenly into %d teams", mode.MaxPlayers, mode.Teams)
+		}
+	}
+
+	for i, argument := range c.Launch.Arguments {
+		p.template(fmt.Sprintf("launch.arguments[%d]", i), argument)
+	}
+	variables := make([]string, 0, len(c.Launch.Env))
+	for variable := range c.Launch.Env {
+		variables = append(variables, variable)
+	}
+	sort.Strings(variables)
+	for _, variable := range variables {
+		p.template("launch.env."+variable, c.Launch.Env[variable])
+	}
+
+	p.atLeast("logs.max_size_mb", c.Logs.MaxSizeMB, 0)
+	p.atLeast("logs.max_backups", c.Logs.MaxBackups, 0)
+	p.atLeast("logs.retention_hours", c.Logs.RetentionHours, 0)
+	p.address("admin.address", c.Admin.Address)
+	p.address("control.address", c.Control.Address)
+	p.address("metrics.address", c.Metrics.Address)
+
+	p.oneOf("join_tokens.algorithm", c.JoinTokens.Algorithm, "EdDSA", "HS256")
+	p.atLeast("join_tokens.ttl", c.JoinTokens.TTL, 1)
+
+	p.oneOf("auth.method", c.Auth.Method, "", "none", "hmac", "jwt", "callback")
+	switch c.Auth.Method {
+	case "hmac":
+		if c.Auth.Secret == "" {
+			p.add("auth.secret", "is required for hmac")
+		}
+		p.atLeast("auth.max_skew", c.Auth.MaxSkew, 1)
+	case "jwt":
+		if len(c.Auth.PublicKeys) == 0 {
+			p.add("auth.public_keys", "at least one key is required for jwt")
+		}
+	case "callback":
+		p.url("auth.callback_url", c.Auth.CallbackURL)
+		p.atLeast("auth.callback_timeout", c.Auth.CallbackTimeout, 1)
+	}
+
+	if c.RateLimit.PerIP < 0 {
+		p.add("rate_limit.per_ip", "must not be negative, got %v", c.RateLimit.PerIP)
+	}
+	if c.RateLimit.PerClient < 0 {
+		p.add("rate_limit.per_client", "must not be negative, got %v", c.RateLimit.PerClient)
+	}
+	p.atLeast("rate_limit.per_ip_burst", c.RateLimit.PerIPBurst, 0)
+	p.atLeast("rate_limit.per_client_burst", c.RateLimit.PerClientBurst, 0)
+	p.atLeast("rate_limit.max_connections", c.RateLimit.MaxConnections, 0)
+	p.atLeast("rate_limit.max_connections_per_ip", c.RateLimit.MaxConnectionsPerIP, 0)
+	if c.Bans.Path == "" {
+		p.add("bans.path", "is required")
+	}
+
+	p.oneOf("logging.format", c.Logging.Format, "", "json", "text")
+	p.level("logging.level", c.Logging.Level)
+	for component, level := range c.Logging.Components {
+		p.level("logging.components."+component, level)
+	}
+
+	p.oneOf("tracing.exporter", c.Tracing.Exporter, "", "none", "file", "otlp")
+	if c.Tracing.Exporter == "otlp" {
+		p.url("tracing.endpoint", c.Tracing.Endpoint)
+	}
+	p.atLeast("maintenance.deadline", c.Maintenance.Deadline, 1)
+	p.address("maintenance.redirect", c.Maintenance.Redirect)
+
+	p.oneOf("store.driver", c.Store.Driver, "file", "memory")
+	if c.Store.Driver == "file" && c.Store.Path == "" {
+		p.add("store.path", "is required for the file driver")
+	}
+	p.atLeast("store.retention_hours", c.Store.RetentionHours, 0)
+	p.atLeast("history.retention_days", c.History.RetentionDays, 0)
+
+	for i, endpoint := range c.Webhooks.Endpoints {
+		p.url(fmt.Sprintf("webhooks.endpoints[%d].url", i), endpoint.URL)
+		if endpoint.Secret == "" {
+			p.add(fmt.Sprintf("webhooks.endpoints[%d].secret", i), "is required to sign payloads")
+		}
+	}
+	p.atLeast("webhooks.timeout", c.Webhooks.Timeout, 1)
+	p.atLeast("webhooks.max_attempts", c.Webhooks.MaxAttempts, 1)
+	p.atLeast("webhooks.backoff", c.Webhooks.Backoff, 1)
+	if c.Webhooks.MaxBackoff < c.Webhooks.Backoff {
+		p.add("webhooks.max_backoff", "must not be less than backoff %d, got %d", c.Webhooks.Backoff, c.Webhooks.MaxBackoff)
+	}
+
+	return errors.Join(p...)
+}
@@ -0,0 +1,186 @@
+package control
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxBodySize = 1 << 20
+
+var log = logger.For("control")
+
+// GameServers - запущенные процессы. Комната доступна через control API, пока
+// жив её процесс, даже если матч уже закончился и слот освобождён.
+type GameServers interface {
+	FindRoom(uuid string) *room.Room
+	StopGameServer(uuid string) error
+}
+
+// Server - обратный канал от игровых серверов. Сервер получает при запуске
+// SM_CONTROL_URL, SM_ROOM_UUID и SM_ROOM_TOKEN и присылает токен в
+// "Authorization: Bearer <token>".
+type Server struct {
+	servers    GameServers
+	mux        *http.ServeMux
+	httpServer *http.Server
+}
+
+type playerEvent struct {
+	ClientID string `json:"client_id"`
+}
+
+type matchEnd struct {
+	Results json.RawMessage `json:"results"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func New(cfg *config.Config, servers GameServers) *Server {
+	s := &Server{
+		servers: servers,
+		mux:     http.NewServeMux(),
+	}
+	s.httpServer = &http.Server{
+		Addr:              cfg.Control.Address,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.mux.HandleFunc("POST /v1/rooms/{uuid}/ready", s.withRoom(s.ready))
+	s.mux.HandleFunc("POST /v1/rooms/{uuid}/players/join", s.withRoom(s.playerJoined))
+	s.mux.HandleFunc("POST /v1/rooms/{uuid}/players/leave", s.withRoom(s.playerLeft))
+	s.mux.HandleFunc("POST /v1/rooms/{uuid}/match/start", s.withRoom(s.matchStarted))
+	s.mux.HandleFunc("POST /v1/rooms/{uuid}/match/end", s.withRoom(s.matchEnded))
+	s.mux.HandleFunc("POST /v1/rooms/{uuid}/shutdown", s.withRoom(s.shutdown))
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe отказывается слушать не-loopback адрес: токены комнат не должны уходить в сеть.
+func (s *Server) ListenAndServe() error {
+	listener, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Listen занимает адрес control API, чтобы ошибку можно было получить до запуска Serve.
+func (s *Server) Listen() (net.Listener, error) {
+	host, _, err := net.SplitHostPort(s.httpServer.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("control API must listen on loopback, got %s", s.httpServer.Addr)
+	}
+	return net.Listen("tcp", s.httpServer.Addr)
+}
+
+// Serve после Close возвращает http.ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	log.Info("control API is listening", "address", listener.Addr().String())
+	return s.httpServer.Serve(listener)
+}
+
+func (s *Server) Close() error {
+	return s.httpServer.Close()
+}
+
+func (s *Server) withRoom(handler func(http.ResponseWriter, *http.Request, *room.Room)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := s.servers.FindRoom(r.PathValue("uuid"))
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// Несуществующая комната и неверный токен неотличимы для вызывающего
+		if target == nil || subtle.ConstantTimeCompare([]byte(token), []byte(target.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		handler(w, r, target)
+	}
+}
+
+func (s *Server) ready(w http.ResponseWriter, r *http.Request, target *room.Room) {
+	target.MarkReady()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) playerJoined(w http.ResponseWriter, r *http.Request, target *room.Room) {
+	var event playerEvent
+	if err := decode(r, &event); err != nil || event.ClientID == "" {
+		writeError(w, http.StatusBadRequest, errors.New("client_id is required"))
+		return
+	}
+	target.PlayerJoined(event.ClientID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) playerLeft(w http.ResponseWriter, r *http.Request, target *room.Room) {
+	var event playerEvent
+	if err := decode(r, &event); err != nil || event.ClientID == "" {
+		writeError(w, http.StatusBadRequest, errors.New("client_id is required"))
+		return
+	}
+	target.PlayerLeft(event.ClientID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) matchStarted(w http.ResponseWriter, r *http.Request, target *room.Room) {
+	if !target.StartMatch() {
+		writeError(w, http.StatusConflict, fmt.Errorf("room is %s", target.State()))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) matchEnded(w http.ResponseWriter, r *http.Request, target *room.Room) {
+	var event matchEnd
+	if err := decode(r, &event); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !target.End(event.Results) {
+		writeError(w, http.StatusConflict, errors.New("match already ended"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) shutdown(w http.ResponseWriter, r *http.Request, target *room.Room) {
+	if err := s.servers.StopGameServer(target.UUID); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// decode допускает пустое тело: серверу не обязательно присылать результаты.
+func decode(r *http.Request, v any) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Error: err.Error()})
+}
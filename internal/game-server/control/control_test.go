@@ -0,0 +1,121 @@
+package control_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/game-server/control"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+type fakeServers struct {
+	mu      sync.Mutex
+	room    *room.Room
+	stopped []string
+}
+
+func (f *fakeServers) FindRoom(uuid string) *room.Room {
+	if f.room != nil && f.room.UUID == uuid {
+		return f.room
+	}
+	return nil
+}
+
+func (f *fakeServers) StopGameServer(uuid string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = append(f.stopped, uuid)
+	return nil
+}
+
+func newTestServer(t *testing.T) (*fakeServers, *httptest.Server) {
+	r, err := room.New(_type.RoomSettings{ID: 1, MaxPlayers: 8, CurrentMap: "Arena", AppVersion: "v1"})
+	require.NoError(t, err)
+
+	servers := &fakeServers{room: r}
+	srv := httptest.NewServer(control.New(&config.Config{}, servers).Handler())
+	t.Cleanup(srv.Close)
+	return servers, srv
+}
+
+func post(t *testing.T, url, token, body string) int {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestControl_RejectsWrongToken(t *testing.T) {
+	servers, srv := newTestServer(t)
+	base := srv.URL + "/v1/rooms/" + servers.room.UUID
+
+	assert.Equal(t, http.StatusUnauthorized, post(t, base+"/ready", "wrong", ""))
+	assert.Equal(t, http.StatusUnauthorized, post(t, srv.URL+"/v1/rooms/unknown/ready", servers.room.Token, ""))
+	assert.Equal(t, room.StateStarting, servers.room.State())
+}
+
+func TestControl_MatchLifecycle(t *testing.T) {
+	servers, srv := newTestServer(t)
+	r := servers.room
+	base := srv.URL + "/v1/rooms/" + r.UUID
+
+	ended := make(chan struct{})
+	events := bus.New()
+	bus.Subscribe(events, "test", func(room.Ended) { close(ended) })
+	r.Events = events
+
+	require.Equal(t, http.StatusNoContent, post(t, base+"/ready", r.Token, ""))
+	assert.Equal(t, room.StateReady, r.State())
+	select {
+	case <-r.ReadyC():
+	default:
+		t.Fatal("ready channel must be closed")
+	}
+
+	require.Equal(t, http.StatusNoContent, post(t, base+"/players/join", r.Token, `{"client_id":"p1"}`))
+	require.Equal(t, http.StatusNoContent, post(t, base+"/players/join", r.Token, `{"client_id":"p2"}`))
+	require.Equal(t, http.StatusNoContent, post(t, base+"/players/leave", r.Token, `{"client_id":"p1"}`))
+	assert.Equal(t, []string{"p2"}, r.ConnectedPlayers())
+	assert.Equal(t, http.StatusBadRequest, post(t, base+"/players/join", r.Token, `{}`))
+
+	require.Equal(t, http.StatusNoContent, post(t, base+"/match/start", r.Token, ""))
+	assert.Equal(t, room.StateInMatch, r.State())
+
+	require.Equal(t, http.StatusNoContent, post(t, base+"/match/end", r.Token, `{"results":{"winner":"p2"}}`))
+	<-ended
+	assert.Equal(t, room.StateEnded, r.State())
+	assert.JSONEq(t, `{"winner":"p2"}`, string(r.Results()))
+	assert.Equal(t, http.StatusConflict, post(t, base+"/match/end", r.Token, ""))
+
+	require.Equal(t, http.StatusAccepted, post(t, base+"/shutdown", r.Token, ""))
+	assert.Equal(t, []string{r.UUID}, servers.stopped)
+}
+
+func TestControl_ListenReportsErrorsBeforeServe(t *testing.T) {
+	_, err := control.New(&config.Config{Control: config.Control{Address: "0.0.0.0:0"}}, &fakeServers{}).Listen()
+	assert.ErrorContains(t, err, "loopback")
+
+	server := control.New(&config.Config{Control: config.Control{Address: "127.0.0.1:0"}}, &fakeServers{})
+	listener, err := server.Listen()
+	require.NoError(t, err)
+	busy := control.New(&config.Config{Control: config.Control{Address: listener.Addr().String()}}, &fakeServers{})
+	_, err = busy.Listen()
+	assert.Error(t, err, "address in use is reported by Listen")
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+	require.NoError(t, server.Close())
+	assert.ErrorIs(t, <-served, http.ErrServerClosed)
+}
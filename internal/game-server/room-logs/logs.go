@@ -0,0 +1,307 @@
+package room_logs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var log = logger.For("room_logs")
+
+const (
+	GameLog   = "game.log"
+	OutputLog = "output.log"
+
+	archiveDir = "archive"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Manager раскладывает логи серверов по папкам <path>/<room uuid>/ и следит за их сроком жизни.
+type Manager struct {
+	// Active - у комнаты есть запущенный сервер: её логи не удаляются, сколько бы ей ни было
+	Active func(uuid string) bool
+
+	root       string
+	maxSize    int64
+	maxBackups int
+	retention  time.Duration
+}
+
+func New(cfg *config.Config) (*Manager, error) {
+	root, err := filepath.Abs(cfg.Logs.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(root, archiveDir), 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create log directory: %w", err)
+	}
+	return &Manager{
+		root:       root,
+		maxSize:    int64(cfg.Logs.MaxSizeMB) << 20,
+		maxBackups: cfg.Logs.MaxBackups,
+		retention:  time.Duration(cfg.Logs.RetentionHours) * time.Hour,
+	}, nil
+}
+
+func (m *Manager) roomDir(uuid string) (string, error) {
+	if !uuidPattern.MatchString(uuid) {
+		return "", fmt.Errorf("invalid room id %q", uuid)
+	}
+	return filepath.Join(m.root, uuid), nil
+}
+
+// Prepare создаёт папку комнаты и возвращает абсолютный путь для -logFile.
+func (m *Manager) Prepare(uuid string) (string, error) {
+	dir, err := m.roomDir(uuid)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, GameLog), nil
+}
+
+// Output - файл для stdout/stderr процесса с ротацией по размеру.
+func (m *Manager) Output(uuid string) (io.WriteCloser, error) {
+	dir, err := m.roomDir(uuid)
+	if err != nil {
+		return nil, err
+	}
+	return newRotatingWriter(filepath.Join(dir, OutputLog), m.maxSize, m.maxBackups)
+}
+
+// Path возвращает путь к логу комнаты; name - GameLog или OutputLog.
+func (m *Manager) Path(uuid, name string) (string, error) {
+	if name != GameLog && name != OutputLog {
+		return "", fmt.Errorf("unknown log %q", name)
+	}
+	dir, err := m.roomDir(uuid)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func (m *Manager) Open(uuid, name string) (*os.File, error) {
+	path, err := m.Path(uuid, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Tail возвращает последние lines строк лога.
+func (m *Manager) Tail(uuid, name string, lines int) ([]string, error) {
+	file, err := m.Open(uuid, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return tail(file, lines)
+}
+
+// Archive упаковывает логи упавшего сервера в archive/<uuid>.tar.gz и удаляет папку комнаты.
+func (m *Manager) Archive(uuid string) (string, error) {
+	dir, err := m.roomDir(uuid)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(m.root, archiveDir, uuid+".tar.gz")
+
+	tmp, err := os.CreateTemp(filepath.Join(m.root, archiveDir), ".archive-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeArchive(tmp, dir, uuid); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", err
+	}
+	return target, os.RemoveAll(dir)
+}
+
+func (m *Manager) ArchivePath(uuid string) (string, error) {
+	if _, err := m.roomDir(uuid); err != nil {
+		return "", err
+	}
+	return filepath.Join(m.root, archiveDir, uuid+".tar.gz"), nil
+}
+
+// Cleanup удаляет папки комнат и архивы старше срока хранения. Возраст папки считается
+// по самому свежему файлу в ней: дописывание в лог не меняет mtime самой папки.
+func (m *Manager) Cleanup(now time.Time) error {
+	if m.retention <= 0 {
+		return nil
+	}
+	deadline := now.Add(-m.retention)
+
+	var errs []error
+	for _, dir := range []string{m.root, filepath.Join(m.root, archiveDir)} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), ".tar.gz")
+			if !uuidPattern.MatchString(name) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if entry.IsDir() && m.Active != nil && m.Active(name) {
+				continue
+			}
+			modified, err := newestModTime(path)
+			if err != nil || modified.After(deadline) {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Rotate сдвигает в бэкапы game.log, выросшие больше max_size_mb. Этот файл пишет
+// сам сервер, поэтому он копируется и обрезается на месте, а не переименовывается.
+func (m *Manager) Rotate() error {
+	if m.maxSize <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() || !uuidPattern.MatchString(entry.Name()) {
+			continue
+		}
+		if err := copyTruncate(filepath.Join(m.root, entry.Name(), GameLog), m.maxSize, m.maxBackups); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) RunCleanup(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if err := m.Rotate(); err != nil {
+				log.Warn("log rotation failed", logger.Err(err))
+			}
+			if err := m.Cleanup(now); err != nil {
+				log.Warn("log cleanup failed", logger.Err(err))
+			}
+		}
+	}
+}
+
+// newestModTime - mtime файла или самого свежего файла в папке вместе с ней самой.
+func newestModTime(path string) (time.Time, error) {
+	var newest time.Time
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return newest, err
+}
+
+func writeArchive(dst io.Writer, dir, prefix string) error {
+	gz := gzip.NewWriter(dst)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.CopyN(tw, file, header.Size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func tail(file *os.File, lines int) ([]string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunk = 64 << 10
+	size := info.Size()
+	offset := size
+	var data []byte
+	for offset > 0 && strings.Count(string(data), "\n") <= lines {
+		step := int64(chunk)
+		if offset < step {
+			step = offset
+		}
+		offset -= step
+		buf := make([]byte, step)
+		if _, err := file.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(buf, data...)
+	}
+
+	result := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(result) == 1 && result[0] == "" {
+		return []string{}, nil
+	}
+	if len(result) > lines {
+		result = result[len(result)-lines:]
+	}
+	return result, nil
+}
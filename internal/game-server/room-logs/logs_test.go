@@ -0,0 +1,139 @@
+package room_logs_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
+)
+
+const roomUUID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func newManager(t *testing.T, maxSizeMB int) (*room_logs.Manager, string) {
+	root := t.TempDir()
+	m, err := room_logs.New(&config.Config{Logs: config.Logs{Path: root, MaxSizeMB: maxSizeMB, MaxBackups: 2, RetentionHours: 24}})
+	require.NoError(t, err)
+	return m, root
+}
+
+func TestManager_PrepareAndTail(t *testing.T) {
+	m, root := newManager(t, 1)
+
+	path, err := m.Prepare(roomUUID)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, roomUUID, room_logs.GameLog), path)
+
+	_, err = m.Prepare("../../etc")
+	assert.Error(t, err)
+
+	content := ""
+	for i := 1; i <= 100; i++ {
+		content += fmt.Sprintf("line %d\n", i)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	lines, err := m.Tail(roomUUID, room_logs.GameLog, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"line 98", "line 99", "line 100"}, lines)
+
+	lines, err = m.Tail(roomUUID, room_logs.GameLog, 500)
+	require.NoError(t, err)
+	assert.Len(t, lines, 100)
+}
+
+func TestManager_OutputRotation(t *testing.T) {
+	m, root := newManager(t, 1)
+	_, err := m.Prepare(roomUUID)
+	require.NoError(t, err)
+
+	output, err := m.Output(roomUUID)
+	require.NoError(t, err)
+	chunk := make([]byte, 400<<10)
+	for i := 0; i < 10; i++ {
+		_, err := output.Write(chunk)
+		require.NoError(t, err)
+	}
+	require.NoError(t, output.Close())
+
+	dir := filepath.Join(root, roomUUID)
+	assert.FileExists(t, filepath.Join(dir, room_logs.OutputLog+".1"))
+	assert.FileExists(t, filepath.Join(dir, room_logs.OutputLog+".2"))
+	assert.NoFileExists(t, filepath.Join(dir, room_logs.OutputLog+".3"), "only max_backups files are kept")
+
+	info, err := os.Stat(filepath.Join(dir, room_logs.OutputLog))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1<<20))
+}
+
+func TestManager_ArchiveCrashedRoom(t *testing.T) {
+	m, root := newManager(t, 1)
+	path, err := m.Prepare(roomUUID)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("Segmentation fault\n"), 0o644))
+
+	archive, err := m.Archive(roomUUID)
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(root, roomUUID))
+
+	file, err := os.Open(archive)
+	require.NoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	header, err := tar.NewReader(gz).Next()
+	require.NoError(t, err)
+	assert.Equal(t, roomUUID+"/"+room_logs.GameLog, header.Name)
+}
+
+func TestManager_Cleanup(t *testing.T) {
+	m, root := newManager(t, 1)
+	oldUUID := "9c858901-8a57-4791-81fe-4c455b099bc9"
+	for _, id := range []string{roomUUID, oldUUID} {
+		_, err := m.Prepare(id)
+		require.NoError(t, err)
+	}
+	activeUUID := "5b2c8a1e-3f4d-4e6a-9b7c-1d2e3f4a5b6c"
+	writingUUID := "7d9e1f2a-4b5c-4d6e-8f9a-0b1c2d3e4f5a"
+	for _, id := range []string{activeUUID, writingUUID} {
+		path, err := m.Prepare(id)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, []byte("still running\n"), 0o644))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, id := range []string{oldUUID, activeUUID, writingUUID} {
+		require.NoError(t, os.Chtimes(filepath.Join(root, id), old, old))
+	}
+	require.NoError(t, os.Chtimes(filepath.Join(root, activeUUID, room_logs.GameLog), old, old))
+	m.Active = func(uuid string) bool { return uuid == activeUUID }
+
+	require.NoError(t, m.Cleanup(time.Now()))
+	assert.DirExists(t, filepath.Join(root, roomUUID))
+	assert.NoDirExists(t, filepath.Join(root, oldUUID))
+	assert.DirExists(t, filepath.Join(root, activeUUID), "room with a running server keeps its logs")
+	assert.DirExists(t, filepath.Join(root, writingUUID), "age is taken from the newest log, not the directory")
+	assert.DirExists(t, filepath.Join(root, "archive"))
+}
+
+func TestManager_RotateGameLog(t *testing.T) {
+	m, root := newManager(t, 1)
+	path, err := m.Prepare(roomUUID)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, make([]byte, 2<<20), 0o644))
+
+	require.NoError(t, m.Rotate())
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "game.log is truncated in place")
+	backup, err := os.Stat(filepath.Join(root, roomUUID, room_logs.GameLog+".1"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2<<20), backup.Size())
+}
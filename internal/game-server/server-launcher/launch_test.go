@@ -0,0 +1,71 @@
+package server_launcher_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
+	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+// newLauncher - лаунчер с единственной сборкой v1, исполняемый файл которой - script.
+func newLauncher(t *testing.T, script string) *server_launcher.ServerLauncher {
+	cfg := &config.Config{VersionPath: t.TempDir(), ExecutableName: "server.sh", Logs: config.Logs{Path: t.TempDir()}}
+	buildDir := filepath.Join(cfg.VersionPath, "v1")
+	require.NoError(t, os.MkdirAll(buildDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(buildDir, cfg.ExecutableName), []byte("#!/bin/sh\n"+script), 0o755))
+	registry, err := versions.New(cfg)
+	require.NoError(t, err)
+	logs, err := room_logs.New(cfg)
+	require.NoError(t, err)
+	return server_launcher.New(cfg, registry, logs)
+}
+
+func TestLaunch_CancelledWhileStarting(t *testing.T) {
+	// Сервер-заглушка никогда не сообщает о готовности
+	launcher := newLauncher(t, "exec sleep 30\n")
+
+	target, err := room.New(_type.RoomSettings{ID: 1, MaxPlayers: 2, CurrentMap: "Arena", AppVersion: "v1"})
+	require.NoError(t, err)
+	launched := make(chan error, 1)
+	go func() { launched <- launcher.LaunchGameServer(target) }()
+
+	// Отмена может прийти и до, и после старта процесса
+	require.Eventually(t, func() bool {
+		return launcher.StopGameServer(target.UUID) == nil
+	}, time.Second, time.Millisecond)
+
+	select {
+	case err := <-launched:
+		assert.ErrorIs(t, err, server_launcher.ErrCancelled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled launch kept waiting for the server")
+	}
+	assert.Eventually(t, func() bool { return len(launcher.Servers()) == 0 }, time.Second, 10*time.Millisecond, "server of a cancelled room keeps running")
+}
+
+func TestLaunch_ReadyPatternSplitAcrossWrites(t *testing.T) {
+	// Строка готовности приходит двумя кусками между проверками лога
+	launcher := newLauncher(t, "printf 'server started '\nsleep 0.6\nprintf 'on port\\n'\nexec sleep 30\n")
+	target, err := room.New(_type.RoomSettings{ID: 1, MaxPlayers: 2, CurrentMap: "Arena", AppVersion: "v1"})
+	require.NoError(t, err)
+	defer launcher.StopAll()
+
+	launched := make(chan error, 1)
+	go func() { launched <- launcher.LaunchGameServer(target) }()
+	select {
+	case err := <-launched:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ready pattern was not found")
+	}
+}
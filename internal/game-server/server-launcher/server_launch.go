@@ -1,87 +1,221 @@
 package server_launcher
 
 import (
+	"errors"
 	"fmt"
+	"github.com/Tagakama/ServerManager/internal/bus"
 	"github.com/Tagakama/ServerManager/internal/config"
+	room_logs "github.com/Tagakama/ServerManager/internal/game-server/room-logs"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+	"io"
 	"net"
 	"os"
 	"os/exec"
-	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
+// Время между SIGTERM и SIGKILL при остановке сервера.
+const stopGracePeriod = 10 * time.Second
+
+var log = logger.For("launcher")
+
+// Исходы запуска для sm_launch_duration_seconds.
+const (
+	OutcomeReady   = "ready"
+	OutcomeNoBuild = "no_build"
+	OutcomeError   = "error"
+	OutcomeExited  = "exited"
+	OutcomeTimeout = "timeout"
+	// OutcomeCancelled - комнату отменили, пока сервер запускался
+	OutcomeCancelled = "cancelled"
+)
+
+var (
+	launchDuration   = metrics.Default.Histogram("sm_launch_duration_seconds", "Time from launch request to a ready or failed game server, per outcome.", metrics.DurationBuckets, "version", "outcome")
+	runningProcesses = metrics.Default.Gauge("sm_game_servers_running", "Running game server processes.")
+	serverCrashes    = metrics.Default.Counter("sm_game_server_crashes_total", "Game server processes that exited with an error.", "version")
+)
+
+// Launcher запускает сервер комнаты и ждёт его готовности. Ошибка отдаётся игрокам,
+// поэтому это *versions.Error или *_type.Error с кодом launch_failed.
 type Launcher interface {
-	LaunchGameServer(settings *room.Room) bool
+	LaunchGameServer(settings *room.Room) error
+}
+
+// JoinKeyProvider - ключ проверки токенов входа, который получает игровой сервер.
+type JoinKeyProvider interface {
+	Algorithm() string
+	VerificationKey() string
 }
 
 type ServerLauncher struct {
-	versionPath string
-	execName    string
+	JoinKeys JoinKeyProvider
+	// Events получает запуск и выход процессов: по журналу назначений после рестарта
+	// ищутся осиротевшие процессы
+	Events bus.Publisher
+
+	versions   *versions.Registry
+	logs       *room_logs.Manager
+	launch     config.Launch
+	controlURL string
+
+	mu        sync.Mutex
+	processes map[string]*process
+	// launching - комнаты, сервер которых ещё запускается; true - запуск отменён
+	launching map[string]bool
 }
 
-func New(cfg *config.Config) *ServerLauncher {
+type process struct {
+	cmd      *exec.Cmd
+	room     *room.Room
+	port     int
+	version  string
+	started  time.Time
+	draining bool
+}
+
+// ErrNotRunning - у комнаты нет запущенного процесса.
+var ErrNotRunning = errors.New("no running server for room")
+
+// ErrCancelled - комнату отменили во время запуска; её игрокам уже ответили.
+var ErrCancelled = errors.New("room was cancelled while its server was starting")
+
+func New(cfg *config.Config, registry *versions.Registry, logs *room_logs.Manager) *ServerLauncher {
 	return &ServerLauncher{
-		versionPath: cfg.VersionPath,
-		execName:    cfg.ExecutableName,
+		versions:   registry,
+		logs:       logs,
+		launch:     cfg.Launch,
+		controlURL: "http://" + cfg.Control.Address,
+		processes:  make(map[string]*process),
+		launching:  make(map[string]bool),
 	}
 }
 
-func (s *ServerLauncher) LaunchGameServer(settings *room.Room) bool {
-	port, tcpListener, err := FindFreePort()
+func (s *ServerLauncher) LaunchGameServer(settings *room.Room) error {
+	started := time.Now()
+	outcome := OutcomeError
+	span := settings.Trace.Child("LaunchGameServer")
+	span.SetAttr("room_uuid", settings.UUID)
+	span.SetAttr("version", settings.AppVersion)
+	defer func() {
+		launchDuration.Observe(time.Since(started).Seconds(), settings.AppVersion, outcome)
+		span.SetAttr("outcome", outcome)
+		if outcome != OutcomeReady {
+			span.SetError(errors.New(outcome))
+		}
+		span.End()
+	}()
+
+	s.mu.Lock()
+	s.launching[settings.UUID] = false
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.launching, settings.UUID)
+		s.mu.Unlock()
+	}()
+
+	build, err := s.versions.Resolve(settings.AppVersion, settings.CurrentMap)
 	if err != nil {
-		panic(err)
+		outcome = OutcomeNoBuild
+		log.Warn("no build for room", logger.RoomID(settings.ID), logger.RoomUUID(settings.UUID), logger.Err(err))
+		return err
 	}
 
-	logFilePath := fmt.Sprintf("Logs/Room_%d.log", settings.ID)
-	unicName := fmt.Sprintf("%s%s%d", settings.AppVersion, settings.CurrentMap, settings.ID)
-	cmd := exec.Command(s.versionPath+settings.AppVersion+s.execName,
-		"-nographics", "-dedicatedServer", "-batchmode", "-fps", "60", "-dfill", "-UserID", unicName,
-		"-sessionName", unicName, "-logFile", logFilePath,
-		"-port", strconv.Itoa(port), "-region eu",
-		"-serverName", unicName, "-scene", settings.CurrentMap)
+	port, tcpListener, err := FindFreePort()
+	if err != nil {
+		return launchFailed(settings, err)
+	}
 
 	tcpListener.Close()
 
+	logFilePath, err := s.logs.Prepare(settings.UUID)
+	if err != nil {
+		return launchFailed(settings, err)
+	}
+	output, err := s.logs.Output(settings.UUID)
+	if err != nil {
+		return launchFailed(settings, err)
+	}
+	outputPath, _ := s.logs.Path(settings.UUID, room_logs.OutputLog)
+
+	vars := s.launchVars(settings, port, logFilePath)
+	vars.Traceparent = span.Traceparent()
+	cmd, err := s.command(build, vars)
+	if err != nil {
+		output.Close()
+		return launchFailed(settings, err)
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if s.cancelled(settings.UUID) {
+		output.Close()
+		outcome = OutcomeCancelled
+		return ErrCancelled
+	}
 	// Запускаем процесс
 	err = cmd.Start()
 	if err != nil {
-		fmt.Printf("failed to start server %d: %v\n", settings.ID, err)
-		return false
+		output.Close()
+		return launchFailed(settings, err)
+	}
+	s.mu.Lock()
+	s.processes[settings.UUID] = &process{cmd: cmd, room: settings, port: port, version: build.Version, started: time.Now()}
+	cancelled := s.launching[settings.UUID]
+	s.mu.Unlock()
+	runningProcesses.Inc()
+	s.publish(events.ServerAssigned{
+		Room:       settings,
+		PID:        cmd.Process.Pid,
+		Port:       port,
+		Version:    build.Version,
+		Executable: build.ExecutablePath(),
+	})
+
+	processLog := log.With(logger.RoomID(settings.ID), logger.RoomUUID(settings.UUID), logger.PID(cmd.Process.Pid))
+	processLog.Info("game server process started", "version", build.Version, "port", port, "session", settings.SessionName)
+	// Отмена пришла, пока процесс стартовал: он завершится, и запуск вернёт ErrCancelled
+	if cancelled {
+		processLog.Info("room was cancelled during launch, stopping server")
+		s.StopGameServer(settings.UUID)
 	}
 
 	// Создаем каналы для синхронизации
 	serverStarted := make(chan bool, 1)
-	serverFailed := make(chan error, 1)
+	serverFailed := make(chan error, 2)
+	// Таймаут запуска один - в select ниже; опрос логов заканчивается вместе с запуском
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
 
 	go func() {
-		timeout := time.After(30 * time.Second)
 		ticker := time.NewTicker(500 * time.Millisecond)
 		defer ticker.Stop()
+		// Ищем признаки успешного запуска сервера в логе игры и в stdout
+		scanner := newLogScanner(build.ReadyPattern, logFilePath, outputPath)
 
 		for {
 			select {
-			case <-timeout:
-				serverFailed <- fmt.Errorf("timeout waiting for server start in log file")
+			case <-stopWatching:
 				return
 			case <-ticker.C:
-				// Читаем и анализируем лог-файл
-				content, err := os.ReadFile(logFilePath)
-				if err != nil {
-					// Файл может еще не существовать, это нормально
-					continue
-				}
-
-				logContent := string(content)
-
-				// Ищем признаки успешного запуска сервера
-				if strings.Contains(logContent, "started on") {
-					fmt.Printf("Server %d successfully started (found in log)\n", settings.ID)
+				if scanner.found() {
+					processLog.Debug("ready pattern found in log")
+					settings.MarkReady()
 					serverStarted <- true
 					return
 				}
-
+			case <-settings.ReadyC():
+				// сервер сам сообщил о готовности через control API
+				return
 			}
 		}
 	}()
@@ -89,30 +223,255 @@ func (s *ServerLauncher) LaunchGameServer(settings *room.Room) bool {
 	// Горутина для ожидания завершения процесса
 	go func() {
 		err := cmd.Wait()
-		if err != nil {
-			serverFailed <- fmt.Errorf("server process exited with error: %v \n", err)
+		output.Close()
+		s.mu.Lock()
+		delete(s.processes, settings.UUID)
+		s.mu.Unlock()
+		runningProcesses.Dec()
+		// Запуск узнаёт о выходе раньше, чем комната завершится: её подписчики не должны
+		// ждать, пока LaunchGameServer досидит до таймаута. Для уже готового сервера
+		// сообщение никто не читает, канал буферизован
+		if err == nil {
+			serverFailed <- fmt.Errorf("server process exited")
+		} else {
+			serverFailed <- fmt.Errorf("server process exited with error: %v", err)
+		}
+		// До Exited: room.Ended убирает комнату, и выход сервера должен оказаться в журнале раньше
+		s.publish(events.ServerExited{Room: settings, PID: cmd.Process.Pid, Err: err})
+		settings.Exited(err)
+		if err == nil {
+			return
+		}
+		serverCrashes.Inc(build.Version)
+		archive, archiveErr := s.logs.Archive(settings.UUID)
+		if archiveErr != nil {
+			processLog.Error("failed to archive logs", logger.Err(archiveErr))
+			return
 		}
+		processLog.Warn("game server crashed, logs archived", "archive", archive, logger.Err(err))
 	}()
 
 	// Ожидаем либо успешного запуска, либо ошибки, либо таймаут
 	select {
 	case <-serverStarted:
-		fmt.Printf("Server %s started successfully, continuing...\n", unicName)
-		return true
+		processLog.Info("game server ready")
+		outcome = OutcomeReady
+		return nil
+
+	case <-settings.ReadyC():
+		processLog.Info("game server reported ready")
+		outcome = OutcomeReady
+		return nil
 
 	case err := <-serverFailed:
-		fmt.Printf("server failed to start: %v \n", err)
-		return false
+		// Сервер, не дошедший до готовности, игрокам не отдаём и не оставляем висеть
+		s.StopGameServer(settings.UUID)
+		if s.cancelled(settings.UUID) {
+			outcome = OutcomeCancelled
+			return ErrCancelled
+		}
+		outcome = OutcomeExited
+		return launchFailed(settings, err)
 
-	case <-time.After(30 * time.Second): // Таймаут 30 секунд
-		fmt.Printf("server startup timed out after 30 seconds\n")
-		return false
+	case <-time.After(config.LaunchTimeout * time.Second):
+		s.StopGameServer(settings.UUID)
+		outcome = OutcomeTimeout
+		return launchFailed(settings, fmt.Errorf("startup timed out after %d seconds", config.LaunchTimeout))
 	}
 
 	// Теперь метод будет ждать, пока сервер не запустится
 	// Дальнейший код выполнится только после успешного запуска сервера
 }
 
+func launchFailed(settings *room.Room, err error) error {
+	log.Error("failed to start game server", logger.RoomID(settings.ID), logger.RoomUUID(settings.UUID), logger.Err(err))
+	return _type.NewError(_type.CodeLaunchFailed, true, "game server failed to start")
+}
+
+func (s *ServerLauncher) FindRoom(uuid string) *room.Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if running, ok := s.processes[uuid]; ok {
+		return running.room
+	}
+	return nil
+}
+
+// StopGameServer просит сервер завершиться и добивает его, если он не успел за stopGracePeriod.
+// Сервер, который ещё запускается, будет остановлен сразу после старта.
+func (s *ServerLauncher) StopGameServer(uuid string) error {
+	s.mu.Lock()
+	running, ok := s.processes[uuid]
+	_, launching := s.launching[uuid]
+	if launching {
+		s.launching[uuid] = true
+	}
+	s.mu.Unlock()
+	if !ok && launching {
+		log.Info("game server is still starting, it will be stopped once started", logger.RoomUUID(uuid))
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("%w %s", ErrNotRunning, uuid)
+	}
+	cmd := running.cmd
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return cmd.Process.Kill()
+	}
+	go func() {
+		time.Sleep(stopGracePeriod)
+		s.mu.Lock()
+		current, stillRunning := s.processes[uuid]
+		s.mu.Unlock()
+		if stillRunning && current == running {
+			cmd.Process.Kill()
+		}
+	}()
+	return nil
+}
+
+func (s *ServerLauncher) cancelled(uuid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.launching[uuid]
+}
+
+func (s *ServerLauncher) launchVars(settings *room.Room, port int, logFile string) LaunchVars {
+	teamSize := settings.MaxPlayers
+	if settings.Teams > 0 {
+		teamSize = settings.MaxPlayers / settings.Teams
+	}
+	vars := LaunchVars{
+		RoomID:      settings.ID,
+		SessionName: settings.SessionName,
+		Version:     settings.AppVersion,
+		Port:        port,
+		Map:         settings.CurrentMap,
+		Mode:        settings.Mode,
+		Region:      s.launch.Region,
+		MaxPlayers:  settings.MaxPlayers,
+		Teams:       settings.Teams,
+		TeamSize:    teamSize,
+		TeamLayout:  fmt.Sprintf("%dx%d", settings.Teams, teamSize),
+		AuthToken:   settings.Token,
+		LogFile:     logFile,
+		RoomUUID:    settings.UUID,
+		ControlURL:  s.controlURL,
+	}
+	if s.JoinKeys != nil {
+		vars.JoinAlg = s.JoinKeys.Algorithm()
+		vars.JoinKey = s.JoinKeys.VerificationKey()
+	}
+	return vars
+}
+
+// command собирает exec.Cmd: шаблоны манифеста сборки важнее шаблонов из конфига.
+func (s *ServerLauncher) command(build *versions.Build, vars LaunchVars) (*exec.Cmd, error) {
+	argTemplates := build.Arguments
+	if len(argTemplates) == 0 {
+		argTemplates = s.launch.Arguments
+	}
+	if len(argTemplates) == 0 {
+		argTemplates = DefaultArguments
+	}
+	args, err := RenderArgs(argTemplates, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	envTemplates := make(map[string]string, len(s.launch.Env)+len(build.Env))
+	for key, value := range s.launch.Env {
+		envTemplates[key] = value
+	}
+	for key, value := range build.Env {
+		envTemplates[key] = value
+	}
+	env, err := RenderEnv(envTemplates, vars)
+	if err != nil {
+		return nil, err
+	}
+	// Данные для обратного канала и проверки токенов входа сервер получает всегда, независимо от шаблонов.
+	env = append(env,
+		"SM_CONTROL_URL="+vars.ControlURL,
+		"SM_ROOM_UUID="+vars.RoomUUID,
+		"SM_ROOM_TOKEN="+vars.AuthToken,
+		"SM_JOIN_ALG="+vars.JoinAlg,
+		"SM_JOIN_KEY="+vars.JoinKey,
+		"SM_TRACEPARENT="+vars.Traceparent)
+	// Контекст трассировки передаётся и аргументом, только если трассировка включена
+	if vars.Traceparent != "" {
+		args = append(args, "-traceparent", vars.Traceparent)
+	}
+
+	cmd := exec.Command(build.ExecutablePath(), args...)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd, nil
+}
+
+// logScanner ищет строку в логах запускающегося сервера, читая только байты,
+// дописанные с прошлой проверки.
+type logScanner struct {
+	pattern string
+	paths   []string
+	offsets map[string]int64
+	// tails - конец прошлого чтения: строка может прийти на границе двух проверок
+	tails map[string]string
+}
+
+func newLogScanner(pattern string, paths ...string) *logScanner {
+	return &logScanner{
+		pattern: pattern,
+		paths:   paths,
+		offsets: make(map[string]int64),
+		tails:   make(map[string]string),
+	}
+}
+
+func (l *logScanner) found() bool {
+	for _, path := range l.paths {
+		if l.scan(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *logScanner) scan(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		// Файл может еще не существовать, это нормально
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	offset := l.offsets[path]
+	// Файл обрезан ротацией - читаем его заново
+	if info.Size() < offset {
+		offset = 0
+		l.tails[path] = ""
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return false
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return false
+	}
+	l.offsets[path] = offset + int64(len(content))
+
+	text := l.tails[path] + string(content)
+	if strings.Contains(text, l.pattern) {
+		return true
+	}
+	keep := min(max(len(l.pattern)-1, 0), len(text))
+	l.tails[path] = text[len(text)-keep:]
+	return false
+}
+
 func FindFreePort() (int, *net.TCPListener, error) {
 	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -124,3 +483,9 @@ func FindFreePort() (int, *net.TCPListener, error) {
 	}
 	return listener.Addr().(*net.TCPAddr).Port, listener, nil
 }
+
+func (s *ServerLauncher) publish(event bus.Event) {
+	if s.Events != nil {
+		s.Events.Publish(event)
+	}
+}
@@ -0,0 +1,151 @@
+package server_launcher
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ServerInfo - запущенный процесс игрового сервера для admin API.
+type ServerInfo struct {
+	RoomUUID      string    `json:"room_uuid"`
+	RoomID        int       `json:"room_id"`
+	PID           int       `json:"pid"`
+	Port          int       `json:"port"`
+	Version       string    `json:"version"`
+	State         string    `json:"state"`
+	Draining      bool      `json:"draining"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+}
+
+func (s *ServerLauncher) Servers() []ServerInfo {
+	now := time.Now()
+	s.mu.Lock()
+	servers := make([]ServerInfo, 0, len(s.processes))
+	rooms := make([]*room.Room, 0, len(s.processes))
+	for _, p := range s.processes {
+		servers = append(servers, ServerInfo{
+			RoomUUID:      p.room.UUID,
+			RoomID:        p.room.ID,
+			PID:           p.cmd.Process.Pid,
+			Port:          p.port,
+			Version:       p.version,
+			Draining:      p.draining,
+			StartedAt:     p.started,
+			UptimeSeconds: now.Sub(p.started).Seconds(),
+		})
+		rooms = append(rooms, p.room)
+	}
+	s.mu.Unlock()
+
+	// Состояние читаем без s.mu: у комнаты свой мьютекс
+	for i, target := range rooms {
+		servers[i].State = target.State()
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].StartedAt.Before(servers[j].StartedAt) })
+	return servers
+}
+
+// Launching - сколько серверов ещё запускается и не попало в Servers.
+func (s *ServerLauncher) Launching() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for uuid := range s.launching {
+		if _, started := s.processes[uuid]; !started {
+			count++
+		}
+	}
+	return count
+}
+
+// KillGameServer завершает процесс сразу, без SIGTERM.
+func (s *ServerLauncher) KillGameServer(uuid string) error {
+	s.mu.Lock()
+	running, ok := s.processes[uuid]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %s", ErrNotRunning, uuid)
+	}
+	log.Warn("killing game server", logger.RoomUUID(uuid), logger.PID(running.cmd.Process.Pid))
+	return running.cmd.Process.Kill()
+}
+
+// DrainGameServer даёт доиграть текущий матч и останавливает сервер после его конца.
+// Новых игроков в комнату к этому моменту уже не берут: набор закрывает матчмейкер.
+func (s *ServerLauncher) DrainGameServer(uuid string) error {
+	s.mu.Lock()
+	running, ok := s.processes[uuid]
+	if ok {
+		if running.draining {
+			s.mu.Unlock()
+			return nil
+		}
+		running.draining = true
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %s", ErrNotRunning, uuid)
+	}
+
+	log.Info("draining game server", logger.RoomUUID(uuid), logger.PID(running.cmd.Process.Pid))
+	go func() {
+		<-running.room.Done()
+		if err := s.StopGameServer(uuid); err != nil {
+			log.Debug("drained server already stopped", logger.RoomUUID(uuid), logger.Err(err))
+		}
+	}()
+	return nil
+}
+
+// StopAll останавливает все серверы при выходе менеджера: SIGTERM, а тем, кто
+// не завершился за stopGracePeriod, - SIGKILL.
+func (s *ServerLauncher) StopAll() {
+	servers := s.Servers()
+	if len(servers) == 0 {
+		return
+	}
+	log.Warn("stopping all game servers", "servers", len(servers))
+	for _, server := range servers {
+		s.StopGameServer(server.RoomUUID)
+	}
+	deadline := time.Now().Add(stopGracePeriod)
+	for len(s.Servers()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	for _, server := range s.Servers() {
+		s.KillGameServer(server.RoomUUID)
+	}
+}
+
+// ErrNotOurProcess - PID уже занят чужим процессом, останавливать его нельзя.
+var ErrNotOurProcess = errors.New("process is not a game server")
+
+// StopOrphan останавливает сервер, запущенный прошлым экземпляром менеджера. PID мог
+// достаться другому процессу, поэтому сначала сверяется исполняемый файл.
+func (s *ServerLauncher) StopOrphan(pid int, executable string) error {
+	running, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: pid %d", ErrNotRunning, pid)
+		}
+		return err
+	}
+	expected, err := filepath.Abs(executable)
+	if err != nil {
+		return err
+	}
+	if filepath.Clean(running) != expected {
+		return fmt.Errorf("%w: pid %d runs %s", ErrNotOurProcess, pid, running)
+	}
+	log.Warn("stopping orphaned game server", logger.PID(pid), "executable", executable)
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,28 @@
+package server_launcher
+
+import (
+	"errors"
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+)
+
+// Subscribe запускает сервер для каждой новой комнаты. Запуски разных комнат
+// идут параллельно; ошибка запуска публикуется как events.LaunchFailed.
+func Subscribe(b *bus.Bus, launcher Launcher) {
+	bus.Subscribe(b, "launcher", func(e events.RoomCreated) {
+		err := launcher.LaunchGameServer(e.Room)
+		// Отменённой комнате матчмейкер уже ответил, повторный отказ её игрокам не нужен
+		if errors.Is(err, ErrCancelled) {
+			log.Info("launch stopped, room was cancelled", logger.RoomID(e.Room.ID), logger.RoomUUID(e.Room.UUID))
+			return
+		}
+		if err != nil {
+			log.Error("game server did not start", logger.RoomID(e.Room.ID), logger.RoomUUID(e.Room.UUID), logger.Err(err))
+			b.Publish(events.LaunchFailed{Room: e.Room, Err: err})
+			return
+		}
+		// Лаунчер мог вернуться раньше, чем сервер сообщил о готовности
+		e.Room.MarkReady()
+	})
+}
@@ -0,0 +1,85 @@
+package server_launcher
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+)
+
+// DefaultArguments используются, если ни конфиг, ни манифест сборки не задают свои.
+var DefaultArguments = []string{
+	"-nographics", "-dedicatedServer", "-batchmode", "-fps", "60", "-dfill",
+	"-UserID", "{{.SessionName}}",
+	"-sessionName", "{{.SessionName}}",
+	"-logFile", "{{.LogFile}}",
+	"-port", "{{.Port}}",
+	"-region", "{{.Region}}",
+	"-serverName", "{{.SessionName}}",
+	"-scene", "{{.Map}}",
+}
+
+// LaunchVars - переменные, доступные в шаблонах аргументов и окружения.
+type LaunchVars struct {
+	RoomID      int
+	SessionName string
+	Version     string
+	Port        int
+	Map         string
+	Mode        string
+	Region      string
+	MaxPlayers  int
+	Teams       int
+	TeamSize    int
+	TeamLayout  string
+	AuthToken   string
+	LogFile     string
+	RoomUUID    string
+	ControlURL  string
+	JoinAlg     string
+	JoinKey     string
+	Traceparent string
+}
+
+// RenderArgs рендерит каждый элемент отдельно, поэтому значение с пробелами остаётся одним argv.
+func RenderArgs(templates []string, vars LaunchVars) ([]string, error) {
+	args := make([]string, 0, len(templates))
+	for _, text := range templates {
+		arg, err := render(text, vars)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func RenderEnv(templates map[string]string, vars LaunchVars) ([]string, error) {
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(templates))
+	for _, key := range keys {
+		value, err := render(templates[key], vars)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, nil
+}
+
+func render(text string, vars LaunchVars) (string, error) {
+	tmpl, err := template.New("arg").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid launch template %q: %w", text, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("invalid launch template %q: %w", text, err)
+	}
+	return buf.String(), nil
+}
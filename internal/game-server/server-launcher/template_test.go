@@ -0,0 +1,58 @@
+package server_launcher_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+)
+
+func testVars() server_launcher.LaunchVars {
+	return server_launcher.LaunchVars{
+		RoomID:      7,
+		SessionName: "v1.0Arena7",
+		Version:     "v1.0",
+		Port:        7777,
+		Map:         "Arena",
+		Mode:        "duo",
+		Region:      "eu west",
+		MaxPlayers:  8,
+		Teams:       4,
+		TeamSize:    2,
+		TeamLayout:  "4x2",
+		AuthToken:   "secret",
+		LogFile:     "Logs/Room_7.log",
+	}
+}
+
+func TestRenderArgs_Defaults(t *testing.T) {
+	args, err := server_launcher.RenderArgs(server_launcher.DefaultArguments, testVars())
+	require.NoError(t, err)
+
+	assert.Contains(t, args, "eu west", "region must stay a single argv element")
+	assert.Equal(t, []string{"-port", "7777"}, args[12:14])
+	assert.Equal(t, "Arena", args[len(args)-1])
+}
+
+func TestRenderArgs_CustomTemplates(t *testing.T) {
+	args, err := server_launcher.RenderArgs([]string{"-room={{.RoomID}}", "-teams", "{{.TeamLayout}}", "-mode", "{{.Mode}}", "-token", "{{.AuthToken}}"}, testVars())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-room=7", "-teams", "4x2", "-mode", "duo", "-token", "secret"}, args)
+
+	_, err = server_launcher.RenderArgs([]string{"{{.Unknown}}"}, testVars())
+	assert.Error(t, err)
+
+	_, err = server_launcher.RenderArgs([]string{"{{.Port"}, testVars())
+	assert.Error(t, err)
+}
+
+func TestRenderEnv(t *testing.T) {
+	env, err := server_launcher.RenderEnv(map[string]string{
+		"SM_TOKEN": "{{.AuthToken}}",
+		"SM_PORT":  "{{.Port}}",
+	}, testVars())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SM_PORT=7777", "SM_TOKEN=secret"}, env)
+}
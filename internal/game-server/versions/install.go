@@ -0,0 +1,252 @@
+package versions
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type InstallRequest struct {
+	Version string
+	// Source - локальный путь или http(s) URL архива .zip / .tar.gz / .tgz
+	Source string
+	SHA256 string
+}
+
+// Install скачивает архив, сверяет контрольную сумму и распаковывает его во
+// временную папку внутри VersionPath. В реестр сборка попадает только после
+// атомарного rename, так что наполовину распакованная версия никогда не видна.
+func (r *Registry) Install(ctx context.Context, req InstallRequest) (*Build, error) {
+	if err := validateVersionName(req.Version); err != nil {
+		return nil, err
+	}
+	if req.SHA256 == "" {
+		return nil, errors.New("checksum is required")
+	}
+	if _, ok := r.Get(req.Version); ok {
+		return nil, fmt.Errorf("version %q is already installed", req.Version)
+	}
+
+	archive, err := os.CreateTemp(r.root, ".download-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	sum, err := fetch(ctx, req.Source, archive)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch %s: %w", req.Source, err)
+	}
+	if !strings.EqualFold(sum, req.SHA256) {
+		return nil, fmt.Errorf("checksum mismatch: expected %s, got %s", req.SHA256, sum)
+	}
+
+	tmpDir, err := os.MkdirTemp(r.root, ".install-"+req.Version+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := unpack(archive, req.Source, tmpDir); err != nil {
+		return nil, fmt.Errorf("cannot unpack %s: %w", req.Source, err)
+	}
+	buildDir := singleTopDir(tmpDir)
+	if _, err := r.readBuild(req.Version, buildDir); err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(r.root, req.Version)
+	if err := os.Rename(buildDir, target); err != nil {
+		return nil, err
+	}
+	build, err := r.readBuild(req.Version, target)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if status, ok := r.rollout.Statuses[build.Version]; ok {
+		build.Status = status
+	}
+	r.builds[build.Version] = build
+	r.mu.Unlock()
+
+	log.Info("build installed", "version", build.Version, "source", req.Source)
+	return build, nil
+}
+
+func validateVersionName(version string) error {
+	if version == "" || version == "." || version == ".." || strings.HasPrefix(version, ".") ||
+		strings.ContainsAny(version, `/\:`) {
+		return fmt.Errorf("invalid version name %q", version)
+	}
+	return nil
+}
+
+func fetch(ctx context.Context, source string, dst io.Writer) (string, error) {
+	var body io.Reader
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		body = resp.Body
+	} else {
+		file, err := os.Open(source)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		body = file
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, hash), body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func unpack(archive *os.File, source, dst string) error {
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	name := strings.ToLower(source)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		info, err := archive.Stat()
+		if err != nil {
+			return err
+		}
+		return unpackZip(archive, info.Size(), dst)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return unpackTarGz(archive, dst)
+	default:
+		return errors.New("unsupported archive format, expected .zip or .tar.gz")
+	}
+}
+
+func unpackZip(archive io.ReaderAt, size int64, dst string) error {
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		path, err := safeJoin(dst, file.Name)
+		if err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		src, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(path, src, file.Mode())
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unpackTarGz(archive io.Reader, dst string) error {
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Глобальный pax-заголовок (git archive, GNU tar) - метаданные архива, а не файл
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		path, err := safeJoin(dst, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(path, reader, header.FileInfo().Mode()); err != nil {
+				return err
+			}
+		default:
+			// ссылки и устройства в сборках не нужны и небезопасны
+			return fmt.Errorf("unsupported entry %s in archive", header.Name)
+		}
+	}
+}
+
+func safeJoin(root, name string) (string, error) {
+	path := filepath.Join(root, name)
+	if path != root && !strings.HasPrefix(path, root+string(os.PathSeparator)) {
+		return "", fmt.Errorf("entry %s escapes the build directory", name)
+	}
+	return path, nil
+}
+
+func writeFile(path string, src io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, src); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// singleTopDir - архивы часто упакованы с одной корневой папкой, её содержимое и есть сборка.
+func singleTopDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
@@ -0,0 +1,107 @@
+package versions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const RolloutName = "rollout.json"
+
+// rolloutState хранит решения оператора поверх манифестов, чтобы они переживали рестарт.
+type rolloutState struct {
+	Default  string            `json:"default,omitempty"`
+	Statuses map[string]string `json:"statuses,omitempty"`
+}
+
+func readRollout(root string) (rolloutState, error) {
+	state := rolloutState{Statuses: make(map[string]string)}
+
+	content, err := os.ReadFile(filepath.Join(root, RolloutName))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return state, fmt.Errorf("invalid %s: %w", RolloutName, err)
+	}
+	if state.Statuses == nil {
+		state.Statuses = make(map[string]string)
+	}
+	return state, nil
+}
+
+func (r *Registry) saveRolloutLocked() error {
+	content, err := json.MarshalIndent(r.rollout, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(r.root, "."+RolloutName+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(r.root, RolloutName))
+}
+
+func (r *Registry) Default() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rollout.Default
+}
+
+// SetDefault - версией по умолчанию может быть только активная сборка.
+func (r *Registry) SetDefault(version string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	build, ok := r.builds[version]
+	if !ok {
+		return &Error{Code: CodeUnknownVersion, Version: version}
+	}
+	if build.Status != StatusActive {
+		return fmt.Errorf("version %q is %s and cannot be default", version, build.Status)
+	}
+	r.rollout.Default = version
+	return r.saveRolloutLocked()
+}
+
+// SetStatus меняет статус сборки. Сборки не мутируются на месте: лаунчер
+// может держать указатель на старую копию, пока запускает сервер.
+func (r *Registry) SetStatus(version, status string) error {
+	switch status {
+	case StatusActive, StatusDeprecated, StatusDraining, StatusRetired:
+	default:
+		return fmt.Errorf("unknown status %q", status)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	build, ok := r.builds[version]
+	if !ok {
+		return &Error{Code: CodeUnknownVersion, Version: version}
+	}
+	updated := *build
+	updated.Status = status
+	r.builds[version] = &updated
+
+	r.rollout.Statuses[version] = status
+	if status != StatusActive && r.rollout.Default == version {
+		r.rollout.Default = ""
+	}
+	return r.saveRolloutLocked()
+}
@@ -0,0 +1,243 @@
+package versions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"github.com/ilyakaznacheev/cleanenv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const ManifestName = "manifest.yaml"
+
+var log = logger.For("versions")
+
+// Статусы сборки. deprecated - новые комнаты создаются, но игрок получает
+// предупреждение, и версия не может быть версией по умолчанию.
+const (
+	StatusActive     = "active"
+	StatusDeprecated = "deprecated"
+	StatusDraining   = "draining"
+	StatusRetired    = "retired"
+)
+
+const (
+	CodeUnknownVersion  = "unknown_version"
+	CodeRetiredVersion  = "version_retired"
+	CodeDrainingVersion = "version_draining"
+	CodeUnsupportedMap  = "unsupported_map"
+)
+
+// Manifest - manifest.yaml в папке сборки. Arguments и Env - шаблоны запуска,
+// заменяющие шаблоны из конфига (см. server_launcher.LaunchVars).
+type Manifest struct {
+	Executable   string            `yaml:"executable"`
+	Arguments    []string          `yaml:"arguments"`
+	Env          map[string]string `yaml:"env"`
+	Maps         []string          `yaml:"maps"`
+	ReadyPattern string            `yaml:"ready_pattern" env-default:"started on"`
+	Status       string            `yaml:"status" env-default:"active"`
+}
+
+type Build struct {
+	Version string
+	Dir     string
+	Manifest
+}
+
+// Error описывает отказ в запуске версии, Code уходит клиенту как есть.
+type Error struct {
+	Code    string
+	Version string
+	Map     string
+}
+
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+// Retryable - нет: тот же запрос с той же версией и картой снова получит отказ.
+func (e *Error) Retryable() bool {
+	return false
+}
+
+func (e *Error) Error() string {
+	switch e.Code {
+	case CodeRetiredVersion:
+		return fmt.Sprintf("version %q is retired", e.Version)
+	case CodeDrainingVersion:
+		return fmt.Sprintf("version %q is draining, no new rooms are created", e.Version)
+	case CodeUnsupportedMap:
+		return fmt.Sprintf("version %q does not support map %q", e.Version, e.Map)
+	default:
+		if e.Version == "" {
+			return "no default version is set"
+		}
+		return fmt.Sprintf("version %q is not installed", e.Version)
+	}
+}
+
+type Registry struct {
+	root           string
+	executableName string
+	mu             sync.RWMutex
+	builds         map[string]*Build
+	rollout        rolloutState
+}
+
+func New(cfg *config.Config) (*Registry, error) {
+	registry := &Registry{
+		root:           cfg.VersionPath,
+		executableName: cfg.ExecutableName,
+		builds:         make(map[string]*Build),
+	}
+	if err := registry.Scan(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r *Registry) Root() string {
+	return r.root
+}
+
+// Scan перечитывает VersionPath: каждая подпапка с исполняемым файлом - отдельная сборка.
+func (r *Registry) Scan() error {
+	if r.root == "" {
+		return errors.New("version path is not configured")
+	}
+
+	entries, err := os.ReadDir(r.root)
+	if err != nil {
+		return fmt.Errorf("cannot read version path %s: %w", r.root, err)
+	}
+	rollout, err := readRollout(r.root)
+	if err != nil {
+		return err
+	}
+
+	builds := make(map[string]*Build)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		build, err := r.readBuild(entry.Name(), filepath.Join(r.root, entry.Name()))
+		if err != nil {
+			log.Warn("skipping build", "build", entry.Name(), logger.Err(err))
+			continue
+		}
+		if status, ok := rollout.Statuses[build.Version]; ok {
+			build.Status = status
+		}
+		builds[build.Version] = build
+	}
+
+	if build, ok := builds[rollout.Default]; ok && build.Status != StatusActive {
+		log.Warn("default version is not active, no default is used", "version", build.Version, "status", build.Status)
+		rollout.Default = ""
+	}
+
+	r.mu.Lock()
+	r.builds = builds
+	r.rollout = rollout
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registry) readBuild(version, dir string) (*Build, error) {
+	build := &Build{Version: version, Dir: dir}
+
+	manifestPath := filepath.Join(dir, ManifestName)
+	if _, err := os.Stat(manifestPath); err == nil {
+		if err := cleanenv.ReadConfig(manifestPath, &build.Manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+	} else {
+		build.Manifest = Manifest{ReadyPattern: "started on", Status: StatusActive}
+	}
+
+	if build.Executable == "" {
+		build.Executable = r.executableName
+	}
+	if build.Executable == "" {
+		return nil, errors.New("executable is not set")
+	}
+	if _, err := os.Stat(build.ExecutablePath()); err != nil {
+		return nil, fmt.Errorf("executable not found: %w", err)
+	}
+	return build, nil
+}
+
+func (r *Registry) Get(version string) (*Build, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	build, ok := r.builds[version]
+	return build, ok
+}
+
+// Resolve возвращает сборку, на которой можно поднять новую комнату с этой картой.
+// Пустая версия означает версию по умолчанию.
+func (r *Registry) Resolve(version, mapName string) (*Build, error) {
+	if version == "" {
+		version = r.Default()
+	}
+	build, ok := r.Get(version)
+	if !ok {
+		return nil, &Error{Code: CodeUnknownVersion, Version: version}
+	}
+	if build.Status == StatusRetired {
+		return nil, &Error{Code: CodeRetiredVersion, Version: version}
+	}
+	if !build.SupportsMap(mapName) {
+		return nil, &Error{Code: CodeUnsupportedMap, Version: version, Map: mapName}
+	}
+	// Сборку отдаём и при draining: игрок может догрузить уже набирающуюся комнату.
+	if build.Status == StatusDraining {
+		return build, &Error{Code: CodeDrainingVersion, Version: version}
+	}
+	return build, nil
+}
+
+func (r *Registry) List() []*Build {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	builds := make([]*Build, 0, len(r.builds))
+	for _, build := range r.builds {
+		builds = append(builds, build)
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Version < builds[j].Version
+	})
+	return builds
+}
+
+// Warning - предупреждение для игроков на устаревшей версии; пусто, если версия не deprecated.
+func (b *Build) Warning() string {
+	if b.Status != StatusDeprecated {
+		return ""
+	}
+	return fmt.Sprintf("version %q is deprecated, please update", b.Version)
+}
+
+func (b *Build) ExecutablePath() string {
+	return filepath.Join(b.Dir, b.Executable)
+}
+
+// SupportsMap - пустой список карт в манифесте означает любую карту.
+func (b *Build) SupportsMap(mapName string) bool {
+	if len(b.Maps) == 0 {
+		return true
+	}
+	for _, m := range b.Maps {
+		if m == mapName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package versions_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+)
+
+func writeBuild(t *testing.T, root, version, manifest string) {
+	dir := filepath.Join(root, version)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Server.x86_64"), []byte("#!/bin/sh\n"), 0o755))
+	if manifest != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, versions.ManifestName), []byte(manifest), 0o644))
+	}
+}
+
+func newRegistry(t *testing.T) *versions.Registry {
+	root := t.TempDir()
+	writeBuild(t, root, "v1.0", "")
+	writeBuild(t, root, "v1.1", "maps: [Arena, Forest]\nready_pattern: \"Server ready\"\narguments: [\"-fps\", \"30\"]\n")
+	writeBuild(t, root, "v0.9", "status: retired\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "broken"), 0o755))
+
+	registry, err := versions.New(&config.Config{VersionPath: root, ExecutableName: "Server.x86_64"})
+	require.NoError(t, err)
+	return registry
+}
+
+func TestRegistry_Scan(t *testing.T) {
+	registry := newRegistry(t)
+
+	builds := registry.List()
+	require.Len(t, builds, 3, "build without executable must be skipped")
+	assert.Equal(t, "v0.9", builds[0].Version)
+
+	build, ok := registry.Get("v1.0")
+	require.True(t, ok)
+	assert.Equal(t, "started on", build.ReadyPattern)
+	assert.Equal(t, versions.StatusActive, build.Status)
+
+	build, ok = registry.Get("v1.1")
+	require.True(t, ok)
+	assert.Equal(t, "Server ready", build.ReadyPattern)
+	assert.Equal(t, []string{"-fps", "30"}, build.Arguments)
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	registry := newRegistry(t)
+
+	cases := []struct {
+		version string
+		mapName string
+		code    string
+	}{
+		{"v1.0", "AnyMap", ""},
+		{"v1.1", "Arena", ""},
+		{"v1.1", "Desert", versions.CodeUnsupportedMap},
+		{"v0.9", "Arena", versions.CodeRetiredVersion},
+		{"v2.0", "Arena", versions.CodeUnknownVersion},
+		{"../../bin", "Arena", versions.CodeUnknownVersion},
+	}
+
+	for _, tc := range cases {
+		build, err := registry.Resolve(tc.version, tc.mapName)
+		if tc.code == "" {
+			require.NoError(t, err, tc.version)
+			assert.Equal(t, tc.version, build.Version)
+			continue
+		}
+		var versionErr *versions.Error
+		require.True(t, errors.As(err, &versionErr), tc.version)
+		assert.Equal(t, tc.code, versionErr.Code)
+	}
+}
+
+func TestRegistry_MissingRoot(t *testing.T) {
+	_, err := versions.New(&config.Config{VersionPath: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetMode(0o755)
+		w, err := zw.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRegistry_InstallFromURL(t *testing.T) {
+	registry := newRegistry(t)
+	archive := tarGzArchive(t, map[string]string{
+		"build/Server.x86_64": "#!/bin/sh\n",
+		"build/manifest.yaml": "maps: [Arena]\n",
+	})
+	artifacts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer artifacts.Close()
+
+	build, err := registry.Install(context.Background(), versions.InstallRequest{
+		Version: "v2.0",
+		Source:  artifacts.URL + "/builds/v2.0.tar.gz",
+		SHA256:  checksum(archive),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(registry.Root(), "v2.0"), build.Dir)
+	assert.FileExists(t, build.ExecutablePath())
+
+	_, err = registry.Resolve("v2.0", "Arena")
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(registry.Root())
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".install-", "temporary directories must be cleaned up")
+	}
+}
+
+func TestRegistry_InstallFromLocalZip(t *testing.T) {
+	registry := newRegistry(t)
+	archive := zipArchive(t, map[string]string{"Server.x86_64": "#!/bin/sh\n"})
+	path := filepath.Join(t.TempDir(), "v2.1.zip")
+	require.NoError(t, os.WriteFile(path, archive, 0o644))
+
+	_, err := registry.Install(context.Background(), versions.InstallRequest{Version: "v2.1", Source: path, SHA256: checksum(archive)})
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(registry.Root(), "v2.1", "Server.x86_64"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0o100, "executable bit must be preserved")
+}
+
+func TestRegistry_InstallSkipsPaxGlobalHeader(t *testing.T) {
+	registry := newRegistry(t)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	// Так начинается архив из git archive
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "0123abcd"}}))
+	content := "#!/bin/sh\n"
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "Server.x86_64", Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	archive := buf.Bytes()
+	path := filepath.Join(t.TempDir(), "v2.2.tar.gz")
+	require.NoError(t, os.WriteFile(path, archive, 0o644))
+
+	build, err := registry.Install(context.Background(), versions.InstallRequest{Version: "v2.2", Source: path, SHA256: checksum(archive)})
+	require.NoError(t, err)
+	assert.FileExists(t, build.ExecutablePath())
+	assert.NoFileExists(t, filepath.Join(build.Dir, "pax_global_header"))
+}
+
+func TestRegistry_InstallRejectsBadArchives(t *testing.T) {
+	registry := newRegistry(t)
+	dir := t.TempDir()
+
+	good := tarGzArchive(t, map[string]string{"Server.x86_64": "#!/bin/sh\n"})
+	goodPath := filepath.Join(dir, "good.tar.gz")
+	require.NoError(t, os.WriteFile(goodPath, good, 0o644))
+
+	evil := tarGzArchive(t, map[string]string{"../../escape": "boom"})
+	evilPath := filepath.Join(dir, "evil.tar.gz")
+	require.NoError(t, os.WriteFile(evilPath, evil, 0o644))
+
+	cases := []versions.InstallRequest{
+		{Version: "v3.0", Source: goodPath, SHA256: checksum([]byte("other"))},
+		{Version: "v3.0", Source: goodPath},
+		{Version: "../v3.0", Source: goodPath, SHA256: checksum(good)},
+		{Version: "v1.0", Source: goodPath, SHA256: checksum(good)},
+		{Version: "v3.0", Source: evilPath, SHA256: checksum(evil)},
+	}
+	for _, req := range cases {
+		_, err := registry.Install(context.Background(), req)
+		assert.Error(t, err, "%+v", req)
+	}
+
+	_, ok := registry.Get("v3.0")
+	assert.False(t, ok)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(registry.Root()), "escape"))
+}
+
+func TestRegistry_Rollout(t *testing.T) {
+	registry := newRegistry(t)
+
+	_, err := registry.Resolve("", "Arena")
+	require.Error(t, err, "no default version yet")
+
+	require.NoError(t, registry.SetDefault("v1.1"))
+	build, err := registry.Resolve("", "Arena")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1", build.Version)
+
+	require.Error(t, registry.SetDefault("v0.9"), "retired version cannot be default")
+
+	require.NoError(t, registry.SetStatus("v1.0", versions.StatusDraining))
+	build, err = registry.Resolve("v1.0", "Arena")
+	var versionErr *versions.Error
+	require.True(t, errors.As(err, &versionErr))
+	assert.Equal(t, versions.CodeDrainingVersion, versionErr.Code)
+	assert.NotNil(t, build, "draining build is still returned for rooms that are filling")
+
+	require.NoError(t, registry.SetStatus("v1.1", versions.StatusDeprecated))
+	assert.Empty(t, registry.Default(), "deprecated version stops being default")
+	require.Error(t, registry.SetDefault("v1.1"), "deprecated version cannot be default")
+	build, err = registry.Resolve("v1.1", "Arena")
+	require.NoError(t, err, "deprecated version still gets new rooms")
+	assert.Contains(t, build.Warning(), "deprecated")
+
+	reloaded, err := versions.New(&config.Config{VersionPath: registry.Root(), ExecutableName: "Server.x86_64"})
+	require.NoError(t, err)
+	build, ok := reloaded.Get("v1.0")
+	require.True(t, ok)
+	assert.Equal(t, versions.StatusDraining, build.Status, "rollout state must survive restart")
+}
@@ -0,0 +1,86 @@
+package history
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы выгрузки для аналитики.
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+var csvHeader = []string{
+	"room_uuid", "room_id", "mode", "map", "version", "started_at", "ended_at",
+	"duration_seconds", "outcome", "exit_error", "players", "connected", "results",
+}
+
+// Export пишет матчи в формате format: jsonl - Match по строке, csv - матч на строку,
+// игроки как "client:team" через пробел, results - исходный JSON отчёта.
+func Export(w io.Writer, format string, matches []Match) error {
+	switch format {
+	case FormatJSONL:
+		return WriteJSONL(w, matches)
+	case FormatCSV:
+		return writeCSV(w, matches)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+func WriteJSONL(w io.Writer, matches []Match) error {
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	for _, match := range matches {
+		if err := encoder.Encode(match); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func writeCSV(w io.Writer, matches []Match) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, match := range matches {
+		players := make([]string, 0, len(match.Players))
+		for _, player := range match.Players {
+			players = append(players, player.ClientID+":"+strconv.Itoa(player.Team))
+		}
+		record := []string{
+			match.RoomUUID,
+			strconv.Itoa(match.RoomID),
+			match.Mode,
+			match.Map,
+			match.Version,
+			formatTime(match.StartedAt),
+			formatTime(match.EndedAt),
+			strconv.FormatFloat(match.DurationSeconds, 'f', 3, 64),
+			match.Outcome,
+			match.ExitError,
+			strings.Join(players, " "),
+			strings.Join(match.Connected, " "),
+			string(match.Results),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,296 @@
+package history
+
+import (
+	"encoding/json"
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/journal"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Исходы матча.
+const (
+	// OutcomeCompleted - сервер прислал отчёт о конце матча
+	OutcomeCompleted = "completed"
+	// OutcomeExited - процесс завершился без отчёта
+	OutcomeExited = "exited"
+	// OutcomeCrashed - процесс завершился с ошибкой, не прислав отчёт
+	OutcomeCrashed = "crashed"
+)
+
+var log = logger.For("history")
+
+var matchesFinished = metrics.Default.Counter("sm_matches_finished_total", "Finished matches, per mode and outcome.", "mode", "outcome")
+
+// Player - запрос, попавший в матч: один игрок или группа из Size игроков.
+type Player struct {
+	ClientID string `json:"client_id"`
+	Team     int    `json:"team"`
+	Size     int    `json:"size"`
+}
+
+// Match - сыгранный или идущий матч. Пустой Outcome - матч ещё идёт.
+type Match struct {
+	RoomUUID string   `json:"room_uuid"`
+	RoomID   int      `json:"room_id"`
+	Mode     string   `json:"mode"`
+	Map      string   `json:"map"`
+	Version  string   `json:"version"`
+	Players  []Player `json:"players"`
+	// Connected - игроки, о подключении которых сервер сообщил и которые не вышли к концу матча
+	Connected       []string        `json:"connected"`
+	StartedAt       time.Time       `json:"started_at"`
+	EndedAt         time.Time       `json:"ended_at"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	Outcome         string          `json:"outcome,omitempty"`
+	ExitError       string          `json:"exit_error,omitempty"`
+	Results         json.RawMessage `json:"results,omitempty"`
+}
+
+func (m Match) Finished() bool {
+	return m.Outcome != ""
+}
+
+func (m Match) hasPlayer(clientID string) bool {
+	for _, player := range m.Players {
+		if player.ClientID == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter отбирает матчи по началу матча. Пустые поля не ограничивают выборку.
+type Filter struct {
+	ClientID string
+	RoomID   int
+	RoomUUID string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+func (f Filter) matches(match Match) bool {
+	switch {
+	case f.ClientID != "" && !match.hasPlayer(f.ClientID):
+		return false
+	case f.RoomID != 0 && match.RoomID != f.RoomID:
+		return false
+	case f.RoomUUID != "" && match.RoomUUID != f.RoomUUID:
+		return false
+	case !f.Since.IsZero() && match.StartedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !match.StartedAt.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// History хранит завершённые матчи в файле, идущие - в памяти: после рестарта
+// их серверы менеджеру уже не принадлежат.
+type History struct {
+	path      string
+	retention time.Duration
+
+	mu       sync.Mutex
+	journal  *journal.Journal
+	finished []Match
+	active   map[string]*Match
+}
+
+// New читает историю из cfg.History.Path. Пустой путь - история только в памяти.
+func New(cfg *config.Config) (*History, error) {
+	h := &History{
+		path:      cfg.History.Path,
+		retention: time.Duration(cfg.History.RetentionDays) * 24 * time.Hour,
+		active:    make(map[string]*Match),
+	}
+	if h.path == "" {
+		return h, nil
+	}
+	file, err := journal.Open(h.path, func(line []byte) error {
+		var match Match
+		if err := json.Unmarshal(line, &match); err != nil {
+			return err
+		}
+		h.finished = append(h.finished, match)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.journal = file
+
+	if err := h.Cleanup(time.Now()); err != nil {
+		h.journal.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// Subscribe ведёт историю по событиям матчмейкера: матч начинается, когда игроки
+// получают адрес сервера, и заканчивается с концом матча комнаты.
+func (h *History) Subscribe(b *bus.Bus) {
+	bus.Subscribe(b, "history", func(e events.MatchReady) { h.Start(e.Room) })
+	bus.Subscribe(b, "history", func(e room.Ended) { h.Finish(e.Room) })
+}
+
+// Start открывает запись матча, когда комната заполнилась и игроки получают адрес сервера.
+func (h *History) Start(target *room.Room) {
+	players := target.PlayersSnapshot()
+	match := &Match{
+		RoomUUID:  target.UUID,
+		RoomID:    target.ID,
+		Mode:      target.Mode,
+		Map:       target.CurrentMap,
+		Version:   target.AppVersion,
+		Players:   make([]Player, 0, len(players)),
+		Connected: []string{},
+		StartedAt: time.Now().UTC(),
+	}
+	for _, player := range players {
+		match.Players = append(match.Players, Player{
+			ClientID: player.ConnectedMessage.ClientID,
+			Team:     player.Team,
+			Size:     player.ConnectedMessage.NumberOfPlayers,
+		})
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.active[match.RoomUUID] = match
+}
+
+// Finish закрывает запись по отчёту сервера или по выходу его процесса.
+// Комнаты, матч которых так и не начался, в историю не попадают.
+func (h *History) Finish(target *room.Room) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	match, ok := h.active[target.UUID]
+	if !ok {
+		return
+	}
+	delete(h.active, target.UUID)
+
+	match.EndedAt = time.Now().UTC()
+	match.DurationSeconds = match.EndedAt.Sub(match.StartedAt).Seconds()
+	match.Connected = target.ConnectedPlayers()
+	match.Results = target.Results()
+	exitErr := target.ExitErr()
+	if exitErr != nil {
+		match.ExitError = exitErr.Error()
+	}
+	switch {
+	case target.Reported():
+		match.Outcome = OutcomeCompleted
+	case exitErr != nil:
+		match.Outcome = OutcomeCrashed
+	default:
+		match.Outcome = OutcomeExited
+	}
+
+	matchesFinished.Inc(match.Mode, match.Outcome)
+	log.Info("match finished", logger.RoomID(match.RoomID), logger.RoomUUID(match.RoomUUID), "outcome", match.Outcome, "duration", match.DurationSeconds)
+	if err := h.writeLocked(*match); err != nil {
+		log.Error("failed to write match", logger.RoomUUID(match.RoomUUID), logger.Err(err))
+	}
+	h.finished = append(h.finished, *match)
+}
+
+func (h *History) writeLocked(match Match) error {
+	if h.journal == nil {
+		return nil
+	}
+	return h.journal.Append(match)
+}
+
+// Matches возвращает завершённые и идущие матчи по времени начала; Limit оставляет последние.
+func (h *History) Matches(filter Filter) []Match {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	matches := make([]Match, 0)
+	for _, match := range h.finished {
+		if filter.matches(match) {
+			matches = append(matches, match)
+		}
+	}
+	for _, match := range h.active {
+		if filter.matches(*match) {
+			matches = append(matches, *match)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].StartedAt.Before(matches[j].StartedAt)
+	})
+	if filter.Limit > 0 && len(matches) > filter.Limit {
+		matches = matches[len(matches)-filter.Limit:]
+	}
+	return matches
+}
+
+func (h *History) Match(uuid string) (Match, bool) {
+	matches := h.Matches(Filter{RoomUUID: uuid})
+	if len(matches) == 0 {
+		return Match{}, false
+	}
+	return matches[len(matches)-1], true
+}
+
+// Cleanup забывает матчи, закончившиеся раньше срока хранения, и переписывает файл.
+func (h *History) Cleanup(now time.Time) error {
+	if h.retention <= 0 {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	before := now.Add(-h.retention)
+	kept := h.finished[:0:0]
+	for _, match := range h.finished {
+		if !match.EndedAt.Before(before) {
+			kept = append(kept, match)
+		}
+	}
+	if len(kept) == len(h.finished) {
+		return nil
+	}
+	dropped := len(h.finished) - len(kept)
+	// Память меняется только после того, как файл переписан: иначе они разойдутся
+	if h.journal != nil {
+		if err := journal.Rewrite(h.journal, kept); err != nil {
+			return err
+		}
+	}
+	h.finished = kept
+	log.Info("match history cleaned up", "dropped", dropped, "kept", len(kept))
+	return nil
+}
+
+func (h *History) RunCleanup(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if err := h.Cleanup(now); err != nil {
+				log.Warn("match history cleanup failed", logger.Err(err))
+			}
+		}
+	}
+}
+
+func (h *History) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.journal == nil {
+		return nil
+	}
+	return h.journal.Close()
+}
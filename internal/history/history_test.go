@@ -0,0 +1,147 @@
+package history_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/history"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+func newRoom(t *testing.T, id int, clients ...string) *room.Room {
+	target, err := room.New(_type.RoomSettings{ID: id, MaxPlayers: 4, Teams: 2, Mode: "duel", CurrentMap: "Forest", AppVersion: "v1"})
+	require.NoError(t, err)
+	target.Timer.Stop()
+	for _, client := range clients {
+		target.AddPlayer(&_type.PendingConnection{ConnectedMessage: _type.Message{ClientID: client, NumberOfPlayers: 1}})
+	}
+	return target
+}
+
+func TestHistory_Outcomes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "matches.jsonl")
+	h, err := history.New(&config.Config{History: config.History{Path: path}})
+	require.NoError(t, err)
+
+	reported := newRoom(t, 1, "alice", "bob")
+	h.Start(reported)
+	reported.PlayerJoined("alice")
+	live, ok := h.Match(reported.UUID)
+	require.True(t, ok)
+	assert.False(t, live.Finished())
+
+	require.True(t, reported.End(json.RawMessage(`{"winner":1}`)))
+	h.Finish(reported)
+	reported.Exited(nil)
+
+	crashed := newRoom(t, 2, "carol")
+	h.Start(crashed)
+	crashed.Exited(errors.New("exit status 139"))
+	h.Finish(crashed)
+
+	// Комната, матч которой не начался, в историю не попадает
+	h.Finish(newRoom(t, 3, "dave"))
+	require.NoError(t, h.Close())
+
+	h, err = history.New(&config.Config{History: config.History{Path: path}})
+	require.NoError(t, err)
+	defer h.Close()
+	matches := h.Matches(history.Filter{})
+	require.Len(t, matches, 2)
+
+	assert.Equal(t, history.OutcomeCompleted, matches[0].Outcome)
+	assert.JSONEq(t, `{"winner":1}`, string(matches[0].Results))
+	assert.Equal(t, []string{"alice"}, matches[0].Connected)
+	assert.Equal(t, []history.Player{{ClientID: "alice", Team: 1, Size: 1}, {ClientID: "bob", Team: 2, Size: 1}}, matches[0].Players)
+
+	assert.Equal(t, history.OutcomeCrashed, matches[1].Outcome)
+	assert.Equal(t, "exit status 139", matches[1].ExitError)
+
+	assert.Len(t, h.Matches(history.Filter{ClientID: "bob"}), 1)
+	assert.Len(t, h.Matches(history.Filter{RoomID: 2}), 1)
+	assert.Len(t, h.Matches(history.Filter{Limit: 1}), 1)
+	assert.Empty(t, h.Matches(history.Filter{Since: time.Now().Add(time.Minute)}))
+}
+
+func TestHistory_CleanupAndTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matches.jsonl")
+	old := history.Match{RoomUUID: "old", StartedAt: time.Now().Add(-50 * time.Hour), EndedAt: time.Now().Add(-49 * time.Hour), Outcome: history.OutcomeExited}
+	recent := history.Match{RoomUUID: "recent", StartedAt: time.Now().Add(-time.Hour), EndedAt: time.Now(), Outcome: history.OutcomeCompleted}
+	var content bytes.Buffer
+	require.NoError(t, history.WriteJSONL(&content, []history.Match{old, recent}))
+	content.WriteString(`{"room_uuid":"cut`)
+	require.NoError(t, os.WriteFile(path, content.Bytes(), 0o644))
+
+	h, err := history.New(&config.Config{History: config.History{Path: path, RetentionDays: 1}})
+	require.NoError(t, err)
+	defer h.Close()
+	matches := h.Matches(history.Filter{})
+	require.Len(t, matches, 1)
+	assert.Equal(t, "recent", matches[0].RoomUUID)
+
+	stored, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored), "old")
+	assert.NotContains(t, string(stored), "cut")
+}
+
+func TestHistory_FailedCleanupKeepsMatches(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	path := filepath.Join(dir, "matches.jsonl")
+	match := history.Match{RoomUUID: "kept", StartedAt: time.Now().Add(-time.Hour), EndedAt: time.Now(), Outcome: history.OutcomeCompleted}
+	var content bytes.Buffer
+	require.NoError(t, history.WriteJSONL(&content, []history.Match{match}))
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(path, content.Bytes(), 0o644))
+
+	h, err := history.New(&config.Config{History: config.History{Path: path, RetentionDays: 1}})
+	require.NoError(t, err)
+	defer h.Close()
+
+	// Переписать файл негде: память должна остаться такой же, как файл
+	require.NoError(t, os.RemoveAll(dir))
+	assert.Error(t, h.Cleanup(time.Now().Add(48*time.Hour)))
+	assert.Len(t, h.Matches(history.Filter{}), 1)
+}
+
+func TestExport(t *testing.T) {
+	matches := []history.Match{{
+		RoomUUID:  "0f8fad5b-d9cb-469f-a165-70867728950e",
+		RoomID:    7,
+		Mode:      "duel",
+		Map:       "Forest",
+		Players:   []history.Player{{ClientID: "alice", Team: 1}, {ClientID: "bob", Team: 2}},
+		StartedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Outcome:   history.OutcomeCompleted,
+		Results:   json.RawMessage(`{"score":"2,1"}`),
+	}}
+
+	var out bytes.Buffer
+	require.NoError(t, history.Export(&out, history.FormatCSV, matches))
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "room_uuid", records[0][0])
+	assert.Equal(t, "2026-01-02T03:04:05Z", records[1][5])
+	assert.Equal(t, "alice:1 bob:2", records[1][10])
+	assert.Equal(t, `{"score":"2,1"}`, records[1][12])
+
+	out.Reset()
+	require.NoError(t, history.Export(&out, history.FormatJSONL, matches))
+	var decoded history.Match
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, 7, decoded.RoomID)
+
+	assert.Error(t, history.Export(&out, "xml", matches))
+}
@@ -0,0 +1,126 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var log = logger.For("journal")
+
+// Journal - файл с одной JSON-записью на строку, открытый на дозапись. Журнал тикетов,
+// история матчей и outbox вебхуков хранятся так.
+type Journal struct {
+	path string
+	file *os.File
+}
+
+// Open создаёт каталог и файл при необходимости и проигрывает записи через apply.
+// Недописанная при падении последняя строка отрезается, иначе новые записи приклеятся к ней.
+func Open(path string, apply func(line []byte) error) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	valid, err := replay(path, file, apply)
+	if err == nil {
+		err = file.Truncate(valid)
+	}
+	if err == nil {
+		_, err = file.Seek(valid, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Journal{path: path, file: file}, nil
+}
+
+// replay возвращает смещение конца последней целой записи.
+func replay(path string, r io.Reader, apply func(line []byte) error) (int64, error) {
+	reader := bufio.NewReader(r)
+	var offset int64
+	for line := 1; ; line++ {
+		content, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(content)) > 0 {
+				log.Warn("dropping incomplete last record", "path", path, "line", line)
+			}
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := apply(content); err != nil {
+			return 0, fmt.Errorf("%s: line %d: %w", path, line, err)
+		}
+		offset += int64(len(content))
+	}
+}
+
+// Append дописывает запись в конец файла.
+func (j *Journal) Append(record any) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(content, '\n'))
+	return err
+}
+
+func (j *Journal) Sync() error {
+	return j.file.Sync()
+}
+
+// Rewrite заменяет содержимое журнала records: временный файл, fsync, rename и
+// переоткрытие на дозапись. При ошибке журнал остаётся прежним.
+func Rewrite[T any](j *Journal, records []T) error {
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), "."+filepath.Base(j.path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	j.file.Close()
+	j.file = file
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,72 @@
+package journal_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/journal"
+)
+
+type record struct {
+	ID int `json:"id"`
+}
+
+func open(t *testing.T, path string) (*journal.Journal, []record) {
+	var records []record
+	j, err := journal.Open(path, func(line []byte) error {
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		records = append(records, r)
+		return nil
+	})
+	require.NoError(t, err)
+	return j, records
+}
+
+func TestOpen_DropsIncompleteLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal.jsonl")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\":1}\n{\"id\":"), 0o644))
+
+	j, records := open(t, path)
+	assert.Equal(t, []record{{ID: 1}}, records)
+	require.NoError(t, j.Append(record{ID: 2}))
+	require.NoError(t, j.Close())
+
+	j, records = open(t, path)
+	defer j.Close()
+	assert.Equal(t, []record{{ID: 1}, {ID: 2}}, records, "new record must not stick to the cut one")
+}
+
+func TestOpen_ReportsBrokenLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\":1}\nnot json\n"), 0o644))
+	_, err := journal.Open(path, func(line []byte) error {
+		return json.Unmarshal(line, &record{})
+	})
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestRewrite_KeepsAppending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, _ := open(t, path)
+	for id := 1; id <= 3; id++ {
+		require.NoError(t, j.Append(record{ID: id}))
+	}
+	require.NoError(t, journal.Rewrite(j, []record{{ID: 3}}))
+	require.NoError(t, j.Append(record{ID: 4}))
+	require.NoError(t, j.Close())
+
+	j, records := open(t, path)
+	defer j.Close()
+	assert.Equal(t, []record{{ID: 3}, {ID: 4}}, records)
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".journal.jsonl-*"))
+	assert.Empty(t, leftovers, "temporary file is removed")
+}
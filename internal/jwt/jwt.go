@@ -0,0 +1,199 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrUnsupportedAlg   = errors.New("unsupported token algorithm")
+)
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign собирает компактный JWT. key: []byte для HS256, *rsa.PrivateKey для RS256,
+// *ecdsa.PrivateKey (P-256) для ES256, ed25519.PrivateKey для EdDSA.
+func Sign(claims any, alg string, key any) (string, error) {
+	return SignWithKeyID(claims, alg, "", key)
+}
+
+// SignWithKeyID добавляет kid в заголовок, чтобы проверяющая сторона выбрала ключ.
+func SignWithKeyID(claims any, alg, kid string, key any) (string, error) {
+	headerJSON, err := json.Marshal(header{Alg: alg, Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return "", fmt.Errorf("%s requires []byte key", alg)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case RS256:
+		private, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("%s requires *rsa.PrivateKey", alg)
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", err
+		}
+	case ES256:
+		private, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("%s requires *ecdsa.PrivateKey", alg)
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s, err := ecdsa.Sign(rand.Reader, private, digest[:])
+		if err != nil {
+			return "", err
+		}
+		// JWS хранит подпись ECDSA как r||s фиксированной длины, не в DER
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case EdDSA:
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("%s requires ed25519.PrivateKey", alg)
+		}
+		signature = ed25519.Sign(private, []byte(signingInput))
+	default:
+		return "", ErrUnsupportedAlg
+	}
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+// Parse разбирает токен без проверки подписи и возвращает алгоритм и kid из заголовка.
+func Parse(token string) (alg, kid string, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", "", ErrMalformed
+	}
+	headerJSON, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", ErrMalformed
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return "", "", ErrMalformed
+	}
+	return h.Alg, h.Kid, nil
+}
+
+// Verify проверяет подпись ключом key и раскладывает payload в claims.
+// Алгоритм из заголовка должен соответствовать типу ключа, иначе токен отвергается.
+func Verify(token string, key any, claims any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+	alg, _, err := Parse(token)
+	if err != nil {
+		return err
+	}
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrMalformed
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+
+	if err := verifySignature(alg, key, signingInput, signature); err != nil {
+		return err
+	}
+
+	claimsJSON, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(claimsJSON, claims); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+func verifySignature(alg string, key any, signingInput, signature []byte) error {
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrUnsupportedAlg
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+	case RS256:
+		public, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedAlg
+		}
+		digest := sha256.Sum256(signingInput)
+		if rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+	case ES256:
+		public, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedAlg
+		}
+		if len(signature) != 64 {
+			return ErrInvalidSignature
+		}
+		digest := sha256.Sum256(signingInput)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(public, digest[:], r, s) {
+			return ErrInvalidSignature
+		}
+	case EdDSA:
+		public, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrUnsupportedAlg
+		}
+		if !ed25519.Verify(public, signingInput, signature) {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnsupportedAlg
+	}
+	return nil
+}
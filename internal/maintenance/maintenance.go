@@ -0,0 +1,235 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"sync"
+	"time"
+)
+
+// Коды ответа клиенту, пока нода выводится из ротации.
+const (
+	CodeMaintenance = "maintenance"
+	CodeRedirect    = "redirect"
+)
+
+const pollInterval = time.Second
+
+var log = logger.For("maintenance")
+
+var draining = metrics.Default.Gauge("sm_node_draining", "1 while the node is draining before shutdown.")
+
+var ErrAlreadyDraining = errors.New("node is already draining")
+
+// Error - отказ в новой комнате на время обслуживания. Клиент либо повторяет
+// запрос после RetryAt, либо сразу идёт на Redirect.
+type Error struct {
+	RetryAt  time.Time
+	Redirect string
+}
+
+func (e *Error) ErrorCode() string {
+	if e.Redirect != "" {
+		return CodeRedirect
+	}
+	return CodeMaintenance
+}
+
+func (e *Error) Retryable() bool {
+	return true
+}
+
+func (e *Error) RetryAfterSeconds() int {
+	if e.Redirect != "" {
+		return 0
+	}
+	seconds := int(time.Until(e.RetryAt).Round(time.Second).Seconds())
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// RedirectAddress - адрес ноды, которая принимает запросы вместо этой.
+func (e *Error) RedirectAddress() string {
+	return e.Redirect
+}
+
+func (e *Error) Error() string {
+	if e.Redirect != "" {
+		return fmt.Sprintf("maintenance, use %s", e.Redirect)
+	}
+	return fmt.Sprintf("maintenance, retry at %s", e.RetryAt.UTC().Format(time.RFC3339))
+}
+
+// Options переопределяют настройки из конфига для одного drain. Нулевые поля
+// берутся из конфига, RetryAt по умолчанию - дедлайн.
+type Options struct {
+	Deadline time.Duration
+	RetryAt  time.Time
+	Redirect string
+}
+
+// Status - состояние drain для admin API.
+type Status struct {
+	Draining  bool      `json:"draining"`
+	Reason    string    `json:"reason,omitempty"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+	Deadline  time.Time `json:"deadline,omitzero"`
+	RetryAt   time.Time `json:"retry_at,omitzero"`
+	Redirect  string    `json:"redirect,omitempty"`
+	Active    int       `json:"active"`
+}
+
+// Node - режим обслуживания. После Drain новые комнаты не создаются, а Done
+// закрывается, когда Active вернёт ноль или наступит дедлайн.
+type Node struct {
+	// Active - сколько матчей и набирающихся комнат ещё живо на ноде
+	Active func() int
+
+	defaults Options
+
+	mu        sync.Mutex
+	draining  bool
+	reason    string
+	startedAt time.Time
+	options   Options
+	expired   bool
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func New(cfg *config.Config) *Node {
+	return &Node{
+		defaults: Options{
+			Deadline: time.Duration(cfg.Maintenance.Deadline) * time.Second,
+			Redirect: cfg.Maintenance.Redirect,
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Drain переводит ноду в обслуживание. reason попадает в лог и статус: "signal", "admin".
+func (n *Node) Drain(reason string, options Options) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.draining {
+		return ErrAlreadyDraining
+	}
+	if options.Deadline <= 0 {
+		options.Deadline = n.defaults.Deadline
+	}
+	if options.Redirect == "" {
+		options.Redirect = n.defaults.Redirect
+	}
+	n.startedAt = time.Now()
+	if options.RetryAt.IsZero() {
+		options.RetryAt = n.startedAt.Add(options.Deadline)
+	}
+	n.draining = true
+	n.reason = reason
+	n.options = options
+	draining.Set(1)
+
+	log.Info("node is draining", "reason", reason, "deadline", options.Deadline, "redirect", options.Redirect)
+	go n.wait(n.startedAt.Add(options.Deadline))
+	return nil
+}
+
+// Stop завершает ожидание немедленно, как при наступлении дедлайна.
+func (n *Node) Stop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	select {
+	case <-n.stop:
+	default:
+		close(n.stop)
+	}
+}
+
+func (n *Node) wait(deadline time.Time) {
+	defer close(n.done)
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if n.active() == 0 {
+			log.Info("node drained, all matches have ended")
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			n.setExpired()
+			log.Warn("drain deadline passed", "active", n.active())
+			return
+		case <-n.stop:
+			n.setExpired()
+			log.Warn("drain interrupted", "active", n.active())
+			return
+		}
+	}
+}
+
+func (n *Node) setExpired() {
+	n.mu.Lock()
+	n.expired = true
+	n.mu.Unlock()
+}
+
+func (n *Node) active() int {
+	if n.Active == nil {
+		return 0
+	}
+	return n.Active()
+}
+
+// Err - ошибка для запроса, которому нужна новая комната; nil, пока нода в ротации.
+func (n *Node) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.draining {
+		return nil
+	}
+	return &Error{RetryAt: n.options.RetryAt, Redirect: n.options.Redirect}
+}
+
+func (n *Node) Draining() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.draining
+}
+
+// Expired - drain закончился по дедлайну или через Stop, а не потому что матчи доиграны.
+func (n *Node) Expired() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.expired
+}
+
+func (n *Node) Done() <-chan struct{} {
+	return n.done
+}
+
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	status := Status{
+		Draining:  n.draining,
+		Reason:    n.reason,
+		StartedAt: n.startedAt,
+		RetryAt:   n.options.RetryAt,
+		Redirect:  n.options.Redirect,
+	}
+	if n.draining {
+		status.Deadline = n.startedAt.Add(n.options.Deadline)
+	}
+	n.mu.Unlock()
+	status.Active = n.active()
+	return status
+}
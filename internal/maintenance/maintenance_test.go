@@ -0,0 +1,74 @@
+package maintenance_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/maintenance"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+func waitDone(t *testing.T, node *maintenance.Node, timeout time.Duration) {
+	select {
+	case <-node.Done():
+	case <-time.After(timeout):
+		t.Fatal("drain did not finish")
+	}
+}
+
+func TestNode_DrainsWhenMatchesEnd(t *testing.T) {
+	node := maintenance.New(&config.Config{Maintenance: config.Maintenance{Deadline: 60}})
+	var active atomic.Int32
+	active.Store(2)
+	node.Active = func() int { return int(active.Load()) }
+
+	assert.NoError(t, node.Err())
+	require.NoError(t, node.Drain("admin", maintenance.Options{}))
+	assert.ErrorIs(t, node.Drain("signal", maintenance.Options{}), maintenance.ErrAlreadyDraining)
+
+	status := node.Status()
+	assert.True(t, status.Draining)
+	assert.Equal(t, "admin", status.Reason)
+	assert.Equal(t, 2, status.Active)
+	assert.Equal(t, status.Deadline, status.RetryAt, "clients retry once the node is gone")
+
+	var maintenanceErr *maintenance.Error
+	require.True(t, errors.As(node.Err(), &maintenanceErr))
+	assert.Equal(t, maintenance.CodeMaintenance, maintenanceErr.ErrorCode())
+
+	active.Store(0)
+	waitDone(t, node, 3*time.Second)
+	assert.False(t, node.Expired())
+}
+
+func TestNode_DeadlineAndRedirect(t *testing.T) {
+	node := maintenance.New(&config.Config{Maintenance: config.Maintenance{Deadline: 60, Redirect: "10.0.0.2:8080"}})
+	node.Active = func() int { return 1 }
+
+	require.NoError(t, node.Drain("signal", maintenance.Options{Deadline: 50 * time.Millisecond}))
+	waitDone(t, node, 3*time.Second)
+	assert.True(t, node.Expired())
+
+	response := _type.NewErrorResponse(node.Err())
+	assert.Equal(t, maintenance.CodeRedirect, response.Code)
+	assert.Equal(t, "10.0.0.2:8080", response.Redirect)
+	assert.True(t, response.Retryable)
+	assert.Zero(t, response.RetryAfter)
+}
+
+func TestNode_Stop(t *testing.T) {
+	node := maintenance.New(&config.Config{Maintenance: config.Maintenance{Deadline: 60}})
+	node.Active = func() int { return 1 }
+
+	require.NoError(t, node.Drain("signal", maintenance.Options{}))
+	node.Stop()
+	node.Stop()
+	waitDone(t, node, 3*time.Second)
+	assert.True(t, node.Expired())
+}
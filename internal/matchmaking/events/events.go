@@ -0,0 +1,90 @@
+package events
+
+import (
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+// События матчмейкера. Ключ - UUID комнаты, поэтому они упорядочены с событиями
+// самой комнаты (room.Filled, room.StateChanged, room.Ended); отказ без комнаты
+// упорядочен по тикету.
+
+// Исходы RoomCancelled.
+const (
+	OutcomeCancelled = "cancelled"
+	OutcomeFailed    = "failed"
+)
+
+// RoomCreated - комната создана и принимает игроков; лаунчер запускает для неё сервер.
+type RoomCreated struct {
+	Room *room.Room
+}
+
+// PlayerJoined публикуется до того, как игрок занял место: его Room.Filled идёт после.
+type PlayerJoined struct {
+	Room   *room.Room
+	Player *_type.PendingConnection
+}
+
+// LaunchFailed - сервер комнаты не запустился.
+type LaunchFailed struct {
+	Room *room.Room
+	Err  error
+}
+
+// ServerAssigned - процесс сервера комнаты запущен; Executable нужен, чтобы после
+// рестарта отличить свой осиротевший процесс от чужого с тем же PID.
+type ServerAssigned struct {
+	Room       *room.Room
+	PID        int
+	Port       int
+	Version    string
+	Executable string
+}
+
+// ServerExited публикуется до room.Ended той же комнаты.
+type ServerExited struct {
+	Room *room.Room
+	PID  int
+	Err  error
+}
+
+// MatchReady - набор закрыт и сервер готов: игроки получают его адрес.
+type MatchReady struct {
+	Room *room.Room
+}
+
+// RoomCancelled - комната закрыта без матча; Players ждут ответа с ошибкой Err.
+type RoomCancelled struct {
+	Room    *room.Room
+	Players []*_type.PendingConnection
+	Err     error
+	Outcome string
+	// Filling - комната ещё принимала игроков
+	Filling bool
+}
+
+// PlayerRejected - запросу отказано; RoomUUID пуст, если он не успел попасть в комнату.
+type PlayerRejected struct {
+	RoomUUID string
+	Player   *_type.PendingConnection
+	Err      error
+}
+
+func (e RoomCreated) Key() string    { return e.Room.UUID }
+func (e PlayerJoined) Key() string   { return e.Room.UUID }
+func (e LaunchFailed) Key() string   { return e.Room.UUID }
+func (e ServerAssigned) Key() string { return e.Room.UUID }
+func (e ServerExited) Key() string   { return e.Room.UUID }
+func (e MatchReady) Key() string     { return e.Room.UUID }
+func (e RoomCancelled) Key() string  { return e.Room.UUID }
+
+func (e PlayerRejected) Key() string {
+	if e.RoomUUID != "" {
+		return e.RoomUUID
+	}
+	if e.Player.TicketID != "" {
+		return e.Player.TicketID
+	}
+	return e.Player.ConnectedMessage.ClientID
+}
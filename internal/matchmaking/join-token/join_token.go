@@ -0,0 +1,150 @@
+package join_token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/jwt"
+	"os"
+	"time"
+)
+
+var ErrExpired = errors.New("join token expired")
+
+// Claims - содержимое токена входа. Игровой сервер проверяет подпись,
+// exp и совпадение room со своим SM_ROOM_UUID.
+type Claims struct {
+	RoomID   string `json:"room"`
+	ClientID string `json:"sub"`
+	Team     int    `json:"team"`
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+}
+
+type Issuer struct {
+	alg       string
+	signKey   any
+	verifyKey any
+	ttl       time.Duration
+}
+
+func New(cfg *config.Config) (*Issuer, error) {
+	issuer := &Issuer{
+		alg: cfg.JoinTokens.Algorithm,
+		ttl: time.Duration(cfg.JoinTokens.TTL) * time.Second,
+	}
+
+	switch issuer.alg {
+	case jwt.EdDSA:
+		private, err := loadEd25519(cfg.JoinTokens.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		issuer.signKey = private
+		issuer.verifyKey = private.Public().(ed25519.PublicKey)
+	case jwt.HS256:
+		secret, err := loadSecret(cfg.JoinTokens.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		issuer.signKey = secret
+		issuer.verifyKey = secret
+	default:
+		return nil, fmt.Errorf("unsupported join token algorithm %q", issuer.alg)
+	}
+	return issuer, nil
+}
+
+func (i *Issuer) Issue(roomUUID, clientID string, team int) (string, error) {
+	now := time.Now()
+	return jwt.Sign(Claims{
+		RoomID:   roomUUID,
+		ClientID: clientID,
+		Team:     team,
+		IssuedAt: now.Unix(),
+		Expires:  now.Add(i.ttl).Unix(),
+	}, i.alg, i.signKey)
+}
+
+func (i *Issuer) Verify(token string, now time.Time) (*Claims, error) {
+	alg, _, err := jwt.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if alg != i.alg {
+		return nil, jwt.ErrUnsupportedAlg
+	}
+	var claims Claims
+	if err := jwt.Verify(token, i.verifyKey, &claims); err != nil {
+		return nil, err
+	}
+	if now.Unix() >= claims.Expires {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+func (i *Issuer) Algorithm() string {
+	return i.alg
+}
+
+// VerificationKey отдаётся игровому серверу в base64: публичный ключ Ed25519
+// или общий секрет HS256. С EdDSA сервер может только проверять токены.
+func (i *Issuer) VerificationKey() string {
+	switch key := i.verifyKey.(type) {
+	case ed25519.PublicKey:
+		return base64.StdEncoding.EncodeToString(key)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(key)
+	}
+	return ""
+}
+
+// loadEd25519 читает PKCS#8 PEM. Без файла ключ генерируется на время жизни процесса:
+// уже запущенные серверы сохраняют ключ, полученный при старте.
+func loadEd25519(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", path)
+	}
+	return private, nil
+}
+
+func loadSecret(path string) ([]byte, error) {
+	if path == "" {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		return secret, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret := bytes.TrimSpace(content)
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("%s: HMAC secret must be at least 32 bytes", path)
+	}
+	return secret, nil
+}
@@ -0,0 +1,96 @@
+package join_token_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/jwt"
+	join_token "github.com/Tagakama/ServerManager/internal/matchmaking/join-token"
+)
+
+func newIssuer(t *testing.T, alg, keyPath string) *join_token.Issuer {
+	issuer, err := join_token.New(&config.Config{JoinTokens: config.JoinTokens{Algorithm: alg, KeyPath: keyPath, TTL: 60}})
+	require.NoError(t, err)
+	return issuer
+}
+
+func TestIssuer_EdDSA_VerifiableWithPublicKeyOnly(t *testing.T) {
+	issuer := newIssuer(t, jwt.EdDSA, "")
+
+	token, err := issuer.Issue("room-uuid", "client1", 2)
+	require.NoError(t, err)
+
+	claims, err := issuer.Verify(token, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "room-uuid", claims.RoomID)
+	assert.Equal(t, "client1", claims.ClientID)
+	assert.Equal(t, 2, claims.Team)
+
+	// Игровой сервер знает только ключ из SM_JOIN_KEY
+	public, err := base64.StdEncoding.DecodeString(issuer.VerificationKey())
+	require.NoError(t, err)
+	var offline join_token.Claims
+	require.NoError(t, jwt.Verify(token, ed25519.PublicKey(public), &offline))
+	assert.Equal(t, *claims, offline)
+
+	_, err = issuer.Verify(token, time.Now().Add(2*time.Minute))
+	assert.ErrorIs(t, err, join_token.ErrExpired)
+
+	_, err = newIssuer(t, jwt.EdDSA, "").Verify(token, time.Now())
+	assert.ErrorIs(t, err, jwt.ErrInvalidSignature, "token from another key must be rejected")
+}
+
+func TestIssuer_HS256(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(keyPath, []byte("0123456789abcdef0123456789abcdef\n"), 0o600))
+	issuer := newIssuer(t, jwt.HS256, keyPath)
+
+	token, err := issuer.Issue("room-uuid", "client1", 1)
+	require.NoError(t, err)
+	_, err = issuer.Verify(token, time.Now())
+	require.NoError(t, err)
+
+	_, err = newIssuer(t, jwt.EdDSA, "").Verify(token, time.Now())
+	assert.ErrorIs(t, err, jwt.ErrUnsupportedAlg, "algorithm must not be switched by the token header")
+
+	require.NoError(t, os.WriteFile(keyPath, []byte("short"), 0o600))
+	_, err = join_token.New(&config.Config{JoinTokens: config.JoinTokens{Algorithm: jwt.HS256, KeyPath: keyPath}})
+	assert.Error(t, err)
+}
+
+func TestIssuer_LoadsEd25519Key(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "join.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	issuer := newIssuer(t, jwt.EdDSA, keyPath)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(private.Public().(ed25519.PublicKey)), issuer.VerificationKey())
+}
+
+func TestIssuer_TamperedToken(t *testing.T) {
+	issuer := newIssuer(t, jwt.EdDSA, "")
+	token, err := issuer.Issue("room-uuid", "client1", 1)
+	require.NoError(t, err)
+
+	forged, err := jwt.Sign(join_token.Claims{RoomID: "room-uuid", ClientID: "cheater", Expires: time.Now().Add(time.Hour).Unix()}, jwt.EdDSA, ed25519.NewKeyFromSeed(make([]byte, 32)))
+	require.NoError(t, err)
+	_, err = issuer.Verify(forged, time.Now())
+	assert.Error(t, err)
+
+	_, err = issuer.Verify(token[:len(token)-4], time.Now())
+	assert.Error(t, err)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/config"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/type"
@@ -20,10 +22,11 @@ type MockServerLauncher struct {
 	launched []*room.Room
 }
 
-func (m *MockServerLauncher) LaunchGameServer(r *room.Room) {
+func (m *MockServerLauncher) LaunchGameServer(r *room.Room) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.launched = append(m.launched, r)
+	return nil
 }
 
 func (m *MockServerLauncher) Count() int {
@@ -46,8 +49,13 @@ func TestMatchmaker_Distribution(t *testing.T) {
 		Launcher:     mockLauncher,
 	}
 
-	// Создаём пул воркеров
-	wp := workers.NewWorkerPool(workerCount, mm)
+	// Создаём пул воркеров: 1000 одновременных запросов не влезают в очередь,
+	// лишние ждут места, а не получают отказ
+	wp, err := workers.New(&config.Config{TCPServer: config.TCPServer{
+		WorkerCount: workerCount,
+		Queue:       config.Queue{Size: 100, Overflow: workers.OverflowBlock, BlockTimeout: 5},
+	}}, mm)
+	require.NoError(t, err)
 
 	// Запускаем 1000 подключений
 	var wg sync.WaitGroup
@@ -63,25 +71,28 @@ func TestMatchmaker_Distribution(t *testing.T) {
 					NumberOfPlayers: 1,
 				},
 			}
-			wp.AddTask(conn)
+			assert.NoError(t, wp.AddTask(conn))
 		}(i)
 	}
 
 	// Ждём выполнения
 	wg.Wait()
-	time.Sleep(500 * time.Millisecond) // даём воркерам завершить
-
-	// Проверяем
-	totalPlayers := 0
-	closedRooms := 0
-	for _, r := range mm.CurrentRooms {
-		totalPlayers += r.ReservedPlayers
-		if r.Closed {
-			closedRooms++
+	placed := func() (totalPlayers, closedRooms int) {
+		for _, info := range mm.Rooms() {
+			totalPlayers += info.Reserved
+			if !info.Filling {
+				closedRooms++
+			}
 		}
+		return totalPlayers, closedRooms
 	}
+	require.Eventually(t, func() bool {
+		totalPlayers, _ := placed()
+		return totalPlayers == totalConnections
+	}, 5*time.Second, 10*time.Millisecond, "Все игроки должны быть распределены")
 
-	assert.Equal(t, totalConnections, totalPlayers, "Все игроки должны быть распределены")
+	// Проверяем
+	_, closedRooms := placed()
 	require.GreaterOrEqual(t, closedRooms, totalConnections/maxPlayers, "Должно быть достаточно закрытых комнат")
 }
 
@@ -101,13 +112,15 @@ func TestRoom_Timeout(t *testing.T) {
 	}
 	r, err := room.New(settings)
 	require.NoError(t, err)
-	r.Timeout = time.Second
-	r.Timer.Reset(r.Timeout)
 
 	closedCh := make(chan struct{})
-	r.OnComplete = func(_ *room.Room) {
+	events := bus.New()
+	bus.Subscribe(events, "test", func(room.Filled) {
 		close(closedCh)
-	}
+	})
+	r.Events = events
+	r.Timeout = time.Second
+	r.Timer.Reset(r.Timeout)
 
 	mm.CurrentRooms = append(mm.CurrentRooms, r)
 
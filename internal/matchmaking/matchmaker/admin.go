@@ -0,0 +1,227 @@
+package matchmaker
+
+import (
+	"errors"
+	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	r "github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+	"sort"
+	"time"
+)
+
+// Коды ответа игрокам, которых оператор убрал из очереди.
+const (
+	CodeRoomCancelled = "room_cancelled"
+	CodeKicked        = "kicked"
+)
+
+var ErrRoomNotFound = errors.New("room not found")
+
+// ServerStopper - лаунчер, который умеет останавливать сервер отменённой комнаты.
+type ServerStopper interface {
+	StopGameServer(uuid string) error
+}
+
+// PoolInfo - комнаты одного пула: режим, карта и версия.
+type PoolInfo struct {
+	Mode           string `json:"mode"`
+	Map            string `json:"map"`
+	Version        string `json:"version"`
+	MaxPlayers     int    `json:"max_players"`
+	Teams          int    `json:"teams"`
+	Rooms          int    `json:"rooms"`
+	FillingRooms   int    `json:"filling_rooms"`
+	WaitingPlayers int    `json:"waiting_players"`
+}
+
+func (m *Matchmaker) Rooms() []r.Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	rooms := make([]r.Info, 0, len(m.CurrentRooms))
+	for _, room := range m.CurrentRooms {
+		rooms = append(rooms, room.Info(now))
+	}
+	return rooms
+}
+
+func (m *Matchmaker) Room(uuid string) (r.Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	room := m.findLocked(uuid)
+	if room == nil {
+		return r.Info{}, ErrRoomNotFound
+	}
+	return room.Info(time.Now()), nil
+}
+
+func (m *Matchmaker) Pools() []PoolInfo {
+	rooms := m.Rooms()
+	m.modesMu.RLock()
+	defer m.modesMu.RUnlock()
+	pools := make(map[[3]string]*PoolInfo)
+	for _, room := range rooms {
+		key := [3]string{room.Mode, room.Map, room.Version}
+		pool, ok := pools[key]
+		if !ok {
+			pool = &PoolInfo{Mode: room.Mode, Map: room.Map, Version: room.Version, MaxPlayers: room.MaxPlayers}
+			if mode, ok := m.Modes[room.Mode]; ok {
+				pool.Teams = mode.Teams
+			}
+			pools[key] = pool
+		}
+		pool.Rooms++
+		if room.Filling {
+			pool.FillingRooms++
+			pool.WaitingPlayers += room.Reserved
+		}
+	}
+
+	result := make([]PoolInfo, 0, len(pools))
+	for _, pool := range pools {
+		result = append(result, *pool)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Mode != b.Mode {
+			return a.Mode < b.Mode
+		}
+		if a.Map != b.Map {
+			return a.Map < b.Map
+		}
+		return a.Version < b.Version
+	})
+	return result
+}
+
+// ForceStart запускает матч, не дожидаясь заполнения комнаты.
+func (m *Matchmaker) ForceStart(uuid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	room := m.findLocked(uuid)
+	if room == nil {
+		return ErrRoomNotFound
+	}
+	if !room.Complete() {
+		return r.ErrClosed
+	}
+	log.Info("room force-started by operator", logger.RoomID(room.ID), logger.RoomUUID(room.UUID))
+	return nil
+}
+
+// CancelRoom отвечает ожидающим игрокам ошибкой и останавливает сервер комнаты.
+// Комнату, в которой уже идёт матч, отменить нельзя - только остановить её сервер.
+func (m *Matchmaker) CancelRoom(uuid, reason string) error {
+	m.mu.Lock()
+	room := m.findLocked(uuid)
+	if room == nil {
+		m.mu.Unlock()
+		return ErrRoomNotFound
+	}
+	players, err := room.Cancel()
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.removeRoomLocked(room)
+	m.mu.Unlock()
+
+	log.Info("room cancelled by operator", logger.RoomID(room.ID), logger.RoomUUID(room.UUID), "reason", reason)
+	if reason == "" {
+		reason = "room was cancelled by operator"
+	}
+	m.Bus.Publish(events.RoomCancelled{
+		Room:    room,
+		Players: players,
+		Err:     _type.NewError(CodeRoomCancelled, true, "%s", reason),
+		Outcome: events.OutcomeCancelled,
+		Filling: true,
+	})
+	m.stopServer(room)
+	return nil
+}
+
+// stopServer останавливает сервер комнаты, закрытой без матча. Запускающийся сервер
+// лаунчер остановит, как только тот стартует.
+func (m *Matchmaker) stopServer(room *r.Room) {
+	stopper, ok := m.Launcher.(ServerStopper)
+	if !ok {
+		return
+	}
+	err := stopper.StopGameServer(room.UUID)
+	if errors.Is(err, server_launcher.ErrNotRunning) {
+		log.Debug("server of closed room is not running", logger.RoomUUID(room.UUID))
+		return
+	}
+	if err != nil {
+		log.Warn("failed to stop server of closed room", logger.RoomUUID(room.UUID), logger.Err(err))
+	}
+}
+
+// KickPlayer убирает игрока из комнаты, которая ещё набирается.
+func (m *Matchmaker) KickPlayer(uuid, clientID string) error {
+	m.mu.Lock()
+	room := m.findLocked(uuid)
+	if room == nil {
+		m.mu.Unlock()
+		return ErrRoomNotFound
+	}
+	player, err := room.RemovePlayer(clientID)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Info("player kicked by operator", logger.RoomUUID(uuid), logger.ClientID(clientID))
+	m.rejectIn(uuid, player, _type.NewError(CodeKicked, false, "removed from room by operator"))
+	return nil
+}
+
+// Leave убирает из набирающейся комнаты запрос, клиент которого ушёл или замолчал, и
+// отвечает ему reason. false - запроса нет ни в одной набирающейся комнате: он ещё в
+// очереди пула или матч уже собран.
+func (m *Matchmaker) Leave(connection *_type.PendingConnection, reason error) bool {
+	m.mu.Lock()
+	var left *r.Room
+	for _, room := range m.CurrentRooms {
+		if room.RemoveConnection(connection) == nil {
+			left = room
+			break
+		}
+	}
+	m.mu.Unlock()
+	if left == nil {
+		return false
+	}
+
+	log.Info("player left room", logger.RoomUUID(left.UUID), logger.ClientID(connection.ConnectedMessage.ClientID), logger.Err(reason))
+	m.rejectIn(left.UUID, connection, reason)
+	return true
+}
+
+// Filling - сколько комнат ещё набирает игроков.
+func (m *Matchmaker) Filling() int {
+	filling := 0
+	for _, room := range m.Rooms() {
+		if room.Filling {
+			filling++
+		}
+	}
+	return filling
+}
+
+// CancelFilling отменяет все набирающиеся комнаты, например когда нода уходит на обслуживание.
+func (m *Matchmaker) CancelFilling(reason string) int {
+	cancelled := 0
+	for _, room := range m.Rooms() {
+		if !room.Filling {
+			continue
+		}
+		if err := m.CancelRoom(room.UUID, reason); err == nil {
+			cancelled++
+		}
+	}
+	return cancelled
+}
@@ -0,0 +1,124 @@
+package matchmaker
+
+import (
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	r "github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+// roomProgress - комната, игрокам которой ещё не ответили. Ответ уходит, когда
+// набор закрыт и сервер готов (MatchReady), или с ошибкой, если сервер до этого
+// не дожил (RoomCancelled).
+type roomProgress struct {
+	filled bool
+}
+
+func (m *Matchmaker) subscribeLifecycle() {
+	bus.Subscribe(m.Bus, "matchmaker", m.roomFilled)
+	bus.Subscribe(m.Bus, "matchmaker", m.serverStateChanged)
+	bus.Subscribe(m.Bus, "matchmaker", m.launchFailed)
+	bus.Subscribe(m.Bus, "matchmaker", m.roomEnded)
+}
+
+func (m *Matchmaker) roomFilled(e r.Filled) {
+	m.progressOf(e.Room).filled = true
+	// Сервер упал, пока комната набиралась: "running" отдавать некуда
+	if state := e.Room.State(); state == r.StateFailed || state == r.StateEnded {
+		m.abort(e.Room, _type.NewError(_type.CodeServerCrashed, true, "game server stopped before the match started"))
+		return
+	}
+	if ready(e.Room) {
+		m.matchReady(e.Room)
+	}
+}
+
+func (m *Matchmaker) serverStateChanged(e r.StateChanged) {
+	if e.State != r.StateReady {
+		return
+	}
+	if progress := m.findProgress(e.Room); progress != nil && progress.filled {
+		m.matchReady(e.Room)
+	}
+}
+
+func (m *Matchmaker) launchFailed(e events.LaunchFailed) {
+	// Комнату, сервер которой уже завершился, закрывает room.Ended
+	if e.Room.State() == r.StateEnded {
+		return
+	}
+	// Сервер успел сообщить о готовности через control API: комната живёт до room.Ended
+	if ready(e.Room) {
+		return
+	}
+	m.abort(e.Room, e.Err)
+}
+
+// roomEnded: комната с работающим сервером живёт до конца матча.
+func (m *Matchmaker) roomEnded(e r.Ended) {
+	if progress := m.findProgress(e.Room); progress != nil && progress.filled {
+		m.abort(e.Room, _type.NewError(_type.CodeServerCrashed, true, "game server stopped before the match started"))
+	}
+	m.forget(e.Room)
+	m.RemoveRoom(e.Room)
+}
+
+func (m *Matchmaker) matchReady(room *r.Room) {
+	m.forget(room)
+	log.Info("room filled, sending responses", logger.RoomID(room.ID), logger.RoomUUID(room.UUID), "players", len(room.PlayersSnapshot()))
+	m.Bus.Publish(events.MatchReady{Room: room})
+}
+
+// abort закрывает комнату без матча: её игроки получают reason.
+func (m *Matchmaker) abort(room *r.Room, reason error) {
+	m.forget(room)
+	m.mu.Lock()
+	players, err := room.Cancel()
+	filling := err == nil
+	if !filling {
+		players = room.PlayersSnapshot()
+	}
+	m.removeRoomLocked(room)
+	m.mu.Unlock()
+	room.MarkFailed()
+
+	log.Warn("room closed without a running server", logger.RoomID(room.ID), logger.RoomUUID(room.UUID), logger.Err(reason))
+	m.Bus.Publish(events.RoomCancelled{Room: room, Players: players, Err: reason, Outcome: events.OutcomeFailed, Filling: filling})
+	m.stopServer(room)
+}
+
+func ready(room *r.Room) bool {
+	select {
+	case <-room.ReadyC():
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *Matchmaker) progressOf(room *r.Room) *roomProgress {
+	m.progressMu.Lock()
+	defer m.progressMu.Unlock()
+	if m.progress == nil {
+		m.progress = make(map[string]*roomProgress)
+	}
+	progress, ok := m.progress[room.UUID]
+	if !ok {
+		progress = &roomProgress{}
+		m.progress[room.UUID] = progress
+	}
+	return progress
+}
+
+func (m *Matchmaker) findProgress(room *r.Room) *roomProgress {
+	m.progressMu.Lock()
+	defer m.progressMu.Unlock()
+	return m.progress[room.UUID]
+}
+
+func (m *Matchmaker) forget(room *r.Room) {
+	m.progressMu.Lock()
+	defer m.progressMu.Unlock()
+	delete(m.progress, room.UUID)
+}
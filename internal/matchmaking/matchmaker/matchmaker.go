@@ -1,13 +1,14 @@
 package matchmaker
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/config"
 	server_launcher "github.com/Tagakama/ServerManager/internal/game-server/server-launcher"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
 	r "github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
 	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
-	"log"
 	"sync"
 )
 
@@ -16,79 +17,170 @@ type RoomCloser interface {
 	RemoveClosedRoom()
 }
 
+type VersionResolver interface {
+	Resolve(version, mapName string) (*versions.Build, error)
+}
+
+type TokenIssuer interface {
+	Issue(roomUUID, clientID string, team int) (string, error)
+}
+
+// Maintenance - режим обслуживания ноды: Err не nil, пока новые комнаты создавать нельзя.
+type Maintenance interface {
+	Err() error
+}
+
 type Matchmaker struct {
 	CurrentRooms []*r.Room
 	mu           sync.Mutex
 	Launcher     server_launcher.Launcher
+	Versions     VersionResolver
+	Modes        map[string]config.Mode
+	JoinTokens   TokenIssuer
+	Maintenance  Maintenance
+	// Bus - события комнат и тикетов. Журнал, история, вебхуки и прочие интеграции
+	// подписываются на неё сами, матчмейкер о них не знает
+	Bus *bus.Bus
+
+	progressMu sync.Mutex
+	progress   map[string]*roomProgress
+	// modesMu защищает Modes: конфиг перечитывается на ходу через SetModes
+	modesMu sync.RWMutex
 }
 
+const DefaultMode = "default"
+
 var roomsCount = 1
 
+var log = logger.For("matchmaker")
+
+// New подписывает на шину сам матчмейкер, ответы клиентам, метрики и запуск серверов через launcher.
 func New(launcher server_launcher.Launcher) *Matchmaker {
-	return &Matchmaker{
+	m := &Matchmaker{
 		CurrentRooms: make([]*r.Room, 0),
 		mu:           sync.Mutex{},
 		Launcher:     launcher,
+		Bus:          bus.New(),
 	}
+	m.subscribeLifecycle()
+	m.subscribeNotifications()
+	m.subscribeMetrics()
+	server_launcher.Subscribe(m.Bus, launcher)
+	return m
 }
 
 func (m *Matchmaker) AddNewRoom(connection *_type.PendingConnection) error {
+	modeName, mode := m.modeFor(connection)
 	newRoomSettings := _type.RoomSettings{
 		ID:         roomsCount,
-		MaxPlayers: 8,
+		MaxPlayers: mode.MaxPlayers,
+		Teams:      mode.Teams,
+		Mode:       modeName,
 		CurrentMap: connection.ConnectedMessage.MapName,
 		AppVersion: connection.ConnectedMessage.AppVersion,
 	}
 	newRoom, err := r.New(newRoomSettings)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Error creating new room :%s", err))
-	}
-
-	serverStart := m.Launcher.LaunchGameServer(newRoom)
-
-	if !serverStart {
-		fmt.Printf("The server did not start!\n")
+		log.Error("failed to create room", logger.ClientID(connection.ConnectedMessage.ClientID), logger.Err(err))
+		return _type.NewError(_type.CodeRoomCreateFailed, true, "failed to create room")
 	}
 
-	newRoom.OnComplete = func(r *r.Room) {
-		m.RoomCopmlete(r)
-	}
-
-	//m.mu.Lock()
-	m.CurrentRooms = append(m.CurrentRooms, newRoom)
+	newRoom.Events = m.Bus
+	newRoom.Trace = connection.Stage
 	roomsCount++
-	//m.mu.Unlock()
 
+	// Сервер запускает подписчик RoomCreated; комната принимает игроков, пока он стартует
+	m.CurrentRooms = append(m.CurrentRooms, newRoom)
+	m.Bus.Publish(events.RoomCreated{Room: newRoom})
 	return nil
 }
 
 func (m *Matchmaker) InviteInRoom(connection *_type.PendingConnection) {
-	playersCount := connection.ConnectedMessage.NumberOfPlayers
+	connection.Stage = connection.Trace.Child("InviteInRoom")
+
+	var versionErr error
+	if m.Versions != nil {
+		build, err := m.Versions.Resolve(connection.ConnectedMessage.AppVersion, connection.ConnectedMessage.MapName)
+		if build == nil {
+			m.Reject(connection, err)
+			return
+		}
+		connection.ConnectedMessage.AppVersion = build.Version
+		connection.Warning = build.Warning()
+		versionErr = err
+	}
+
+	modeName, _ := m.modeFor(connection)
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.removeClosedRoomLocked()
+
+	// Проверка под m.mu: отменённый после неё запрос уже в комнате, и его уберёт Leave
+	if connection.Cancelled() {
+		log.Debug("request was cancelled before it got a room", logger.ClientID(connection.ConnectedMessage.ClientID))
+		return
+	}
 
 	added := false
 
+	// Комнату, закрытую таймером между проверкой и добавлением, пропускаем и ищем дальше
 	for _, room := range m.CurrentRooms {
-		if room.CheckingFreeSpace(playersCount) {
-			room.AddPlayer(connection)
+		if room.Accepts(connection, modeName) && m.join(room, connection, modeName) == nil {
 			added = true
 			break
 		}
 	}
 
+	// Версия на draining: доукомплектовать открытую комнату можно, новую создавать нельзя
+	if !added && versionErr != nil {
+		m.Reject(connection, versionErr)
+		return
+	}
+
+	// Нода выводится из ротации: набирающиеся комнаты доукомплектовываются, новые не создаются
+	if !added && m.Maintenance != nil {
+		if err := m.Maintenance.Err(); err != nil {
+			m.Reject(connection, err)
+			return
+		}
+	}
+
 	// ❗ Только если не добавили — создаём новую комнату
 	if !added {
 		m.addAndAssign(connection)
 	}
 }
 
+// modeFor выбирает режим по полю Message; неизвестные значения ("join" и т.п.) идут в режим по умолчанию.
+func (m *Matchmaker) modeFor(connection *_type.PendingConnection) (string, config.Mode) {
+	m.modesMu.RLock()
+	name := connection.ConnectedMessage.Message
+	mode, ok := m.Modes[name]
+	if !ok {
+		name = DefaultMode
+		mode = m.Modes[DefaultMode]
+	}
+	m.modesMu.RUnlock()
+	if mode.MaxPlayers <= 0 {
+		mode.MaxPlayers = 8
+	}
+	if mode.Teams <= 0 {
+		mode.Teams = 1
+	}
+	return name, mode
+}
+
+// SetModes применяется к новым комнатам; уже созданные доигрывают со своими настройками.
+func (m *Matchmaker) SetModes(modes map[string]config.Mode) {
+	m.modesMu.Lock()
+	defer m.modesMu.Unlock()
+	m.Modes = modes
+}
+
 func (m *Matchmaker) removeClosedRoomLocked() {
 	var activeRooms []*r.Room
 	for _, room := range m.CurrentRooms {
-		if !room.Closed {
+		if !room.IsClosed() {
 			activeRooms = append(activeRooms, room)
 		}
 	}
@@ -104,7 +196,10 @@ func (m *Matchmaker) RemoveClosedRoom() {
 func (m *Matchmaker) RemoveRoom(closedRoom *r.Room) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.removeRoomLocked(closedRoom)
+}
 
+func (m *Matchmaker) removeRoomLocked(closedRoom *r.Room) {
 	var updatedRooms []*r.Room
 	for _, room := range m.CurrentRooms {
 		if room != closedRoom {
@@ -117,46 +212,65 @@ func (m *Matchmaker) RemoveRoom(closedRoom *r.Room) {
 func (m *Matchmaker) addAndAssign(connection *_type.PendingConnection) {
 	err := m.AddNewRoom(connection)
 	if err != nil {
-		fmt.Errorf("Error adding new room :%s", err)
+		m.Reject(connection, err)
+		return
 	}
 	lastRoom := m.CurrentRooms[len(m.CurrentRooms)-1]
-	lastRoom.AddPlayer(connection)
+	modeName, _ := m.modeFor(connection)
+	if err := m.join(lastRoom, connection, modeName); err != nil {
+		log.Error("new room did not accept its first player", logger.RoomUUID(lastRoom.UUID), logger.ClientID(connection.ConnectedMessage.ClientID), logger.Err(err))
+		m.Reject(connection, _type.NewError(_type.CodeRoomCreateFailed, true, "failed to create room"))
+	}
 }
 
-func (m *Matchmaker) RoomCopmlete(r *r.Room) {
-
-	//serverStart := m.Launcher.LaunchGameServer(r)
-	//
-	//if !serverStart {
-	//	fmt.Printf("The server did not start!\n")
-	//} ПЕРЕНЕСЕНО в AddNEwRoom
-
-	fmt.Printf("Room %d has closed , sending response!\n", r.ID)
-
-	m.SendResponse(r)
-
-	m.removeClosedRoomLocked()
+// join занимает место в комнате и закрывает span InviteInRoom: дальше запрос ждёт
+// заполнения комнаты. Ошибка - комната не приняла игрока, span не тронут.
+func (m *Matchmaker) join(room *r.Room, connection *_type.PendingConnection, mode string) error {
+	// Этап меняется до TryAdd: как только игрок в комнате, ответ ему может уйти из
+	// подписчика Filled. Незакрытый span отклонённой попытки никуда не выгружается
+	invite := connection.Stage
+	connection.Stage = connection.Trace.Child("room.fill_wait")
+	connection.Stage.SetAttr("room_uuid", room.UUID)
+	// PlayerJoined публикуется комнатой до её Filled: подписчики должны увидеть игрока раньше
+	if err := room.TryAdd(connection, mode, events.PlayerJoined{Room: room, Player: connection}); err != nil {
+		connection.Stage = invite
+		return err
+	}
+	invite.SetAttr("room_uuid", room.UUID)
+	invite.End()
+	return nil
 }
 
-func (m *Matchmaker) SendResponse(r *r.Room) {
-	unicName := fmt.Sprintf("%s%s%d", r.AppVersion, r.CurrentMap, r.ID)
-
-	newResponse := _type.Response{
-		Status:  "running",
-		IP:      unicName,
-		MapName: r.CurrentMap,
+// RestoreRoomCounter продолжает нумерацию комнат после рестарта, чтобы ID в журнале не повторялись.
+func (m *Matchmaker) RestoreRoomCounter(next int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if next > roomsCount {
+		roomsCount = next
 	}
+}
 
-	response, err := json.Marshal(newResponse)
-	if err != nil {
-		fmt.Errorf("Error marshalling response :%s", err)
-	}
-	fmt.Printf("New marshall response: %s.\n", string(response))
-	for _, player := range r.Players {
-		_, err = fmt.Fprintf(player.Conn, "%s", string(response))
-		if err != nil {
-			log.Printf("Failed to send response to player: %v", err)
+func (m *Matchmaker) FindRoom(uuid string) *r.Room {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findLocked(uuid)
+}
+
+func (m *Matchmaker) findLocked(uuid string) *r.Room {
+	for _, room := range m.CurrentRooms {
+		if room.UUID == uuid {
+			return room
 		}
-		player.Conn.Close()
 	}
+	return nil
+}
+
+// Reject отвечает запросу ошибкой. Ответ отправляет подписчик шины, поэтому
+// Reject можно вызывать под блокировкой матчмейкера.
+func (m *Matchmaker) Reject(connection *_type.PendingConnection, reason error) {
+	m.rejectIn("", connection, reason)
+}
+
+func (m *Matchmaker) rejectIn(roomUUID string, connection *_type.PendingConnection, reason error) {
+	m.Bus.Publish(events.PlayerRejected{RoomUUID: roomUUID, Player: connection, Err: reason})
 }
@@ -1,12 +1,22 @@
 package matchmaker_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/game-server/versions"
+	"github.com/Tagakama/ServerManager/internal/maintenance"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/type"
+	"github.com/Tagakama/ServerManager/internal/tracing"
+	"github.com/Tagakama/ServerManager/internal/webhooks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"sync"
 	"testing"
@@ -62,6 +72,30 @@ func TestMatchmaker_InviteInRoom(t *testing.T) {
 	}
 }
 
+func TestMatchmaker_LeaveFreesSlot(t *testing.T) {
+	m := matchmaker.New(&MockServerLauncher{})
+	quiet := mockConnection("quiet", "map1", 1)
+	m.InviteInRoom(quiet)
+	require.Len(t, m.Rooms(), 1)
+
+	assert.True(t, m.Leave(quiet, _type.NewError("timeout", true, "idle timeout")))
+	assert.Zero(t, m.Rooms()[0].Reserved, "idle player's slot is freed")
+	assert.False(t, m.Leave(quiet, nil), "player is no longer in a room")
+
+	for i := 0; i < 8; i++ {
+		m.InviteInRoom(mockConnection("client"+strconv.Itoa(i), "map1", 1))
+	}
+	assert.False(t, m.Rooms()[0].Filling, "room fills with eight other players")
+}
+
+func TestMatchmaker_SkipsCancelledRequest(t *testing.T) {
+	m := matchmaker.New(&MockServerLauncher{})
+	gone := mockConnection("gone", "map1", 1)
+	gone.Cancel()
+	m.InviteInRoom(gone)
+	assert.Empty(t, m.Rooms(), "cancelled request must not take a slot")
+}
+
 func TestMatchmaker_RemoveClosedRoom_RemovesMultipleClosedRooms(t *testing.T) {
 	mockLauncher := &MockServerLauncher{}
 	mm := matchmaker.New(mockLauncher)
@@ -196,11 +230,10 @@ func TestMatchmaker_RoomTimeoutClosure(t *testing.T) {
 	mockLauncher := &MockServerLauncher{}
 	mm := matchmaker.New(mockLauncher)
 
-	// Переопределим таймаут через OnComplete
 	done := make(chan struct{}, 1)
-
-	// Сохраним ссылку на первую комнату
-	var createdRoom *room.Room
+	bus.Subscribe(mm.Bus, "test", func(room.Filled) {
+		done <- struct{}{}
+	})
 
 	// 3 подключения
 	for i := 0; i < 3; i++ {
@@ -216,31 +249,11 @@ func TestMatchmaker_RoomTimeoutClosure(t *testing.T) {
 		mm.InviteInRoom(conn)
 	}
 
-	// Получаем первую созданную комнату
+	// Получаем первую созданную комнату и ставим ей короткий таймаут
 	require.NotEmpty(t, mm.CurrentRooms)
-	createdRoom = mm.CurrentRooms[0]
-
-	// Устанавливаем короткий таймаут и OnComplete
-	createdRoom.Mutex.Lock()
+	createdRoom := mm.CurrentRooms[0]
 	createdRoom.Timeout = 150 * time.Millisecond
-	createdRoom.Timer = time.NewTimer(createdRoom.Timeout)
-	createdRoom.OnComplete = func(r *room.Room) {
-		done <- struct{}{}
-	}
-	createdRoom.Mutex.Unlock()
-
-	// Запускаем таймер вручную (так как ты вызываешь таймер в New, но в тесте мы его пересоздали)
-	go func(r *room.Room) {
-		<-r.Timer.C
-		r.Mutex.Lock()
-		defer r.Mutex.Unlock()
-		if !r.Closed {
-			r.Closed = true
-			if r.OnComplete != nil {
-				r.OnComplete(r)
-			}
-		}
-	}(createdRoom)
+	createdRoom.Timer.Reset(createdRoom.Timeout)
 
 	// Ждём до 500 мс, пока сработает таймер
 	select {
@@ -250,5 +263,254 @@ func TestMatchmaker_RoomTimeoutClosure(t *testing.T) {
 		t.Fatal("Room was not closed by timeout")
 	}
 
+	createdRoom.Mutex.Lock()
+	defer createdRoom.Mutex.Unlock()
 	assert.True(t, createdRoom.Closed, "Room should be closed after timeout")
 }
+
+type rejectingVersions struct{}
+
+func (rejectingVersions) Resolve(version, mapName string) (*versions.Build, error) {
+	return nil, &versions.Error{Code: versions.CodeUnknownVersion, Version: version}
+}
+
+func TestMatchmaker_RejectsUnknownVersion(t *testing.T) {
+	mm := matchmaker.New(&MockServerLauncher{})
+	mm.Versions = rejectingVersions{}
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := mockConnection("client1", "map1", 1)
+	conn.Conn = server
+	go mm.InviteInRoom(conn)
+
+	var response _type.ErrorResponse
+	require.NoError(t, json.NewDecoder(client).Decode(&response))
+	assert.Equal(t, "error", response.Status)
+	assert.Equal(t, versions.CodeUnknownVersion, response.Code)
+	assert.Empty(t, mm.CurrentRooms)
+}
+
+func TestMatchmaker_MaintenanceFillsOpenRoomsOnly(t *testing.T) {
+	mm := matchmaker.New(&MockServerLauncher{})
+	node := maintenance.New(&config.Config{Maintenance: config.Maintenance{Deadline: 60}})
+	node.Active = func() int { return 1 }
+	mm.Maintenance = node
+
+	mm.InviteInRoom(mockConnection("first", "map1", 1))
+	require.Len(t, mm.CurrentRooms, 1)
+	retryAt := time.Now().Add(10 * time.Minute)
+	require.NoError(t, node.Drain("test", maintenance.Options{RetryAt: retryAt}))
+	defer node.Stop()
+
+	// Набирающаяся комната на той же карте доукомплектовывается
+	mm.InviteInRoom(mockConnection("second", "map1", 1))
+	assert.Equal(t, 2, mm.CurrentRooms[0].ReservedPlayers)
+
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := mockConnection("other", "map2", 1)
+	conn.Conn = server
+	go mm.InviteInRoom(conn)
+
+	var response _type.ErrorResponse
+	require.NoError(t, json.NewDecoder(client).Decode(&response))
+	assert.Equal(t, maintenance.CodeMaintenance, response.Code)
+	assert.True(t, response.Retryable)
+	assert.InDelta(t, 600, response.RetryAfter, 2)
+	assert.Contains(t, response.Message, retryAt.UTC().Format(time.RFC3339))
+	assert.Len(t, mm.CurrentRooms, 1, "no new rooms while draining")
+}
+
+func TestMatchmaker_RoomRemovedWhenMatchEnds(t *testing.T) {
+	mm := matchmaker.New(&MockServerLauncher{})
+
+	for i := 0; i < 8; i++ {
+		mm.InviteInRoom(mockConnection(fmt.Sprintf("client-%d", i), "map1", 1))
+	}
+	require.Len(t, mm.CurrentRooms, 1)
+	full := mm.CurrentRooms[0]
+	assert.True(t, full.Closed)
+	assert.Same(t, full, mm.FindRoom(full.UUID), "full room stays until its match ends")
+
+	mm.InviteInRoom(mockConnection("late", "map1", 1))
+	require.Len(t, mm.CurrentRooms, 2, "closed room must not accept players")
+
+	full.End(nil)
+	mm.Bus.Wait()
+	assert.Nil(t, mm.FindRoom(full.UUID))
+	assert.Len(t, mm.Rooms(), 1)
+}
+
+type failingLauncher struct{}
+
+func (failingLauncher) LaunchGameServer(*room.Room) error {
+	return _type.NewError(_type.CodeLaunchFailed, true, "game server failed to start")
+}
+
+func TestMatchmaker_FailedLaunchIsReported(t *testing.T) {
+	mm := matchmaker.New(failingLauncher{})
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := mockConnection("client1", "map1", 1)
+	conn.Conn = server
+	go mm.InviteInRoom(conn)
+
+	var response _type.ErrorResponse
+	require.NoError(t, json.NewDecoder(client).Decode(&response))
+	assert.Equal(t, "error", response.Status, "failed launch must not be reported as running")
+	assert.Equal(t, _type.CodeLaunchFailed, response.Code)
+	assert.True(t, response.Retryable)
+	assert.Empty(t, mm.Rooms())
+}
+
+func TestMatchmaker_ServerCrashWhileFilling(t *testing.T) {
+	mm := matchmaker.New(&MockServerLauncher{})
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := mockConnection("client1", "map1", 1)
+	conn.Conn = server
+	mm.InviteInRoom(conn)
+	require.Len(t, mm.CurrentRooms, 1)
+
+	// Процесс сервера завершился до заполнения комнаты
+	crashed := mm.CurrentRooms[0]
+	go crashed.End(nil)
+
+	var response _type.ErrorResponse
+	require.NoError(t, json.NewDecoder(client).Decode(&response))
+	assert.Equal(t, _type.CodeServerCrashed, response.Code)
+	assert.Eventually(t, func() bool { return mm.FindRoom(crashed.UUID) == nil }, time.Second, 10*time.Millisecond)
+}
+
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *memoryExporter) Export(span tracing.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func (e *memoryExporter) Close() error { return nil }
+
+func (e *memoryExporter) named(name string) []tracing.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var result []tracing.SpanData
+	for _, span := range e.spans {
+		if span.Name == name {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+func TestMatchmaker_TicketSpans(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := tracing.NewTracer("test", exporter)
+	mm := matchmaker.New(&MockServerLauncher{})
+
+	tickets := make(map[string]string)
+	for i := 0; i < 8; i++ {
+		conn := mockConnection(fmt.Sprintf("client-%d", i), "map1", 1)
+		conn.Trace = tracer.Start("matchmaking.ticket")
+		tickets[conn.Trace.TraceID()] = conn.ConnectedMessage.ClientID
+		mm.InviteInRoom(conn)
+	}
+	require.Len(t, mm.CurrentRooms, 1)
+	assert.NotNil(t, mm.CurrentRooms[0].Trace, "launch is traced under the ticket that created the room")
+
+	require.Eventually(t, func() bool { return len(exporter.named("matchmaking.ticket")) == 8 }, time.Second, 10*time.Millisecond)
+	for _, stage := range []string{"InviteInRoom", "room.fill_wait"} {
+		spans := exporter.named(stage)
+		require.Len(t, spans, 8, stage)
+		for _, span := range spans {
+			assert.Contains(t, tickets, span.TraceID, stage)
+			assert.Equal(t, mm.CurrentRooms[0].UUID, span.Attributes["room_uuid"], stage)
+		}
+	}
+	for _, ticket := range exporter.named("matchmaking.ticket") {
+		assert.Empty(t, ticket.ParentSpanID)
+		assert.Equal(t, mm.CurrentRooms[0].UUID, ticket.Attributes["room_uuid"])
+	}
+}
+
+// readyLauncher - сервер становится готовым сразу при запуске.
+type readyLauncher struct{}
+
+func (readyLauncher) LaunchGameServer(r *room.Room) error {
+	r.MarkReady()
+	return nil
+}
+
+// webhookBackend - получатель вебхуков; возвращает типы событий по порядку прихода.
+func webhookBackend(t *testing.T, mm *matchmaker.Matchmaker) func() []string {
+	var mu sync.Mutex
+	var received []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get(webhooks.HeaderEvent))
+	}))
+	t.Cleanup(backend.Close)
+
+	notifier, err := webhooks.New(&config.Config{Webhooks: config.Webhooks{Endpoints: []config.WebhookEndpoint{{URL: backend.URL, Secret: "secret"}}}})
+	require.NoError(t, err)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go notifier.Run(stop)
+	notifier.Subscribe(mm.Bus)
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestMatchmaker_Webhooks(t *testing.T) {
+	mm := matchmaker.New(readyLauncher{})
+	mm.Modes = map[string]config.Mode{"test": {MaxPlayers: 2}}
+	events := webhookBackend(t, mm)
+	mm.InviteInRoom(mockConnection("client1", "map1", 1))
+	mm.InviteInRoom(mockConnection("client2", "map1", 1))
+	require.Eventually(t, func() bool { return len(events()) == 3 }, 2*time.Second, 10*time.Millisecond)
+	// Сервер запускается, пока комната набирается: готовность и заполнение идут в любом порядке
+	assert.Equal(t, webhooks.RoomCreated, events()[0])
+	assert.ElementsMatch(t, []string{webhooks.ServerReady, webhooks.RoomFilled}, events()[1:])
+
+	mm.CurrentRooms[0].End(json.RawMessage(`{"winner":1}`))
+	require.Eventually(t, func() bool { return len(events()) == 4 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, webhooks.MatchEnded, events()[3])
+}
+
+// slowFailingLauncher - запуск падает, когда тест его отпустит.
+type slowFailingLauncher struct {
+	release chan struct{}
+}
+
+func (l slowFailingLauncher) LaunchGameServer(*room.Room) error {
+	<-l.release
+	return _type.NewError(_type.CodeLaunchFailed, true, "game server failed to start")
+}
+
+func TestMatchmaker_WebhookFilledBeforeLaunchFailed(t *testing.T) {
+	launcher := slowFailingLauncher{release: make(chan struct{})}
+	mm := matchmaker.New(launcher)
+	mm.Modes = map[string]config.Mode{"test": {MaxPlayers: 2}}
+	events := webhookBackend(t, mm)
+	mm.InviteInRoom(mockConnection("client1", "map1", 1))
+	mm.InviteInRoom(mockConnection("client2", "map1", 1))
+	require.Eventually(t, func() bool { return len(events()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	close(launcher.release)
+
+	require.Eventually(t, func() bool { return len(events()) == 3 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{webhooks.RoomCreated, webhooks.RoomFilled, webhooks.LaunchFailed}, events())
+}
@@ -0,0 +1,47 @@
+package matchmaker
+
+import (
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	r "github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"time"
+)
+
+// Пул комнат - режим, карта и версия: игроки из разных пулов не смешиваются.
+var (
+	roomsOpened = metrics.Default.Counter("sm_rooms_opened_total", "Rooms created, per pool.", "mode", "map", "version")
+	roomsClosed = metrics.Default.Counter("sm_rooms_closed_total", "Rooms that stopped accepting players, per pool and outcome.", "mode", "map", "version", "outcome")
+	roomsOpen   = metrics.Default.Gauge("sm_rooms_open", "Rooms currently accepting players, per pool.", "mode", "map", "version")
+	roomPlayers = metrics.Default.Histogram("sm_room_players", "Players in a room when its match starts.", metrics.CountBuckets, "mode")
+	timeToMatch = metrics.Default.Histogram("sm_time_to_match_seconds", "Time from accepting a request to sending the game server address.", metrics.DurationBuckets, "mode")
+)
+
+func poolLabels(room *r.Room, extra ...string) []string {
+	return append([]string{room.Mode, room.CurrentMap, room.AppVersion}, extra...)
+}
+
+func (m *Matchmaker) subscribeMetrics() {
+	bus.Subscribe(m.Bus, "metrics", func(e events.RoomCreated) {
+		roomsOpened.Inc(poolLabels(e.Room)...)
+		roomsOpen.Inc(poolLabels(e.Room)...)
+	})
+	bus.Subscribe(m.Bus, "metrics", func(e r.Filled) {
+		roomsOpen.Dec(poolLabels(e.Room)...)
+	})
+	bus.Subscribe(m.Bus, "metrics", func(e events.MatchReady) {
+		roomsClosed.Inc(poolLabels(e.Room, "filled")...)
+		roomPlayers.Observe(float64(e.Room.ReservedPlayers), e.Room.Mode)
+		for _, player := range e.Room.PlayersSnapshot() {
+			if !player.AcceptedAt.IsZero() {
+				timeToMatch.Observe(time.Since(player.AcceptedAt).Seconds(), e.Room.Mode)
+			}
+		}
+	})
+	bus.Subscribe(m.Bus, "metrics", func(e events.RoomCancelled) {
+		if e.Filling {
+			roomsOpen.Dec(poolLabels(e.Room)...)
+		}
+		roomsClosed.Inc(poolLabels(e.Room, e.Outcome)...)
+	})
+}
@@ -0,0 +1,102 @@
+package matchmaker
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	r "github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+	"time"
+)
+
+// writeTimeout - сколько ждём клиента с ответом: медленный клиент не должен
+// задерживать очередь событий своей комнаты.
+const writeTimeout = 5 * time.Second
+
+// subscribeNotifications - ответы клиентам: адрес сервера или ошибка, после чего соединение закрывается.
+func (m *Matchmaker) subscribeNotifications() {
+	bus.Subscribe(m.Bus, "notifications", func(e events.MatchReady) {
+		m.SendResponse(e.Room)
+	})
+	bus.Subscribe(m.Bus, "notifications", func(e events.RoomCancelled) {
+		for _, player := range e.Players {
+			m.respondError(player, e.Err)
+		}
+	})
+	bus.Subscribe(m.Bus, "notifications", func(e events.PlayerRejected) {
+		m.respondError(e.Player, e.Err)
+	})
+}
+
+func (m *Matchmaker) SendResponse(r *r.Room) {
+	for _, player := range r.PlayersSnapshot() {
+		newResponse := _type.Response{
+			Status:  "running",
+			IP:      r.SessionName,
+			MapName: r.CurrentMap,
+			Team:    player.Team,
+			Warning: player.Warning,
+		}
+		if m.JoinTokens != nil {
+			token, err := m.JoinTokens.Issue(r.UUID, player.ConnectedMessage.ClientID, player.Team)
+			if err != nil {
+				log.Error("failed to issue join token", logger.RoomID(r.ID), logger.ClientID(player.ConnectedMessage.ClientID), logger.Err(err))
+				m.rejectIn(r.UUID, player, _type.NewError(_type.CodeTokenFailed, true, "failed to issue join token"))
+				continue
+			}
+			newResponse.Token = token
+		}
+
+		response, err := json.Marshal(newResponse)
+		if err != nil {
+			log.Error("failed to marshal response", logger.RoomID(r.ID), logger.Err(err))
+		}
+		log.Debug("sending response", logger.RoomID(r.ID), logger.ClientID(player.ConnectedMessage.ClientID), "team", player.Team)
+		player.Stage.End()
+		player.Trace.SetAttr("room_uuid", r.UUID)
+		if player.Conn == nil {
+			player.Trace.End()
+			continue
+		}
+		send := player.Trace.Child("response.send")
+		player.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		_, err = fmt.Fprintf(player.Conn, "%s", string(response))
+		if err != nil {
+			log.Warn("failed to send response", logger.RoomID(r.ID), logger.ClientID(player.ConnectedMessage.ClientID), logger.Err(err))
+		}
+		player.Conn.Close()
+		send.SetError(err)
+		send.End()
+		player.Trace.End()
+	}
+}
+
+func (m *Matchmaker) respondError(connection *_type.PendingConnection, reason error) {
+	newResponse := _type.NewErrorResponse(reason)
+	newResponse.MapName = connection.ConnectedMessage.MapName
+
+	log.Info("request rejected", logger.ClientID(connection.ConnectedMessage.ClientID), "code", newResponse.Code, logger.Err(reason))
+	connection.Stage.SetError(reason)
+	connection.Stage.End()
+	connection.Trace.SetError(reason)
+	defer connection.Trace.End()
+	if connection.Conn == nil {
+		return
+	}
+	send := connection.Trace.Child("response.send")
+	defer send.End()
+
+	response, err := json.Marshal(newResponse)
+	if err != nil {
+		log.Error("failed to marshal response", logger.ClientID(connection.ConnectedMessage.ClientID), logger.Err(err))
+		return
+	}
+	connection.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err = fmt.Fprintf(connection.Conn, "%s", string(response))
+	if err != nil {
+		log.Warn("failed to send response", logger.ClientID(connection.ConnectedMessage.ClientID), logger.Err(err))
+	}
+	connection.Conn.Close()
+}
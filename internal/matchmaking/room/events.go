@@ -0,0 +1,34 @@
+package room
+
+import "github.com/Tagakama/ServerManager/internal/bus"
+
+// События комнаты публикуются в Room.Events; ключ - UUID комнаты, так что
+// подписчик получает их в том порядке, в котором они произошли.
+
+// Filled - набор игроков закрыт: комната заполнилась, сработал таймер, оператор
+// запустил её досрочно или сервер завершился, пока она набиралась.
+type Filled struct {
+	Room *Room
+}
+
+// StateChanged - сменилось состояние игрового сервера комнаты.
+type StateChanged struct {
+	Room  *Room
+	State string
+}
+
+// Ended - матч завершён отчётом сервера или выходом его процесса.
+type Ended struct {
+	Room *Room
+}
+
+func (e Filled) Key() string       { return e.Room.UUID }
+func (e StateChanged) Key() string { return e.Room.UUID }
+func (e Ended) Key() string        { return e.Room.UUID }
+
+// publish можно вызывать под блокировкой комнаты: шина не вызывает подписчиков синхронно.
+func (room *Room) publish(event bus.Event) {
+	if room.Events != nil {
+		room.Events.Publish(event)
+	}
+}
@@ -0,0 +1,55 @@
+package room
+
+import "time"
+
+// Info - снимок комнаты для admin API.
+type Info struct {
+	ID         int          `json:"id"`
+	UUID       string       `json:"uuid"`
+	Mode       string       `json:"mode"`
+	Map        string       `json:"map"`
+	Version    string       `json:"version"`
+	State      string       `json:"state"`
+	Filling    bool         `json:"filling"`
+	Players    []PlayerInfo `json:"players"`
+	Reserved   int          `json:"reserved"`
+	MaxPlayers int          `json:"max_players"`
+	CreatedAt  time.Time    `json:"created_at"`
+	AgeSeconds float64      `json:"age_seconds"`
+}
+
+// PlayerInfo: Size - число игроков в группе, Connected - сервер сообщил о подключении.
+type PlayerInfo struct {
+	ClientID  string `json:"client_id"`
+	Team      int    `json:"team"`
+	Size      int    `json:"size"`
+	Connected bool   `json:"connected"`
+}
+
+func (room *Room) Info(now time.Time) Info {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	info := Info{
+		ID:         room.ID,
+		UUID:       room.UUID,
+		Mode:       room.Mode,
+		Map:        room.CurrentMap,
+		Version:    room.AppVersion,
+		State:      room.state,
+		Filling:    !room.Closed,
+		Players:    make([]PlayerInfo, 0, len(room.Players)),
+		Reserved:   room.ReservedPlayers,
+		MaxPlayers: room.MaxPlayers,
+		CreatedAt:  room.StartedAt,
+		AgeSeconds: now.Sub(room.StartedAt).Seconds(),
+	}
+	for _, player := range room.Players {
+		info.Players = append(info.Players, PlayerInfo{
+			ClientID:  player.ConnectedMessage.ClientID,
+			Team:      player.Team,
+			Size:      player.ConnectedMessage.NumberOfPlayers,
+			Connected: room.connected[player.ConnectedMessage.ClientID],
+		})
+	}
+	return info
+}
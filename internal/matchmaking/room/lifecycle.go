@@ -0,0 +1,176 @@
+package room
+
+import (
+	"encoding/json"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"sort"
+)
+
+// Состояния игрового сервера комнаты. Closed отвечает только за набор игроков.
+const (
+	StateStarting = "starting"
+	StateReady    = "ready"
+	StateInMatch  = "in_match"
+	StateEnded    = "ended"
+	StateFailed   = "failed"
+)
+
+func (room *Room) State() string {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	return room.state
+}
+
+func (room *Room) setState(state string) bool {
+	room.Mutex.Lock()
+	if room.state == StateEnded || room.state == StateFailed {
+		room.Mutex.Unlock()
+		return false
+	}
+	if room.state != state {
+		room.state = state
+		room.publish(StateChanged{Room: room, State: state})
+	}
+	room.Mutex.Unlock()
+	return true
+}
+
+// ReadyC закрывается, когда сервер сообщил о готовности (через лог или control API).
+func (room *Room) ReadyC() <-chan struct{} {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if room.ready == nil {
+		room.ready = make(chan struct{})
+	}
+	return room.ready
+}
+
+func (room *Room) MarkReady() {
+	room.Mutex.Lock()
+	if room.ready == nil {
+		room.ready = make(chan struct{})
+	}
+	select {
+	case <-room.ready:
+		room.Mutex.Unlock()
+		return
+	default:
+		close(room.ready)
+	}
+	if room.state == StateStarting || room.state == "" {
+		room.state = StateReady
+		room.publish(StateChanged{Room: room, State: StateReady})
+	}
+	room.Mutex.Unlock()
+}
+
+func (room *Room) StartMatch() bool {
+	return room.setState(StateInMatch)
+}
+
+// MarkFailed - сервер не запустился. Комната дослуживает набор игроков,
+// чтобы им можно было ответить, и затем удаляется.
+func (room *Room) MarkFailed() bool {
+	return room.setState(StateFailed)
+}
+
+// End - отчёт сервера о конце матча. Матч завершается и освобождает слот комнаты;
+// повторные вызовы игнорируются.
+func (room *Room) End(results json.RawMessage) bool {
+	return room.end(results, true, nil)
+}
+
+// Exited - процесс сервера завершился. Если сервер не прислал отчёт, матч
+// завершается здесь; err - ошибка выхода процесса.
+func (room *Room) Exited(err error) bool {
+	return room.end(nil, false, err)
+}
+
+func (room *Room) end(results json.RawMessage, reported bool, exitErr error) bool {
+	room.Mutex.Lock()
+	if exitErr != nil {
+		room.exitErr = exitErr
+	}
+	if room.state == StateEnded {
+		room.Mutex.Unlock()
+		return false
+	}
+	room.state = StateEnded
+	room.reported = reported
+	if room.done == nil {
+		room.done = make(chan struct{})
+	}
+	close(room.done)
+	if results != nil {
+		room.results = results
+	}
+	// Сервер завершился раньше, чем набралась комната - ожидающим игрокам всё равно нужен ответ
+	completeNow := !room.Closed && len(room.Players) > 0
+	room.Closed = true
+	room.publish(StateChanged{Room: room, State: StateEnded})
+	if completeNow {
+		room.publish(Filled{Room: room})
+	}
+	room.publish(Ended{Room: room})
+	room.Mutex.Unlock()
+
+	log.Info("match ended", logger.RoomID(room.ID), logger.RoomUUID(room.UUID))
+	return true
+}
+
+// Done закрывается, когда матч комнаты завершён.
+func (room *Room) Done() <-chan struct{} {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if room.done == nil {
+		room.done = make(chan struct{})
+	}
+	return room.done
+}
+
+func (room *Room) Results() json.RawMessage {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	return room.results
+}
+
+// Reported - матч завершён отчётом сервера, а не выходом процесса.
+func (room *Room) Reported() bool {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	return room.reported
+}
+
+// ExitErr - ошибка, с которой завершился процесс сервера, если он уже завершился.
+func (room *Room) ExitErr() error {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	return room.exitErr
+}
+
+func (room *Room) PlayerJoined(clientID string) {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if room.connected == nil {
+		room.connected = make(map[string]bool)
+	}
+	room.connected[clientID] = true
+}
+
+func (room *Room) PlayerLeft(clientID string) {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	delete(room.connected, clientID)
+}
+
+// ConnectedPlayers - игроки, о подключении которых сообщил игровой сервер.
+func (room *Room) ConnectedPlayers() []string {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	players := make([]string, 0, len(room.connected))
+	for clientID := range room.connected {
+		players = append(players, clientID)
+	}
+	sort.Strings(players)
+	return players
+}
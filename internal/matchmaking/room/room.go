@@ -1,26 +1,58 @@
 package room
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
 	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+	"github.com/Tagakama/ServerManager/internal/tracing"
 	"sync"
 	"time"
 )
 
+var log = logger.For("room")
+
+var (
+	ErrClosed         = errors.New("room is no longer accepting players")
+	ErrPlayerNotFound = errors.New("player not found in room")
+	ErrNotAccepted    = errors.New("room does not accept the request")
+)
+
 type Room struct {
 	ID              int
+	UUID            string
 	Players         []*_type.PendingConnection
 	CurrentMap      string
 	AppVersion      string
+	Mode            string
 	SessionName     string
+	Token           string
 	ReservedPlayers int
 	MaxPlayers      int
+	Teams           int
 	Timer           *time.Timer
 	Timeout         time.Duration
 	Closed          bool
 	Mutex           sync.Mutex
-	OnComplete      func(room *Room)
+	// Events получает Filled, StateChanged и Ended; nil - события никуда не публикуются
+	Events    bus.Publisher
+	StartedAt time.Time
+	// Trace - этап запроса, создавшего комнату; от него ведётся span запуска сервера
+	Trace *tracing.Span
+
+	teamLoad  []int
+	state     string
+	ready     chan struct{}
+	done      chan struct{}
+	connected map[string]bool
+	results   json.RawMessage
+	reported  bool
+	exitErr   error
 }
 
 func New(settings _type.RoomSettings) (*Room, error) {
@@ -28,48 +60,236 @@ func New(settings _type.RoomSettings) (*Room, error) {
 		return &Room{}, errors.New("Room ID is incorrect")
 	}
 
+	token, err := newToken()
+	if err != nil {
+		return &Room{}, err
+	}
+	uuid, err := newUUID()
+	if err != nil {
+		return &Room{}, err
+	}
+
 	room := &Room{
 		ID:          settings.ID,
+		UUID:        uuid,
 		Players:     make([]*_type.PendingConnection, 0),
 		CurrentMap:  settings.CurrentMap,
 		AppVersion:  settings.AppVersion,
+		Mode:        settings.Mode,
 		MaxPlayers:  settings.MaxPlayers,
-		SessionName: fmt.Sprintf("%s_%d_%s", settings.AppVersion, settings.ID, settings.CurrentMap),
+		Teams:       settings.Teams,
+		SessionName: fmt.Sprintf("%s%s%d", settings.AppVersion, settings.CurrentMap, settings.ID),
+		Token:       token,
 		Mutex:       sync.Mutex{},
-		Timer:       time.NewTimer(30 * time.Second),
-		Timeout:     time.Duration(30 * time.Second),
+		Timer:       time.NewTimer(config.RoomFillTimeout * time.Second),
+		Timeout:     config.RoomFillTimeout * time.Second,
 		Closed:      false,
+		StartedAt:   time.Now(),
+		state:       StateStarting,
+		ready:       make(chan struct{}),
+		connected:   make(map[string]bool),
 	}
 
 	go func(r *Room) {
 		<-r.Timer.C
-		r.Mutex.Lock()
-		defer r.Mutex.Unlock()
-
-		if r.Closed {
-			return
-		}
-		r.Closed = true
-		if r.OnComplete != nil {
-			go r.OnComplete(r)
-		}
+		r.Complete()
 	}(room)
-	fmt.Printf("New Room ID: %d\n", room.ID)
+	log.Info("room created", logger.RoomID(room.ID), logger.RoomUUID(room.UUID), "mode", room.Mode, "map", room.CurrentMap, "version", room.AppVersion)
 	return room, nil
 }
 
 func (room *Room) CheckingFreeSpace(playerCount int) bool {
-	return room.MaxPlayers-room.ReservedPlayers >= playerCount
+	return room.capacity()-room.ReservedPlayers >= playerCount
+}
+
+// Accepts - игроки разных версий, режимов и карт не могут попасть на один сервер.
+// Ответ может устареть сразу после возврата: занимает место только TryAdd.
+func (room *Room) Accepts(connection *_type.PendingConnection, mode string) bool {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	return !room.Closed && room.acceptsLocked(connection, mode)
+}
+
+func (room *Room) acceptsLocked(connection *_type.PendingConnection, mode string) bool {
+	return room.AppVersion == connection.ConnectedMessage.AppVersion &&
+		room.Mode == mode &&
+		room.CurrentMap == connection.ConnectedMessage.MapName &&
+		room.CheckingFreeSpace(connection.ConnectedMessage.NumberOfPlayers) &&
+		room.fitsTeam(connection.ConnectedMessage.NumberOfPlayers)
+}
+
+// TryAdd проверяет комнату и занимает место одной операцией: таймер не может закрыть
+// набор между проверкой и добавлением. joined публикуется до Filled, если комната
+// заполнилась этим игроком. ErrClosed - набор уже закрыт, ErrNotAccepted - комната
+// не подходит или в ней нет места.
+func (room *Room) TryAdd(player *_type.PendingConnection, mode string, joined bus.Event) error {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if room.Closed {
+		return ErrClosed
+	}
+	if !room.acceptsLocked(player, mode) {
+		return ErrNotAccepted
+	}
+	if joined != nil {
+		room.publish(joined)
+	}
+	room.addLocked(player)
+	return nil
 }
 
-func (room *Room) AddPlayer(player *_type.PendingConnection) {
+// AddPlayer добавляет игрока без проверки карты, версии и места; закрытая комната его не принимает.
+func (room *Room) AddPlayer(player *_type.PendingConnection) error {
 	room.Mutex.Lock()
 	defer room.Mutex.Unlock()
+	if room.Closed {
+		return ErrClosed
+	}
+	room.addLocked(player)
+	return nil
+}
 
+func (room *Room) addLocked(player *_type.PendingConnection) {
 	room.Players = append(room.Players, player)
 	room.ReservedPlayers += player.ConnectedMessage.NumberOfPlayers
-	fmt.Printf("Player %s, connected to room %d\n", player.ConnectedMessage.ClientID, room.ID)
-	if room.ReservedPlayers == room.MaxPlayers {
+	player.Team = room.assignTeam(player.ConnectedMessage.NumberOfPlayers)
+	log.Info("player joined room", logger.RoomID(room.ID), logger.ClientID(player.ConnectedMessage.ClientID), "team", player.Team, "reserved", room.ReservedPlayers)
+	if room.ReservedPlayers >= room.capacity() {
 		room.Closed = true
+		room.publish(Filled{Room: room})
+	}
+}
+
+// PlayersSnapshot - копия списка игроков, её можно обходить без блокировки комнаты.
+func (room *Room) PlayersSnapshot() []*_type.PendingConnection {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	return append([]*_type.PendingConnection(nil), room.Players...)
+}
+
+// IsClosed - набор игроков закрыт.
+func (room *Room) IsClosed() bool {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	return room.Closed
+}
+
+// Complete закрывает набор и запускает матч с теми, кто уже есть. Так срабатывает таймер,
+// так же оператор запускает комнату досрочно. false - набор уже закрыт.
+func (room *Room) Complete() bool {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if room.Closed {
+		return false
+	}
+	room.Closed = true
+	room.publish(Filled{Room: room})
+	return true
+}
+
+// Cancel закрывает набор без запуска матча и отдаёт ожидающих игроков, чтобы им ответить.
+func (room *Room) Cancel() ([]*_type.PendingConnection, error) {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if room.Closed {
+		return nil, ErrClosed
+	}
+	room.Closed = true
+	return room.Players, nil
+}
+
+// RemovePlayer убирает игрока из комнаты, пока идёт набор, и освобождает его место в команде.
+func (room *Room) RemovePlayer(clientID string) (*_type.PendingConnection, error) {
+	return room.remove(func(player *_type.PendingConnection) bool {
+		return player.ConnectedMessage.ClientID == clientID
+	})
+}
+
+// RemoveConnection - RemovePlayer для конкретного запроса, а не любого с тем же ClientID.
+func (room *Room) RemoveConnection(connection *_type.PendingConnection) error {
+	_, err := room.remove(func(player *_type.PendingConnection) bool {
+		return player == connection
+	})
+	return err
+}
+
+func (room *Room) remove(match func(player *_type.PendingConnection) bool) (*_type.PendingConnection, error) {
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if room.Closed {
+		return nil, ErrClosed
+	}
+	for i, player := range room.Players {
+		if !match(player) {
+			continue
+		}
+		room.Players = append(room.Players[:i:i], room.Players[i+1:]...)
+		room.ReservedPlayers -= player.ConnectedMessage.NumberOfPlayers
+		if player.Team > 0 && player.Team <= len(room.teamLoad) {
+			room.teamLoad[player.Team-1] -= player.ConnectedMessage.NumberOfPlayers
+		}
+		return player, nil
+	}
+	return nil, ErrPlayerNotFound
+}
+
+// Команды нумеруются с 1, группа игроков всегда попадает в одну команду.
+func (room *Room) teamCapacity() (teams, size int) {
+	teams = room.Teams
+	if teams < 1 {
+		teams = 1
+	}
+	if len(room.teamLoad) != teams {
+		room.teamLoad = append(room.teamLoad, make([]int, teams)...)[:teams]
+	}
+	return teams, room.MaxPlayers / teams
+}
+
+// capacity - сколько игроков реально помещается в команды. Если MaxPlayers не делится
+// на число команд, остаток не занять, и комната заполнена без него.
+func (room *Room) capacity() int {
+	teams, size := room.teamCapacity()
+	return teams * size
+}
+
+func (room *Room) freestTeam() (team, free int) {
+	teams, size := room.teamCapacity()
+	team, free = 1, size-room.teamLoad[0]
+	for i := 1; i < teams; i++ {
+		if size-room.teamLoad[i] > free {
+			team, free = i+1, size-room.teamLoad[i]
+		}
+	}
+	return team, free
+}
+
+func (room *Room) fitsTeam(playerCount int) bool {
+	_, free := room.freestTeam()
+	return free >= playerCount
+}
+
+func (room *Room) assignTeam(playerCount int) int {
+	team, _ := room.freestTeam()
+	room.teamLoad[team-1] += playerCount
+	return team
+}
+
+// newToken - секрет комнаты, передаётся игровому серверу при запуске.
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// newUUID - UUID v4. ID комнаты повторяется после рестарта, UUID уникален.
+func newUUID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
+	buf[6] = buf[6]&0x0f | 0x40
+	buf[8] = buf[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16]), nil
 }
@@ -1,6 +1,7 @@
 package room_test
 
 import (
+	"github.com/Tagakama/ServerManager/internal/bus"
 	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
 	ro "github.com/Tagakama/ServerManager/internal/matchmaking/room"
 	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
@@ -10,10 +11,13 @@ import (
 	"time"
 )
 
+type stubLauncher struct{}
+
+func (stubLauncher) LaunchGameServer(*ro.Room) error { return nil }
+
 func TestRoom_ClosesAfterTimeout(t *testing.T) {
 	closed := make(chan bool, 1)
 
-	// Установим таймер на 100 мс для быстрого теста
 	room, err := ro.New(_type.RoomSettings{
 		ID:         1,
 		MaxPlayers: 8,
@@ -22,26 +26,16 @@ func TestRoom_ClosesAfterTimeout(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	room.Timeout = 100 * time.Millisecond
-	room.Timer = time.NewTimer(room.Timeout)
-
 	// Отслеживаем закрытие
-	room.OnComplete = func(r *ro.Room) {
+	events := bus.New()
+	bus.Subscribe(events, "test", func(ro.Filled) {
 		closed <- true
-	}
+	})
+	room.Events = events
 
-	// Запускаем таймер вручную (имитируем поведение New)
-	go func(r *ro.Room) {
-		<-r.Timer.C
-		r.Mutex.Lock()
-		defer r.Mutex.Unlock()
-		if !r.Closed {
-			r.Closed = true
-			if r.OnComplete != nil {
-				r.OnComplete(r)
-			}
-		}
-	}(room)
+	// Таймер на 100 мс для быстрого теста
+	room.Timeout = 100 * time.Millisecond
+	room.Timer.Reset(room.Timeout)
 
 	// Проверим, что закроется в течение 500 мс
 	select {
@@ -51,11 +45,13 @@ func TestRoom_ClosesAfterTimeout(t *testing.T) {
 		t.Fatal("Room did not close after timeout")
 	}
 
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
 	assert.True(t, room.Closed, "Room should be closed by timer")
 }
 
 func TestRoom_RemovedAfterTimeout(t *testing.T) {
-	mm := matchmaker.New()
+	mm := matchmaker.New(stubLauncher{})
 	done := make(chan struct{}, 1)
 
 	conn := &_type.PendingConnection{
@@ -71,24 +67,12 @@ func TestRoom_RemovedAfterTimeout(t *testing.T) {
 	require.Len(t, mm.CurrentRooms, 1)
 	room := mm.CurrentRooms[0]
 
-	room.Timeout = 100 * time.Millisecond
-	room.Timer = time.NewTimer(room.Timeout)
-	room.OnComplete = func(r *ro.Room) {
-		mm.RemoveRoom(r)
+	bus.Subscribe(mm.Bus, "test", func(e ro.Filled) {
+		mm.RemoveRoom(e.Room)
 		done <- struct{}{}
-	}
-
-	go func(r *ro.Room) {
-		<-r.Timer.C
-		r.Mutex.Lock()
-		defer r.Mutex.Unlock()
-		if !r.Closed {
-			r.Closed = true
-			if r.OnComplete != nil {
-				r.OnComplete(r)
-			}
-		}
-	}(room)
+	})
+	room.Timeout = 100 * time.Millisecond
+	room.Timer.Reset(room.Timeout)
 
 	select {
 	case <-done:
@@ -96,5 +80,47 @@ func TestRoom_RemovedAfterTimeout(t *testing.T) {
 		t.Fatal("Room was not closed and removed after timeout")
 	}
 
-	assert.Len(t, mm.CurrentRooms, 0, "Room should be removed from matchmaker")
+	assert.Empty(t, mm.Rooms(), "Room should be removed from matchmaker")
+}
+
+func TestRoom_AssignsPartiesToTeams(t *testing.T) {
+	room, err := ro.New(_type.RoomSettings{ID: 1, MaxPlayers: 8, Teams: 4, CurrentMap: "Arena", AppVersion: "v1"})
+	require.NoError(t, err)
+
+	party := func(id string, size int) *_type.PendingConnection {
+		return &_type.PendingConnection{ConnectedMessage: _type.Message{ClientID: id, NumberOfPlayers: size, MapName: "Arena", AppVersion: "v1"}}
+	}
+
+	duo := party("duo", 2)
+	room.AddPlayer(duo)
+	assert.Equal(t, 1, duo.Team)
+
+	solo := party("solo", 1)
+	room.AddPlayer(solo)
+	assert.Equal(t, 2, solo.Team, "solo player goes to the emptiest team")
+
+	trio := party("trio", 3)
+	assert.False(t, room.Accepts(trio, ""), "party larger than a team must not be accepted")
+	assert.True(t, room.Accepts(party("another", 2), ""))
+}
+
+func TestRoom_TryAddAfterClose(t *testing.T) {
+	room, err := ro.New(_type.RoomSettings{ID: 1, MaxPlayers: 4, CurrentMap: "Arena", AppVersion: "v1"})
+	require.NoError(t, err)
+	player := &_type.PendingConnection{ConnectedMessage: _type.Message{ClientID: "late", NumberOfPlayers: 1, MapName: "Arena", AppVersion: "v1"}}
+
+	require.True(t, room.Complete())
+	assert.ErrorIs(t, room.TryAdd(player, "", nil), ro.ErrClosed, "timer closed the room before the player was added")
+	assert.Empty(t, room.Info(time.Now()).Players)
+}
+
+func TestRoom_FillsWhenTeamsAreFull(t *testing.T) {
+	room, err := ro.New(_type.RoomSettings{ID: 1, MaxPlayers: 5, Teams: 2, CurrentMap: "Arena", AppVersion: "v1"})
+	require.NoError(t, err)
+
+	for _, id := range []string{"a", "b"} {
+		duo := &_type.PendingConnection{ConnectedMessage: _type.Message{ClientID: id, NumberOfPlayers: 2, MapName: "Arena", AppVersion: "v1"}}
+		require.NoError(t, room.TryAdd(duo, "", nil))
+	}
+	assert.True(t, room.IsClosed(), "two teams of two are full even though max_players is 5")
 }
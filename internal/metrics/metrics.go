@@ -0,0 +1,343 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default - реестр, в котором пакеты регистрируют свои метрики. Отдаётся на /metrics.
+var Default = NewRegistry()
+
+var (
+	// DurationBuckets - границы для длительностей в секундах.
+	DurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+	// CountBuckets - границы для количества игроков.
+	CountBuckets = []float64{1, 2, 4, 6, 8, 10, 12, 16, 24, 32, 64}
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry хранит семейства метрик и пишет их в текстовом формате Prometheus.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]collector
+}
+
+type collector interface {
+	writeTo(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]collector)}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metrics: %s is already registered", name))
+	}
+	r.families[name] = c
+}
+
+// WriteTo пишет все метрики, семейства отсортированы по имени.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	families := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, r.families[name])
+	}
+	r.mu.Unlock()
+
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+	for _, family := range families {
+		family.writeTo(buf)
+	}
+	err := buf.Flush()
+	return counter.n, err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.WriteTo(w)
+}
+
+// Counter регистрирует счётчик. Значения меток передаются в Inc/Add в порядке labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{}
+	c.init(name, help, "counter", labels)
+	r.register(name, c)
+	return c
+}
+
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{}
+	g.init(name, help, "gauge", labels)
+	r.register(name, g)
+	return g
+}
+
+// GaugeFunc - значение считается в момент чтения, например длина очереди.
+func (r *Registry) GaugeFunc(name, help string, value func() float64) {
+	g := &gaugeFunc{value: value}
+	g.init(name, help, "gauge", nil)
+	r.register(name, g)
+}
+
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &Histogram{buckets: sorted}
+	h.init(name, help, "histogram", labels)
+	r.register(name, h)
+	return h
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (f *family) init(name, help, kind string, labels []string) {
+	f.name, f.help, f.kind, f.labels = name, help, kind, labels
+	f.series = make(map[string]*series)
+}
+
+// get вызывается под f.mu. Неверное число меток - ошибка в коде, как и в client_golang.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) sorted() []*series {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, f.series[key])
+	}
+	return result
+}
+
+func (f *family) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+}
+
+func (f *family) writeValues(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writeHeader(w)
+	for _, s := range f.sorted() {
+		writeSample(w, f.name, f.labels, s.values, "", "", s.value)
+	}
+}
+
+type Counter struct {
+	family
+}
+
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *Counter) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.name))
+	}
+	c.mu.Lock()
+	c.get(labels).value += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) Value(labels ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(labels).value
+}
+
+func (c *Counter) writeTo(w *bufio.Writer) {
+	c.writeValues(w)
+}
+
+type Gauge struct {
+	family
+}
+
+func (g *Gauge) Set(value float64, labels ...string) {
+	g.mu.Lock()
+	g.get(labels).value = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(delta float64, labels ...string) {
+	g.mu.Lock()
+	g.get(labels).value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Inc(labels ...string) { g.Add(1, labels...) }
+func (g *Gauge) Dec(labels ...string) { g.Add(-1, labels...) }
+
+func (g *Gauge) Value(labels ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.get(labels).value
+}
+
+func (g *Gauge) writeTo(w *bufio.Writer) {
+	g.writeValues(w)
+}
+
+type gaugeFunc struct {
+	family
+	value func() float64
+}
+
+func (g *gaugeFunc) writeTo(w *bufio.Writer) {
+	g.writeHeader(w)
+	writeSample(w, g.name, nil, nil, "", "", g.value())
+}
+
+type Histogram struct {
+	family
+	buckets []float64
+}
+
+func (h *Histogram) Observe(value float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labels)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Count - число наблюдений, удобно в тестах.
+func (h *Histogram) Count(labels ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.get(labels).count
+}
+
+func (h *Histogram) Sum(labels ...string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.get(labels).sum
+}
+
+func (h *Histogram) writeTo(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, s := range h.sorted() {
+		for i, bound := range h.buckets {
+			var count uint64
+			if s.counts != nil {
+				count = s.counts[i]
+			}
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatFloat(bound), float64(count))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(s.count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
+func escapeHelp(value string) string  { return helpEscaper.Replace(value) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
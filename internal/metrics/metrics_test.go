@@ -0,0 +1,90 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/metrics"
+)
+
+func TestRegistry_TextFormat(t *testing.T) {
+	registry := metrics.NewRegistry()
+	dropped := registry.Counter("sm_dropped_total", "Dropped requests.", "reason")
+	open := registry.Gauge("sm_rooms_open", "Open rooms.", "mode", "map")
+	launch := registry.Histogram("sm_launch_seconds", "Launch time.", []float64{1, 5}, "outcome")
+	registry.GaugeFunc("sm_queue_depth", "Queue depth.", func() float64 { return 3 })
+
+	dropped.Inc("full")
+	dropped.Add(2, "full")
+	dropped.Inc("closed")
+	open.Inc("duel", `Fo"rest`)
+	open.Inc("duel", `Fo"rest`)
+	open.Dec("duel", `Fo"rest`)
+	launch.Observe(0.5, "ready")
+	launch.Observe(3, "ready")
+	launch.Observe(40, "timeout")
+
+	var out strings.Builder
+	_, err := registry.WriteTo(&out)
+	require.NoError(t, err)
+
+	expected := `# HELP sm_dropped_total Dropped requests.
+# TYPE sm_dropped_total counter
+sm_dropped_total{reason="closed"} 1
+sm_dropped_total{reason="full"} 3
+# HELP sm_launch_seconds Launch time.
+# TYPE sm_launch_seconds histogram
+sm_launch_seconds_bucket{outcome="ready",le="1"} 1
+sm_launch_seconds_bucket{outcome="ready",le="5"} 2
+sm_launch_seconds_bucket{outcome="ready",le="+Inf"} 2
+sm_launch_seconds_sum{outcome="ready"} 3.5
+sm_launch_seconds_count{outcome="ready"} 2
+sm_launch_seconds_bucket{outcome="timeout",le="1"} 0
+sm_launch_seconds_bucket{outcome="timeout",le="5"} 0
+sm_launch_seconds_bucket{outcome="timeout",le="+Inf"} 1
+sm_launch_seconds_sum{outcome="timeout"} 40
+sm_launch_seconds_count{outcome="timeout"} 1
+# HELP sm_queue_depth Queue depth.
+# TYPE sm_queue_depth gauge
+sm_queue_depth 3
+# HELP sm_rooms_open Open rooms.
+# TYPE sm_rooms_open gauge
+sm_rooms_open{mode="duel",map="Fo\"rest"} 1
+`
+	assert.Equal(t, expected, out.String())
+	assert.Equal(t, float64(3), dropped.Value("full"))
+	assert.Equal(t, uint64(2), launch.Count("ready"))
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.Counter("sm_total", "Total.", "reason")
+
+	assert.Panics(t, func() { registry.Gauge("sm_total", "Duplicate.") })
+	assert.Panics(t, func() { counter.Inc() })
+	assert.Panics(t, func() { counter.Add(-1, "full") })
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Counter("sm_requests_total", "Requests.").Inc()
+
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, response.Header.Get("Content-Type"), "text/plain; version=0.0.4")
+	assert.Contains(t, string(body), "sm_requests_total 1\n")
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"net/http"
+	"time"
+)
+
+// Server отдаёт Default на GET /metrics. Авторизации нет, как у обычных экспортёров,
+// поэтому по умолчанию слушаем loopback.
+type Server struct {
+	httpServer *http.Server
+}
+
+func NewServer(cfg *config.Config) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Default)
+	return &Server{httpServer: &http.Server{
+		Addr:              cfg.Metrics.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}}
+}
+
+func (s *Server) ListenAndServe() error {
+	logger.For("metrics").Info("metrics endpoint is listening", "address", s.httpServer.Addr)
+	return s.httpServer.ListenAndServe()
+}
+
+func (s *Server) Close() error {
+	return s.httpServer.Close()
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Tagakama/ServerManager/internal/journal"
+	"time"
+)
+
+// File - журнал в файле, по одному JSON-событию на строку. При открытии журнал
+// проигрывается в память; Compact переписывает файл через временный и rename.
+type File struct {
+	*Memory
+	path    string
+	sync    bool
+	journal *journal.Journal
+}
+
+// OpenFile читает журнал и открывает его на дозапись. sync - fsync после каждого
+// события: надёжнее при потере питания, но медленнее.
+func OpenFile(path string, sync bool) (*File, error) {
+	if path == "" {
+		return nil, errors.New("store path is required for file driver")
+	}
+	s := &File{Memory: NewMemory(), path: path, sync: sync}
+	file, err := journal.Open(path, func(line []byte) error {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		s.applyLocked(event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.journal = file
+	log.Info("store opened", "path", path, "events", len(s.events), "rooms", len(s.rooms), "tickets", len(s.tickets))
+	return s, nil
+}
+
+func (s *File) Append(event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event = s.stampLocked(event)
+	if err := s.journal.Append(event); err != nil {
+		return err
+	}
+	if s.sync {
+		if err := s.journal.Sync(); err != nil {
+			return err
+		}
+	}
+	s.applyLocked(event)
+	return nil
+}
+
+func (s *File) Compact(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	was := len(s.events)
+	s.compactLocked(before)
+	if err := journal.Rewrite(s.journal, s.events); err != nil {
+		return err
+	}
+	log.Info("store compacted", "path", s.path, "events", len(s.events), "dropped", was+1-len(s.events))
+	return nil
+}
+
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.journal.Close()
+}
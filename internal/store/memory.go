@@ -0,0 +1,294 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory держит журнал и состояние в памяти. Это и есть состояние File:
+// файл только переживает рестарт.
+type Memory struct {
+	mu      sync.RWMutex
+	seq     uint64
+	lastID  int
+	events  []Event
+	tickets map[string]*TicketRecord
+	rooms   map[string]*RoomRecord
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tickets: make(map[string]*TicketRecord),
+		rooms:   make(map[string]*RoomRecord),
+	}
+}
+
+func (m *Memory) Append(event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.applyLocked(m.stampLocked(event))
+	return nil
+}
+
+func (m *Memory) stampLocked(event Event) Event {
+	if event.Seq == 0 {
+		event.Seq = m.seq + 1
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	return event
+}
+
+func (m *Memory) applyLocked(event Event) {
+	if event.Seq > m.seq {
+		m.seq = event.Seq
+	}
+	m.events = append(m.events, event)
+
+	switch event.Kind {
+	case TicketQueued:
+		m.tickets[event.TicketID] = &TicketRecord{
+			ID:        event.TicketID,
+			ClientID:  event.ClientID,
+			Mode:      event.Mode,
+			Map:       event.Map,
+			Version:   event.Version,
+			Players:   event.Players,
+			State:     TicketStateQueued,
+			CreatedAt: event.Time,
+			UpdatedAt: event.Time,
+		}
+	case TicketAssigned, TicketMatched, TicketRejected, TicketLost:
+		ticket := m.ticketLocked(event)
+		ticket.State = ticketStates[event.Kind]
+		ticket.UpdatedAt = event.Time
+		if event.RoomUUID != "" {
+			ticket.RoomUUID = event.RoomUUID
+		}
+		if event.Version != "" {
+			ticket.Version = event.Version
+		}
+		ticket.Code = event.Code
+
+		// Отказ после попадания в комнату (kick, отмена, падение сервера) убирает игрока из неё
+		room, ok := m.rooms[ticket.RoomUUID]
+		if !ok {
+			return
+		}
+		switch event.Kind {
+		case TicketAssigned:
+			room.Players = append(room.Players, ticket.ClientID)
+		case TicketRejected:
+			room.Players = remove(room.Players, ticket.ClientID)
+		}
+		room.UpdatedAt = event.Time
+	case StoreCheckpoint:
+		m.lastID = max(m.lastID, event.RoomID)
+	case RoomCreated:
+		m.lastID = max(m.lastID, event.RoomID)
+		m.rooms[event.RoomUUID] = &RoomRecord{
+			ID:        event.RoomID,
+			UUID:      event.RoomUUID,
+			Mode:      event.Mode,
+			Map:       event.Map,
+			Version:   event.Version,
+			State:     event.State,
+			Players:   []string{},
+			CreatedAt: event.Time,
+			UpdatedAt: event.Time,
+		}
+	default:
+		room, ok := m.rooms[event.RoomUUID]
+		if !ok {
+			return
+		}
+		room.UpdatedAt = event.Time
+		switch event.Kind {
+		case RoomState:
+			room.State = event.State
+		case RoomFilled:
+			room.Filled = true
+		case RoomCancelled, RoomRemoved:
+			room.Removed = true
+		case RoomAbandoned:
+			room.Removed = true
+			room.ServerUp = false
+			room.State = event.State
+		case ServerAssigned:
+			room.PID = event.PID
+			room.Port = event.Port
+			room.Executable = event.Executable
+			room.ServerUp = true
+			if event.Version != "" {
+				room.Version = event.Version
+			}
+		case ServerExited:
+			room.ServerUp = false
+		}
+	}
+}
+
+var ticketStates = map[string]string{
+	TicketAssigned: TicketStateAssigned,
+	TicketMatched:  TicketStateMatched,
+	TicketRejected: TicketStateRejected,
+	TicketLost:     TicketStateLost,
+}
+
+// ticketLocked - отказ может прийти по запросу, который так и не попал в очередь.
+func (m *Memory) ticketLocked(event Event) *TicketRecord {
+	ticket, ok := m.tickets[event.TicketID]
+	if !ok {
+		ticket = &TicketRecord{
+			ID:        event.TicketID,
+			ClientID:  event.ClientID,
+			Mode:      event.Mode,
+			Map:       event.Map,
+			Version:   event.Version,
+			Players:   event.Players,
+			CreatedAt: event.Time,
+		}
+		m.tickets[event.TicketID] = ticket
+	}
+	return ticket
+}
+
+func remove(players []string, clientID string) []string {
+	for i, player := range players {
+		if player == clientID {
+			return append(players[:i:i], players[i+1:]...)
+		}
+	}
+	return players
+}
+
+func (m *Memory) Ticket(id string) (TicketRecord, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ticket, ok := m.tickets[id]
+	if !ok {
+		return TicketRecord{}, false
+	}
+	return *ticket, true
+}
+
+func (m *Memory) Room(uuid string) (RoomRecord, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	room, ok := m.rooms[uuid]
+	if !ok {
+		return RoomRecord{}, false
+	}
+	return copyRoom(room), true
+}
+
+func copyRoom(room *RoomRecord) RoomRecord {
+	result := *room
+	result.Players = append([]string{}, room.Players...)
+	return result
+}
+
+func (m *Memory) Rooms() []RoomRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rooms := make([]RoomRecord, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, copyRoom(room))
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].ID < rooms[j].ID
+	})
+	return rooms
+}
+
+func (m *Memory) Tickets() []TicketRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tickets := make([]TicketRecord, 0, len(m.tickets))
+	for _, ticket := range m.tickets {
+		tickets = append(tickets, *ticket)
+	}
+	sort.Slice(tickets, func(i, j int) bool {
+		return tickets[i].CreatedAt.Before(tickets[j].CreatedAt)
+	})
+	return tickets
+}
+
+// Events возвращает события по порядку записи; Limit оставляет последние.
+func (m *Memory) Events(filter Filter) []Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []Event
+	for _, event := range m.events {
+		if filter.matches(event) {
+			events = append(events, event)
+		}
+	}
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[len(events)-filter.Limit:]
+	}
+	return events
+}
+
+func (f Filter) matches(event Event) bool {
+	switch {
+	case f.TicketID != "" && event.TicketID != f.TicketID:
+		return false
+	case f.ClientID != "" && event.ClientID != f.ClientID:
+		return false
+	case f.RoomUUID != "" && event.RoomUUID != f.RoomUUID:
+		return false
+	case !f.Since.IsZero() && event.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !event.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+func (m *Memory) Compact(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.compactLocked(before)
+	return nil
+}
+
+// compactLocked оставляет всю историю незавершённых тикетов и комнат, как бы стара она ни была.
+func (m *Memory) compactLocked(before time.Time) {
+	for id, ticket := range m.tickets {
+		if ticket.Finished() && ticket.UpdatedAt.Before(before) {
+			delete(m.tickets, id)
+		}
+	}
+	for uuid, room := range m.rooms {
+		if room.Removed && !room.ServerUp && room.UpdatedAt.Before(before) {
+			delete(m.rooms, uuid)
+		}
+	}
+	// Контрольная точка сохраняет счётчики, события которых могли быть забыты
+	events := append(make([]Event, 0, len(m.events)+1), Event{Seq: m.seq, Time: time.Now().UTC(), Kind: StoreCheckpoint, RoomID: m.lastID})
+	for _, event := range m.events {
+		if event.Kind == StoreCheckpoint {
+			continue
+		}
+		_, ticketAlive := m.tickets[event.TicketID]
+		_, roomAlive := m.rooms[event.RoomUUID]
+		if !event.Time.Before(before) || (event.TicketID != "" && ticketAlive) || (event.RoomUUID != "" && roomAlive) {
+			events = append(events, event)
+		}
+	}
+	m.events = events
+}
+
+// LastRoomID - наибольший ID комнаты за всю историю журнала, включая забытые комнаты.
+func (m *Memory) LastRoomID() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lastID
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
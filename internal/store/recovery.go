@@ -0,0 +1,61 @@
+package store
+
+// CodeManagerRestarted - причина, с которой закрываются тикеты прошлого запуска.
+const CodeManagerRestarted = "manager_restarted"
+
+const StateAbandoned = "abandoned"
+
+// Recovery - что осталось незавершённым после прошлого запуска менеджера.
+type Recovery struct {
+	NextRoomID     int
+	LostTickets    []TicketRecord
+	AbandonedRooms []RoomRecord
+	// Orphans - комнаты, чей процесс игрового сервера мог пережить менеджер
+	Orphans []RoomRecord
+}
+
+// Recover закрывает в журнале тикеты и комнаты прошлого запуска: их соединения
+// и процессы менеджеру больше не принадлежат. Вызывается до приёма запросов.
+func Recover(s Store) Recovery {
+	recovery := Recovery{NextRoomID: s.LastRoomID() + 1}
+
+	for _, ticket := range s.Tickets() {
+		if ticket.Finished() {
+			continue
+		}
+		Record(s, Event{
+			Kind:     TicketLost,
+			TicketID: ticket.ID,
+			ClientID: ticket.ClientID,
+			RoomUUID: ticket.RoomUUID,
+			Code:     CodeManagerRestarted,
+		})
+		recovery.LostTickets = append(recovery.LostTickets, ticket)
+	}
+
+	for _, room := range s.Rooms() {
+		if room.Removed && !room.ServerUp {
+			continue
+		}
+		if room.ServerUp && room.PID > 0 {
+			recovery.Orphans = append(recovery.Orphans, room)
+		}
+		Record(s, Event{
+			Kind:     RoomAbandoned,
+			RoomID:   room.ID,
+			RoomUUID: room.UUID,
+			State:    StateAbandoned,
+			Code:     CodeManagerRestarted,
+		})
+		recovery.AbandonedRooms = append(recovery.AbandonedRooms, room)
+	}
+
+	if len(recovery.LostTickets) > 0 || len(recovery.AbandonedRooms) > 0 {
+		log.Warn("recovered state of previous run",
+			"lost_tickets", len(recovery.LostTickets),
+			"abandoned_rooms", len(recovery.AbandonedRooms),
+			"orphan_servers", len(recovery.Orphans),
+			"next_room_id", recovery.NextRoomID)
+	}
+	return recovery
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"time"
+)
+
+const (
+	DriverMemory = "memory"
+	DriverFile   = "file"
+)
+
+// Типы событий журнала. По ним строится текущее состояние тикетов и комнат.
+const (
+	TicketQueued   = "ticket.queued"
+	TicketAssigned = "ticket.assigned"
+	TicketMatched  = "ticket.matched"
+	TicketRejected = "ticket.rejected"
+	TicketLost     = "ticket.lost"
+
+	RoomCreated   = "room.created"
+	RoomState     = "room.state"
+	RoomFilled    = "room.filled"
+	RoomCancelled = "room.cancelled"
+	RoomRemoved   = "room.removed"
+	RoomAbandoned = "room.abandoned"
+
+	ServerAssigned = "server.assigned"
+	ServerExited   = "server.exited"
+
+	// StoreCheckpoint пишется при сжатии журнала и хранит последний ID комнаты
+	StoreCheckpoint = "store.checkpoint"
+)
+
+// Состояния тикета в TicketRecord.
+const (
+	TicketStateQueued   = "queued"
+	TicketStateAssigned = "assigned"
+	TicketStateMatched  = "matched"
+	TicketStateRejected = "rejected"
+	TicketStateLost     = "lost"
+)
+
+var log = logger.For("store")
+
+// Event - запись журнала. Заполняются только поля, относящиеся к Kind.
+type Event struct {
+	Seq        uint64    `json:"seq"`
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	TicketID   string    `json:"ticket_id,omitempty"`
+	ClientID   string    `json:"client_id,omitempty"`
+	RoomID     int       `json:"room_id,omitempty"`
+	RoomUUID   string    `json:"room_uuid,omitempty"`
+	Mode       string    `json:"mode,omitempty"`
+	Map        string    `json:"map,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	Players    int       `json:"players,omitempty"`
+	Team       int       `json:"team,omitempty"`
+	State      string    `json:"state,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	Port       int       `json:"port,omitempty"`
+	Executable string    `json:"executable,omitempty"`
+	Code       string    `json:"code,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// TicketRecord - запрос игрока или группы от приёма до ответа.
+type TicketRecord struct {
+	ID        string    `json:"id"`
+	ClientID  string    `json:"client_id"`
+	Mode      string    `json:"mode"`
+	Map       string    `json:"map"`
+	Version   string    `json:"version"`
+	Players   int       `json:"players"`
+	State     string    `json:"state"`
+	RoomUUID  string    `json:"room_uuid,omitempty"`
+	Code      string    `json:"code,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished - на тикет уже ответили или ответить уже некому.
+func (t TicketRecord) Finished() bool {
+	return t.State == TicketStateMatched || t.State == TicketStateRejected || t.State == TicketStateLost
+}
+
+// RoomRecord - комната и назначенный ей процесс игрового сервера.
+type RoomRecord struct {
+	ID         int       `json:"id"`
+	UUID       string    `json:"uuid"`
+	Mode       string    `json:"mode"`
+	Map        string    `json:"map"`
+	Version    string    `json:"version"`
+	State      string    `json:"state"`
+	Filled     bool      `json:"filled"`
+	Players    []string  `json:"players"`
+	PID        int       `json:"pid,omitempty"`
+	Port       int       `json:"port,omitempty"`
+	Executable string    `json:"executable,omitempty"`
+	ServerUp   bool      `json:"server_up"`
+	Removed    bool      `json:"removed"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Filter отбирает события для аудита. Пустые поля не ограничивают выборку.
+type Filter struct {
+	TicketID string
+	ClientID string
+	RoomUUID string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// Store - журнал тикетов, комнат и серверов. Append присваивает Seq и Time,
+// если они не заданы.
+type Store interface {
+	Append(event Event) error
+	Ticket(id string) (TicketRecord, bool)
+	Room(uuid string) (RoomRecord, bool)
+	Rooms() []RoomRecord
+	Tickets() []TicketRecord
+	Events(filter Filter) []Event
+	LastRoomID() int
+	// Compact забывает завершённые тикеты и комнаты, не менявшиеся с before
+	Compact(before time.Time) error
+	Close() error
+}
+
+func New(cfg *config.Config) (Store, error) {
+	switch cfg.Store.Driver {
+	case "", DriverMemory:
+		return NewMemory(), nil
+	case DriverFile:
+		return OpenFile(cfg.Store.Path, cfg.Store.Sync)
+	default:
+		return nil, fmt.Errorf("unknown store driver %q", cfg.Store.Driver)
+	}
+}
+
+// Record пишет событие, если журнал подключён. Ошибка записи не должна
+// останавливать матчмейкинг, поэтому она только логируется.
+func Record(s Store, event Event) {
+	if s == nil {
+		return
+	}
+	if err := s.Append(event); err != nil {
+		log.Error("failed to record event", "kind", event.Kind, logger.Err(err))
+	}
+}
+
+// RunCompaction раз в interval забывает записи старше retention. Закрытие stop завершает цикл.
+func RunCompaction(s Store, retention, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Compact(time.Now().Add(-retention)); err != nil {
+			log.Error("failed to compact store", logger.Err(err))
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
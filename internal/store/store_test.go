@@ -0,0 +1,151 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/store"
+)
+
+const roomUUID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+// matchedRoom пишет историю комнаты с двумя игроками, один из которых выбыл.
+func matchedRoom(t *testing.T, s store.Store) {
+	events := []store.Event{
+		{Kind: store.TicketQueued, TicketID: "t1", ClientID: "alice", Mode: "default", Map: "Arena", Players: 1},
+		{Kind: store.TicketQueued, TicketID: "t2", ClientID: "bob", Mode: "default", Map: "Arena", Players: 1},
+		{Kind: store.RoomCreated, RoomID: 7, RoomUUID: roomUUID, Mode: "default", Map: "Arena", Version: "v1", State: "starting"},
+		{Kind: store.ServerAssigned, RoomID: 7, RoomUUID: roomUUID, PID: 4242, Port: 7777, Executable: "/builds/v1/Server.x86_64"},
+		{Kind: store.TicketAssigned, TicketID: "t1", ClientID: "alice", RoomUUID: roomUUID},
+		{Kind: store.TicketAssigned, TicketID: "t2", ClientID: "bob", RoomUUID: roomUUID},
+		{Kind: store.TicketRejected, TicketID: "t2", ClientID: "bob", Code: "kicked"},
+		{Kind: store.RoomState, RoomUUID: roomUUID, State: "ready"},
+		{Kind: store.RoomFilled, RoomUUID: roomUUID},
+		{Kind: store.TicketMatched, TicketID: "t1", ClientID: "alice", RoomUUID: roomUUID, Team: 1},
+	}
+	for _, event := range events {
+		require.NoError(t, s.Append(event))
+	}
+}
+
+func TestMemory_Projections(t *testing.T) {
+	s := store.NewMemory()
+	matchedRoom(t, s)
+
+	alice, ok := s.Ticket("t1")
+	require.True(t, ok)
+	assert.Equal(t, store.TicketStateMatched, alice.State)
+	assert.Equal(t, roomUUID, alice.RoomUUID)
+
+	bob, _ := s.Ticket("t2")
+	assert.Equal(t, store.TicketStateRejected, bob.State)
+	assert.Equal(t, "kicked", bob.Code)
+
+	room, ok := s.Room(roomUUID)
+	require.True(t, ok)
+	assert.Equal(t, []string{"alice"}, room.Players)
+	assert.Equal(t, "ready", room.State)
+	assert.True(t, room.Filled)
+	assert.True(t, room.ServerUp)
+	assert.Equal(t, 4242, room.PID)
+	assert.Equal(t, 7, s.LastRoomID())
+
+	events := s.Events(store.Filter{ClientID: "bob"})
+	require.Len(t, events, 3)
+	assert.Equal(t, store.TicketRejected, events[2].Kind)
+	assert.Len(t, s.Events(store.Filter{RoomUUID: roomUUID, Limit: 2}), 2)
+	assert.Empty(t, s.Events(store.Filter{Since: time.Now().Add(time.Hour)}))
+}
+
+func TestFile_ReplayDropsIncompleteRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "journal.jsonl")
+	s, err := store.OpenFile(path, true)
+	require.NoError(t, err)
+	matchedRoom(t, s)
+	require.NoError(t, s.Close())
+
+	// Запись, оборванная падением процесса
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"seq":11,"kind":"room.remo`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	s, err = store.OpenFile(path, false)
+	require.NoError(t, err)
+	room, ok := s.Room(roomUUID)
+	require.True(t, ok)
+	assert.False(t, room.Removed)
+	assert.Equal(t, []string{"alice"}, room.Players)
+
+	require.NoError(t, s.Append(store.Event{Kind: store.RoomRemoved, RoomUUID: roomUUID}))
+	require.NoError(t, s.Close())
+
+	s, err = store.OpenFile(path, false)
+	require.NoError(t, err)
+	defer s.Close()
+	room, _ = s.Room(roomUUID)
+	assert.True(t, room.Removed)
+	events := s.Events(store.Filter{})
+	assert.Equal(t, uint64(11), events[len(events)-1].Seq)
+}
+
+func TestFile_CompactKeepsUnfinished(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	s, err := store.OpenFile(path, false)
+	require.NoError(t, err)
+	matchedRoom(t, s)
+	require.NoError(t, s.Append(store.Event{Kind: store.ServerExited, RoomUUID: roomUUID}))
+	require.NoError(t, s.Append(store.Event{Kind: store.RoomRemoved, RoomUUID: roomUUID}))
+	require.NoError(t, s.Append(store.Event{Kind: store.TicketQueued, TicketID: "t3", ClientID: "carol"}))
+
+	require.NoError(t, s.Compact(time.Now().Add(time.Minute)))
+	_, ok := s.Room(roomUUID)
+	assert.False(t, ok)
+	_, ok = s.Ticket("t1")
+	assert.False(t, ok)
+	_, ok = s.Ticket("t3")
+	assert.True(t, ok, "queued ticket survives compaction")
+	require.NoError(t, s.Close())
+
+	s, err = store.OpenFile(path, false)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, 7, s.LastRoomID(), "checkpoint keeps the room counter")
+	assert.Len(t, s.Tickets(), 1)
+	require.NoError(t, s.Append(store.Event{Kind: store.TicketLost, TicketID: "t3"}))
+	events := s.Events(store.Filter{TicketID: "t3"})
+	require.Len(t, events, 2)
+	assert.Greater(t, events[1].Seq, uint64(13))
+}
+
+func TestRecover(t *testing.T) {
+	s := store.NewMemory()
+	matchedRoom(t, s)
+	require.NoError(t, s.Append(store.Event{Kind: store.TicketQueued, TicketID: "t3", ClientID: "carol"}))
+
+	recovery := store.Recover(s)
+	assert.Equal(t, 8, recovery.NextRoomID)
+	require.Len(t, recovery.LostTickets, 1)
+	assert.Equal(t, "t3", recovery.LostTickets[0].ID)
+	require.Len(t, recovery.Orphans, 1)
+	assert.Equal(t, 4242, recovery.Orphans[0].PID)
+
+	carol, _ := s.Ticket("t3")
+	assert.Equal(t, store.TicketStateLost, carol.State)
+	assert.Equal(t, store.CodeManagerRestarted, carol.Code)
+	room, _ := s.Room(roomUUID)
+	assert.True(t, room.Removed)
+	assert.False(t, room.ServerUp)
+	assert.Equal(t, store.StateAbandoned, room.State)
+
+	// Повторный рестарт ничего не находит
+	again := store.Recover(s)
+	assert.Empty(t, again.LostTickets)
+	assert.Empty(t, again.AbandonedRooms)
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+// Subscribe пишет в журнал жизненный цикл комнат, их серверов и попавших в них тикетов.
+// События одной комнаты приходят по порядку, поэтому server.assigned всегда
+// оказывается в журнале после room.created.
+func Subscribe(s Store, b *bus.Bus) {
+	bus.Subscribe(b, "store", func(e events.RoomCreated) {
+		Record(s, Event{
+			Kind:     RoomCreated,
+			RoomID:   e.Room.ID,
+			RoomUUID: e.Room.UUID,
+			Mode:     e.Room.Mode,
+			Map:      e.Room.CurrentMap,
+			Version:  e.Room.AppVersion,
+			State:    room.StateStarting,
+		})
+	})
+	bus.Subscribe(b, "store", func(e room.StateChanged) {
+		Record(s, Event{Kind: RoomState, RoomID: e.Room.ID, RoomUUID: e.Room.UUID, State: e.State})
+	})
+	bus.Subscribe(b, "store", func(e events.ServerAssigned) {
+		Record(s, Event{
+			Kind:       ServerAssigned,
+			RoomID:     e.Room.ID,
+			RoomUUID:   e.Room.UUID,
+			Version:    e.Version,
+			PID:        e.PID,
+			Port:       e.Port,
+			Executable: e.Executable,
+		})
+	})
+	bus.Subscribe(b, "store", func(e events.ServerExited) {
+		exited := Event{Kind: ServerExited, RoomID: e.Room.ID, RoomUUID: e.Room.UUID, PID: e.PID}
+		if e.Err != nil {
+			exited.Message = e.Err.Error()
+		}
+		Record(s, exited)
+	})
+	bus.Subscribe(b, "store", func(e events.PlayerJoined) {
+		Record(s, Event{
+			Kind:     TicketAssigned,
+			TicketID: e.Player.TicketID,
+			ClientID: e.Player.ConnectedMessage.ClientID,
+			RoomID:   e.Room.ID,
+			RoomUUID: e.Room.UUID,
+			Version:  e.Player.ConnectedMessage.AppVersion,
+		})
+	})
+	bus.Subscribe(b, "store", func(e events.MatchReady) {
+		Record(s, Event{Kind: RoomFilled, RoomID: e.Room.ID, RoomUUID: e.Room.UUID, Players: e.Room.ReservedPlayers})
+		for _, player := range e.Room.PlayersSnapshot() {
+			Record(s, Event{
+				Kind:     TicketMatched,
+				TicketID: player.TicketID,
+				ClientID: player.ConnectedMessage.ClientID,
+				RoomID:   e.Room.ID,
+				RoomUUID: e.Room.UUID,
+				Team:     player.Team,
+			})
+		}
+	})
+	bus.Subscribe(b, "store", func(e events.PlayerRejected) {
+		Record(s, rejected(e.Player, e.Err))
+	})
+	bus.Subscribe(b, "store", func(e events.RoomCancelled) {
+		cancelled := Event{Kind: RoomCancelled, RoomID: e.Room.ID, RoomUUID: e.Room.UUID}
+		if e.Err != nil {
+			cancelled.Message = e.Err.Error()
+		}
+		Record(s, cancelled)
+		for _, player := range e.Players {
+			Record(s, rejected(player, e.Err))
+		}
+		Record(s, Event{Kind: RoomRemoved, RoomID: e.Room.ID, RoomUUID: e.Room.UUID})
+	})
+	bus.Subscribe(b, "store", func(e room.Ended) {
+		Record(s, Event{Kind: RoomRemoved, RoomID: e.Room.ID, RoomUUID: e.Room.UUID})
+	})
+}
+
+func rejected(connection *_type.PendingConnection, reason error) Event {
+	response := _type.NewErrorResponse(reason)
+	return Event{
+		Kind:     TicketRejected,
+		TicketID: connection.TicketID,
+		ClientID: connection.ConnectedMessage.ClientID,
+		Mode:     connection.ConnectedMessage.Message,
+		Map:      connection.ConnectedMessage.MapName,
+		Version:  connection.ConnectedMessage.AppVersion,
+		Players:  connection.ConnectedMessage.NumberOfPlayers,
+		Code:     response.Code,
+		Message:  response.Message,
+	}
+}
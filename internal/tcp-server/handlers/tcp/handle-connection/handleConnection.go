@@ -2,9 +2,22 @@ package handlers
 
 import (
 	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Tagakama/ServerManager/internal/store"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/auth"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/ratelimit"
 	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/workers"
+	"github.com/Tagakama/ServerManager/internal/tracing"
+	"io"
 	"net"
 	"reflect"
 	"strconv"
@@ -12,21 +25,136 @@ import (
 	"time"
 )
 
+const (
+	CodeTimeout    = "timeout"
+	CodeTooLong    = "message_too_long"
+	CodeReadFailed = "read_failed"
+
+	// writeTimeout - сколько ждём клиента, чтобы отдать ему ошибку
+	writeTimeout = 5 * time.Second
+)
+
+var log = logger.For("handler")
+
+// RoomLeaver освобождает место в комнате, когда клиент перестал ждать.
+type RoomLeaver interface {
+	Leave(connection *_type.PendingConnection, reason error) bool
+}
+
+// Handler разбирает запрос клиента "ClientID:Message:Players:Map:Version[:Credential]",
+// проверяет учётные данные и лимиты и передаёт запрос в пул.
+type Handler struct {
+	Pool   workers.TaskSubmitter
+	Auth   auth.Authenticator
+	Limits *ratelimit.Limiter
+	Bans   *bans.List
+
+	// ReadTimeout ограничивает TLS handshake и чтение запроса, IdleTimeout - ожидание
+	// комнаты без признаков жизни от клиента. Любые байты после запроса продлевают сессию.
+	// Ноль отключает ограничение.
+	ReadTimeout    time.Duration
+	IdleTimeout    time.Duration
+	MaxMessageSize int
+
+	// Tracer - nil отключает трассировку
+	Tracer *tracing.Tracer
+	// Store - журнал тикетов, nil отключает запись
+	Store store.Store
+	// Rooms - куда уходит запрос после пула; по таймауту простоя он убирается из комнаты
+	Rooms RoomLeaver
+}
+
+// New - без Authenticator ClientID принимается на веру.
+func New(pool workers.TaskSubmitter, authenticator auth.Authenticator) *Handler {
+	if authenticator == nil {
+		authenticator = auth.None{}
+	}
+	return &Handler{Pool: pool, Auth: authenticator}
+}
+
 func HandleConnection(conn net.Conn, pool workers.TaskSubmitter) {
+	New(pool, nil).Handle(conn)
+}
+
+func (h *Handler) Handle(conn net.Conn) {
+	acceptedAt := time.Now()
+	if h.ReadTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(h.ReadTimeout))
+	}
+
+	connLog := log.With(logger.Remote(remoteAddr(conn)))
+
+	ticket := h.Tracer.StartAt("matchmaking.ticket", acceptedAt)
+	ticket.SetAttr("remote_addr", remoteAddr(conn))
+	stage := ticket.ChildAt("accept", acceptedAt)
+	// fail закрывает span'ы запроса, который не дошёл до пула
+	fail := func(err error) {
+		stage.SetError(err)
+		stage.End()
+		ticket.SetError(err)
+		ticket.End()
+		reject(conn, err)
+	}
 
-	reader := bufio.NewReader(conn)
-	rawMessage, err := reader.ReadString('\n')
-	rawMessage = strings.TrimSpace(rawMessage)
+	// Соединение занимает слот до закрытия, в том числе пока ждёт заполнения комнаты
+	accepted := conn
+	if h.Limits != nil {
+		tracked, err := h.Limits.Connect(conn)
+		if err != nil {
+			connLog.Info("connection rejected", logger.Err(err))
+			fail(err)
+			return
+		}
+		conn = tracked
+	}
+
+	trusted, err := trustedPeer(accepted)
 	if err != nil {
-		fmt.Println("Error reading from connection: ", err)
+		connLog.Info("TLS handshake failed", logger.Err(err))
+		stage.SetError(err)
+		stage.End()
+		ticket.SetError(err)
+		ticket.End()
+		conn.Close()
+		return
+	}
+	if h.Bans != nil && !trusted {
+		if err := h.Bans.CheckIP(net.ParseIP(ratelimit.IP(conn.RemoteAddr())), time.Now()); err != nil {
+			connLog.Info("request rejected", logger.Err(err))
+			fail(err)
+			return
+		}
+	}
+	if h.Limits != nil && !trusted {
+		if err := h.Limits.AllowIP(conn.RemoteAddr()); err != nil {
+			connLog.Info("request rejected", logger.Err(err))
+			fail(err)
+			return
+		}
+	}
+
+	rawMessage, err := h.readMessage(conn)
+	if err != nil {
+		connLog.Info("failed to read request", logger.Err(err))
+		fail(err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	stage.End()
+	stage = ticket.Child("parse")
+
+	handleRawMessage := strings.SplitN(rawMessage, ":", -1)
+	fieldCount := reflect.TypeOf(_type.Message{}).NumField()
+	credential := ""
+	if len(handleRawMessage) == fieldCount+1 {
+		credential = handleRawMessage[fieldCount]
+		handleRawMessage = handleRawMessage[:fieldCount]
 	}
 
 	var clientConnection = func() (*_type.PendingConnection, error) {
-		handleRawMessage := strings.SplitN(rawMessage, ":", -1)
-		newMessage := _type.Message{}
-		if len(handleRawMessage) != reflect.TypeOf(newMessage).NumField() {
-			fmt.Sprintf("Error message format :%s", rawMessage)
-			return &_type.PendingConnection{Conn: conn}, fmt.Errorf("Format not allowed")
+		if len(handleRawMessage) != fieldCount {
+			return &_type.PendingConnection{Conn: conn}, _type.NewError(_type.CodeBadRequest, false,
+				"Format not allowed, expected ClientID:Message:Players:Map:Version[:Credential]")
 		}
 		return &_type.PendingConnection{Conn: conn,
 			ConnectedMessage: _type.Message{ClientID: handleRawMessage[0],
@@ -34,7 +162,7 @@ func HandleConnection(conn net.Conn, pool workers.TaskSubmitter) {
 				NumberOfPlayers: func(s string) int {
 					i, err := strconv.Atoi(s)
 					if err != nil {
-						fmt.Println("Error converting NumberOfPlayers to int: ", err)
+						connLog.Debug("invalid player count", "value", s, logger.Err(err))
 						return 0
 					}
 
@@ -51,16 +179,189 @@ func HandleConnection(conn net.Conn, pool workers.TaskSubmitter) {
 
 	pendingConnection, err := clientConnection()
 	if err != nil {
-		fmt.Println("Error creating pending connection.", err)
+		connLog.Info("bad request format", "message", rawMessage)
+		fail(err)
 		return
 	}
+	pendingConnection.AcceptedAt = acceptedAt
+	pendingConnection.TicketID = newTicketID()
 
-	fmt.Printf("New request - Time: %s, Client: %s, Map: %s, Player count: %d\n",
-		time.Now().Format("02-01-2006 15:04:05"),
-		pendingConnection.ConnectedMessage.ClientID,
-		pendingConnection.ConnectedMessage.MapName,
-		pendingConnection.ConnectedMessage.NumberOfPlayers)
+	// Доверенный бэкенд ставит в очередь игроков от своего имени, ClientID берётся из сообщения
+	if !trusted {
+		clientID, err := h.Auth.Authenticate(context.Background(), auth.Request{
+			ClientID:   pendingConnection.ConnectedMessage.ClientID,
+			Credential: credential,
+			Payload:    strings.Join(handleRawMessage, ":"),
+			RemoteAddr: remoteAddr(conn),
+		})
+		if err != nil {
+			connLog.Info("request rejected", logger.ClientID(pendingConnection.ConnectedMessage.ClientID), logger.Err(err))
+			h.recordRejected(pendingConnection, err)
+			fail(err)
+			return
+		}
+		pendingConnection.ConnectedMessage.ClientID = clientID
+	}
+	connLog = connLog.With(logger.ClientID(pendingConnection.ConnectedMessage.ClientID))
+	if h.Bans != nil {
+		if err := h.Bans.CheckClient(pendingConnection.ConnectedMessage.ClientID, time.Now()); err != nil {
+			connLog.Info("request rejected", logger.Err(err))
+			h.recordRejected(pendingConnection, err)
+			fail(err)
+			return
+		}
+	}
+	if h.Limits != nil {
+		if err := h.Limits.AllowClient(pendingConnection.ConnectedMessage.ClientID); err != nil {
+			connLog.Info("request rejected", logger.Err(err))
+			h.recordRejected(pendingConnection, err)
+			fail(err)
+			return
+		}
+	}
+
+	stage.End()
+	ticket.SetAttr("client_id", pendingConnection.ConnectedMessage.ClientID)
+	ticket.SetAttr("mode", pendingConnection.ConnectedMessage.Message)
+	ticket.SetAttr("map", pendingConnection.ConnectedMessage.MapName)
+	ticket.SetAttr("version", pendingConnection.ConnectedMessage.AppVersion)
+	pendingConnection.Trace = ticket
+	pendingConnection.Stage = ticket.Child("queue.wait")
+
+	if ticket != nil {
+		connLog = connLog.With(logger.TraceID(ticket.TraceID()))
+	}
+	connLog.Info("new request",
+		"mode", pendingConnection.ConnectedMessage.Message,
+		"map", pendingConnection.ConnectedMessage.MapName,
+		"version", pendingConnection.ConnectedMessage.AppVersion,
+		"players", pendingConnection.ConnectedMessage.NumberOfPlayers)
+
+	store.Record(h.Store, store.Event{
+		Kind:     store.TicketQueued,
+		TicketID: pendingConnection.TicketID,
+		ClientID: pendingConnection.ConnectedMessage.ClientID,
+		Mode:     pendingConnection.ConnectedMessage.Message,
+		Map:      pendingConnection.ConnectedMessage.MapName,
+		Version:  pendingConnection.ConnectedMessage.AppVersion,
+		Players:  pendingConnection.ConnectedMessage.NumberOfPlayers,
+	})
+	if err := h.Pool.AddTask(pendingConnection); err != nil {
+		connLog.Warn("request rejected", logger.Err(err))
+		h.recordRejected(pendingConnection, err)
+		stage = pendingConnection.Stage
+		fail(err)
+		return
+	}
+	if h.IdleTimeout > 0 {
+		go h.watchIdle(conn, pendingConnection)
+	}
+}
 
-	pool.AddTask(pendingConnection)
+// recordRejected - отказ до матчмейкера: авторизация, бан, лимит клиента или полная очередь.
+func (h *Handler) recordRejected(connection *_type.PendingConnection, err error) {
+	response := _type.NewErrorResponse(err)
+	store.Record(h.Store, store.Event{
+		Kind:     store.TicketRejected,
+		TicketID: connection.TicketID,
+		ClientID: connection.ConnectedMessage.ClientID,
+		Mode:     connection.ConnectedMessage.Message,
+		Map:      connection.ConnectedMessage.MapName,
+		Version:  connection.ConnectedMessage.AppVersion,
+		Players:  connection.ConnectedMessage.NumberOfPlayers,
+		Code:     response.Code,
+		Message:  response.Message,
+	})
+}
 
+func newTicketID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// readMessage читает первую строку. EOF после непустой строки без перевода строки допустим.
+func (h *Handler) readMessage(conn net.Conn) (string, error) {
+	var source io.Reader = conn
+	if h.MaxMessageSize > 0 {
+		source = io.LimitReader(conn, int64(h.MaxMessageSize)+1)
+	}
+	rawMessage, err := bufio.NewReader(source).ReadString('\n')
+	if h.MaxMessageSize > 0 && len(strings.TrimRight(rawMessage, "\r\n")) > h.MaxMessageSize {
+		return "", _type.NewError(CodeTooLong, false, "message too long: limit is %d bytes", h.MaxMessageSize)
+	}
+	if err != nil && !(errors.Is(err, io.EOF) && strings.TrimSpace(rawMessage) != "") {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return "", _type.NewError(CodeTimeout, true, "request was not received in time")
+		}
+		return "", _type.NewError(CodeReadFailed, true, "failed to read request: %v", err)
+	}
+	return strings.TrimSpace(rawMessage), nil
+}
+
+// watchIdle держит соединение, пока клиент ждёт комнату. Ответ матчмейкера закрывает
+// соединение, и чтение здесь завершается ошибкой. Замолчавший или отключившийся клиент
+// уходит из комнаты, чтобы его место досталось другим.
+func (h *Handler) watchIdle(conn net.Conn, connection *_type.PendingConnection) {
+	buf := make([]byte, 64)
+	for {
+		conn.SetReadDeadline(time.Now().Add(h.IdleTimeout))
+		_, err := conn.Read(buf)
+		if err == nil {
+			continue
+		}
+		// Соединение закрыл ответ матчмейкера
+		if errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) {
+			return
+		}
+		var reason error = _type.NewError(CodeReadFailed, true, "connection closed by client")
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Info("connection closed: idle", logger.Remote(remoteAddr(conn)), "idle_timeout", h.IdleTimeout)
+			reason = _type.NewError(CodeTimeout, true, "idle timeout")
+		} else {
+			log.Info("client disconnected while waiting for a room", logger.Remote(remoteAddr(conn)), logger.Err(err))
+		}
+		// Отмена раньше Leave: запрос либо ещё не занял место и воркер его пропустит,
+		// либо уже в комнате, и ответ уходит из матчмейкера вместе с освобождением места
+		connection.Cancel()
+		if h.Rooms != nil && h.Rooms.Leave(connection, reason) {
+			return
+		}
+		reject(conn, reason)
+		return
+	}
+}
+
+func reject(conn net.Conn, reason error) {
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	response, err := json.Marshal(_type.NewErrorResponse(reason))
+	if err != nil {
+		log.Error("failed to marshal response", logger.Err(err))
+		return
+	}
+	if _, err := fmt.Fprintf(conn, "%s", string(response)); err != nil {
+		log.Warn("failed to send response", logger.Remote(remoteAddr(conn)), logger.Err(err))
+	}
+}
+
+// trustedPeer - соединение по TLS с клиентским сертификатом, подписанным client_ca.
+func trustedPeer(conn net.Conn) (bool, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return false, nil
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return false, err
+	}
+	return len(tlsConn.ConnectionState().VerifiedChains) > 0, nil
+}
+
+func remoteAddr(conn net.Conn) string {
+	if addr := conn.RemoteAddr(); addr != nil {
+		return addr.String()
+	}
+	return ""
 }
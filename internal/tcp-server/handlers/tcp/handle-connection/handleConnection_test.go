@@ -1,23 +1,37 @@
 package handlers_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Tagakama/ServerManager/internal/config"
 	handlers "github.com/Tagakama/ServerManager/internal/tcp-server/handlers/tcp/handle-connection"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/auth"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/ratelimit"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/type"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/workers"
 )
 
 // Упростим мок
 type MockWorkerPool struct {
 	AddedTasks []_type.PendingConnection
+	Err        error
 }
 
-func (m *MockWorkerPool) AddTask(task _type.PendingConnection) {
-	m.AddedTasks = append(m.AddedTasks, task)
+func (m *MockWorkerPool) AddTask(task *_type.PendingConnection) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.AddedTasks = append(m.AddedTasks, *task)
+	return nil
 }
 
 // Генератор случайной строки
@@ -79,6 +93,8 @@ func TestHandleConnection_MultipleRandomRequests(t *testing.T) {
 			go func() {
 				client.Write([]byte(tc.input))
 			}()
+			// Ответ об ошибке формата не должен блокировать обработчик
+			go io.Copy(io.Discard, client)
 
 			handlers.HandleConnection(server, mockPool)
 
@@ -146,3 +162,275 @@ func TestHandleConnection_StressTest100000(t *testing.T) {
 		}
 	}
 }
+
+func TestHandler_Authentication(t *testing.T) {
+	verifier, err := auth.NewHMAC("0123456789abcdef0123456789abcdef", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := "client1:Join:2:Forest:v1.0.0"
+
+	cases := []struct {
+		name      string
+		input     string
+		shouldAdd bool
+	}{
+		{name: "Signed", input: payload + ":" + verifier.Sign(payload, time.Now()) + "\n", shouldAdd: true},
+		{name: "Unsigned", input: payload + "\n"},
+		{name: "Forged", input: "client2:Join:2:Forest:v1.0.0:" + verifier.Sign(payload, time.Now()) + "\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+
+			mockPool := &MockWorkerPool{}
+			go client.Write([]byte(tc.input))
+
+			responses := make(chan _type.ErrorResponse, 1)
+			go func() {
+				var response _type.ErrorResponse
+				json.NewDecoder(client).Decode(&response)
+				responses <- response
+			}()
+
+			handlers.New(mockPool, verifier).Handle(server)
+
+			if tc.shouldAdd {
+				if len(mockPool.AddedTasks) != 1 || mockPool.AddedTasks[0].ConnectedMessage.ClientID != "client1" {
+					t.Fatalf("Expected authenticated task, got %+v", mockPool.AddedTasks)
+				}
+				return
+			}
+			if len(mockPool.AddedTasks) != 0 {
+				t.Fatalf("Expected no task to be added")
+			}
+			response := <-responses
+			if response.Status != "error" || response.Code != auth.CodeUnauthorized {
+				t.Errorf("Expected unauthorized response, got %+v", response)
+			}
+		})
+	}
+}
+
+func TestHandler_RateLimited(t *testing.T) {
+	handler := handlers.New(&MockWorkerPool{}, nil)
+	handler.Limits = ratelimit.New(&config.Config{RateLimit: config.RateLimit{PerClient: 0.5, PerClientBurst: 1}})
+
+	send := func() _type.ErrorResponse {
+		server, client := net.Pipe()
+		defer client.Close()
+		go client.Write([]byte("client1:Join:1:Forest:v1.0.0\n"))
+
+		responses := make(chan _type.ErrorResponse, 1)
+		go func() {
+			var response _type.ErrorResponse
+			json.NewDecoder(client).Decode(&response)
+			responses <- response
+		}()
+		handler.Handle(server)
+		select {
+		case response := <-responses:
+			return response
+		case <-time.After(100 * time.Millisecond):
+			return _type.ErrorResponse{}
+		}
+	}
+
+	if response := send(); response.Status != "" {
+		t.Fatalf("First request must pass, got %+v", response)
+	}
+	response := send()
+	if response.Code != ratelimit.Code || response.RetryAfter != 2 {
+		t.Errorf("Expected rate limited response with retry_after 2, got %+v", response)
+	}
+}
+
+func TestHandler_Banned(t *testing.T) {
+	banList, err := bans.New(&config.Config{Bans: config.Bans{Path: filepath.Join(t.TempDir(), "bans.json")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := banList.Add(bans.Ban{Kind: bans.KindClient, Value: "cheater", Reason: "aimbot"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mockPool := &MockWorkerPool{}
+	handler := handlers.New(mockPool, nil)
+	handler.Bans = banList
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("cheater:Join:1:Forest:v1.0.0\n"))
+	responses := make(chan _type.ErrorResponse, 1)
+	go func() {
+		var response _type.ErrorResponse
+		json.NewDecoder(client).Decode(&response)
+		responses <- response
+	}()
+	handler.Handle(server)
+
+	if len(mockPool.AddedTasks) != 0 {
+		t.Fatalf("Banned client must not reach the worker pool")
+	}
+	if response := <-responses; response.Code != bans.Code || !strings.Contains(response.Message, "aimbot") {
+		t.Errorf("Expected banned response, got %+v", response)
+	}
+}
+
+// readResponse ждёт ответ сервера, пока Handle работает в другой горутине.
+func readResponse(t *testing.T, client net.Conn) _type.ErrorResponse {
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var response _type.ErrorResponse
+	if err := json.NewDecoder(client).Decode(&response); err != nil {
+		t.Fatalf("Expected error response: %v", err)
+	}
+	return response
+}
+
+func TestHandler_ReadLimits(t *testing.T) {
+	mockPool := &MockWorkerPool{}
+	handler := handlers.New(mockPool, nil)
+	handler.ReadTimeout = 50 * time.Millisecond
+	handler.MaxMessageSize = 64
+
+	t.Run("Silent client", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+		go handler.Handle(server)
+		if response := readResponse(t, client); response.Code != handlers.CodeTimeout {
+			t.Errorf("Expected timeout, got %+v", response)
+		}
+	})
+
+	t.Run("No newline within limit", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+		go handler.Handle(server)
+		go client.Write([]byte(strings.Repeat("a", 1<<20)))
+		if response := readResponse(t, client); response.Code != handlers.CodeTooLong {
+			t.Errorf("Expected message_too_long, got %+v", response)
+		}
+	})
+
+	if len(mockPool.AddedTasks) != 0 {
+		t.Fatalf("Expected no task to be added")
+	}
+}
+
+func TestHandler_IdleTimeout(t *testing.T) {
+	mockPool := &MockWorkerPool{}
+	handler := handlers.New(mockPool, nil)
+	handler.IdleTimeout = 100 * time.Millisecond
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("client1:Join:1:Forest:v1.0.0\n"))
+	handler.Handle(server)
+	if len(mockPool.AddedTasks) != 1 {
+		t.Fatalf("Expected task to be added")
+	}
+
+	// Keepalive продлевает ожидание комнаты
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if _, err := client.Write([]byte("\n")); err != nil {
+			t.Fatalf("Connection closed while client was active: %v", err)
+		}
+	}
+	if response := readResponse(t, client); response.Code != handlers.CodeTimeout {
+		t.Errorf("Expected idle timeout, got %+v", response)
+	}
+}
+
+// fakeRooms отвечает за запрос, как матчмейкер: закрывает его соединение
+type fakeRooms struct {
+	left chan error
+}
+
+func (f *fakeRooms) Leave(connection *_type.PendingConnection, reason error) bool {
+	connection.Conn.Close()
+	f.left <- reason
+	return true
+}
+
+func TestHandler_IdleLeavesRoom(t *testing.T) {
+	rooms := &fakeRooms{left: make(chan error, 1)}
+	handler := handlers.New(&MockWorkerPool{}, nil)
+	handler.IdleTimeout = 50 * time.Millisecond
+	handler.Rooms = rooms
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("client1:Join:1:Forest:v1.0.0\n"))
+	handler.Handle(server)
+
+	select {
+	case reason := <-rooms.left:
+		if response := _type.NewErrorResponse(reason); response.Code != handlers.CodeTimeout {
+			t.Errorf("Expected idle timeout, got %+v", response)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Idle client did not leave its room")
+	}
+}
+
+func TestHandler_DisconnectCancelsRequest(t *testing.T) {
+	rooms := &fakeRooms{left: make(chan error, 1)}
+	pool := &capturingPool{}
+	handler := handlers.New(pool, nil)
+	handler.IdleTimeout = time.Minute
+	handler.Rooms = rooms
+
+	server, client := net.Pipe()
+	go client.Write([]byte("client1:Join:1:Forest:v1.0.0\n"))
+	handler.Handle(server)
+	client.Close()
+
+	select {
+	case <-rooms.left:
+	case <-time.After(time.Second):
+		t.Fatal("Disconnected client did not leave its room")
+	}
+	if !pool.task.Cancelled() {
+		t.Error("Expected request of a disconnected client to be cancelled")
+	}
+}
+
+// capturingPool хранит сам запрос, а не копию: тесту нужна его отмена
+type capturingPool struct {
+	task *_type.PendingConnection
+}
+
+func (p *capturingPool) AddTask(task *_type.PendingConnection) error {
+	p.task = task
+	return nil
+}
+
+func TestHandler_BadFormatResponse(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("brokenmessage\n"))
+	go handlers.HandleConnection(server, &MockWorkerPool{})
+
+	response := readResponse(t, client)
+	if response.Status != "error" || response.Code != _type.CodeBadRequest || response.Retryable || response.Message == "" {
+		t.Errorf("Expected non-retryable bad_request, got %+v", response)
+	}
+}
+
+func TestHandler_PoolFullResponse(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go handlers.HandleConnection(server, &MockWorkerPool{Err: workers.ErrFull})
+
+	client.Write([]byte("client1:Join:1:Forest:v1.0.0\n"))
+	var response _type.ErrorResponse
+	if err := json.NewDecoder(client).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != _type.CodePoolFull || !response.Retryable {
+		t.Errorf("Expected retryable pool_full response, got %+v", response)
+	}
+}
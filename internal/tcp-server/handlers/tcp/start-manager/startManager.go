@@ -1,16 +1,33 @@
 package startManager
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
 	"net"
 )
 
+var log = logger.For("listener")
+
 func New(config *config.Config) (net.Listener, error) {
+	var reloader *certReloader
+	if config.TLS.Cert != "" || config.TLS.Key != "" {
+		var err error
+		if reloader, err = newCertReloader(config.TLS); err != nil {
+			return nil, err
+		}
+	}
+
 	server, err := net.Listen("tcp", fmt.Sprintf("%s:%s", config.Address, config.Port))
 	if err != nil {
-		fmt.Sprintf("Server not listen %v", err)
+		log.Error("failed to listen", "port", config.Port, logger.Err(err))
+		return nil, err
+	}
+	if reloader != nil {
+		log.Info("server is listening", "port", config.Port, "tls", true)
+		return tls.NewListener(server, reloader.TLSConfig()), nil
 	}
-	fmt.Println("Server is listening on " + config.Port)
+	log.Info("server is listening", "port", config.Port, "tls", false)
 	return server, err
 }
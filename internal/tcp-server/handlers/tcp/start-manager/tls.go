@@ -0,0 +1,129 @@
+package startManager
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader отдаёт актуальный tls.Config каждому handshake. Файлы сверяются по
+// времени изменения; если новая пара не читается, продолжаем работать со старой.
+type certReloader struct {
+	settings   config.TLS
+	clientAuth tls.ClientAuthType
+
+	mu       sync.Mutex
+	modTimes [3]time.Time
+	current  *tls.Config
+}
+
+func newCertReloader(settings config.TLS) (*certReloader, error) {
+	if settings.Cert == "" || settings.Key == "" {
+		return nil, errors.New("tls cert and key are both required")
+	}
+
+	reloader := &certReloader{settings: settings}
+	switch settings.ClientAuth {
+	case "", "none":
+		reloader.clientAuth = tls.NoClientCert
+	case "optional":
+		reloader.clientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		reloader.clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown tls client_auth %q", settings.ClientAuth)
+	}
+	if reloader.clientAuth != tls.NoClientCert && settings.ClientCA == "" {
+		return nil, errors.New("tls client_ca is required to verify client certificates")
+	}
+
+	if _, err := reloader.config(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (c *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return c.config()
+		},
+	}
+}
+
+func (c *certReloader) config() (*tls.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTimes, err := c.stat()
+	if err != nil {
+		if c.current != nil {
+			return c.current, nil
+		}
+		return nil, err
+	}
+	if c.current != nil && modTimes == c.modTimes {
+		return c.current, nil
+	}
+
+	loaded, err := c.load()
+	if err != nil {
+		if c.current != nil {
+			log.Warn("TLS certificate reload failed, keeping previous", logger.Err(err))
+			c.modTimes = modTimes
+			return c.current, nil
+		}
+		return nil, err
+	}
+	if c.current != nil {
+		log.Info("TLS certificate reloaded")
+	}
+	c.current, c.modTimes = loaded, modTimes
+	return c.current, nil
+}
+
+func (c *certReloader) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, path := range []string{c.settings.Cert, c.settings.Key, c.settings.ClientCA} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (c *certReloader) load() (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(c.settings.Cert, c.settings.Key)
+	if err != nil {
+		return nil, err
+	}
+	loaded := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   c.clientAuth,
+	}
+	if c.settings.ClientCA != "" {
+		content, err := os.ReadFile(c.settings.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("%s: no certificates found", c.settings.ClientCA)
+		}
+		loaded.ClientCAs = pool
+	}
+	return loaded, nil
+}
@@ -0,0 +1,172 @@
+package startManager_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	startManager "github.com/Tagakama/ServerManager/internal/tcp-server/handlers/tcp/start-manager"
+)
+
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func issue(t *testing.T, name string, parent *issued, isCA bool) *issued {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &issued{cert: cert, key: key, der: der}
+}
+
+func (i *issued) write(t *testing.T, certPath, keyPath string) {
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.der}), 0o644))
+	if keyPath == "" {
+		return
+	}
+	keyDER, err := x509.MarshalECPrivateKey(i.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func (i *issued) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{i.der}, PrivateKey: i.key}
+}
+
+func listen(t *testing.T, settings config.TLS) net.Listener {
+	listener, err := startManager.New(&config.Config{TCPServer: config.TCPServer{Address: "127.0.0.1", Port: "0", TLS: settings}})
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return listener
+}
+
+// peerCertificate возвращает сертификат, который сервер предъявил в handshake.
+func peerCertificate(t *testing.T, addr string, clientConfig *tls.Config) (*x509.Certificate, error) {
+	conn, err := tls.Dial("tcp", addr, clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// В TLS 1.3 отказ сервера в клиентском сертификате приходит только на первом чтении
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var netErr net.Error
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestListener_ReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	first := issue(t, "first", nil, false)
+	first.write(t, certPath, keyPath)
+
+	listener := listen(t, config.TLS{Cert: certPath, Key: keyPath})
+	client := &tls.Config{InsecureSkipVerify: true}
+
+	served, err := peerCertificate(t, listener.Addr().String(), client)
+	require.NoError(t, err)
+	assert.Equal(t, "first", served.Subject.CommonName)
+
+	second := issue(t, "second", nil, false)
+	second.write(t, certPath, keyPath)
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(certPath, later, later))
+
+	served, err = peerCertificate(t, listener.Addr().String(), client)
+	require.NoError(t, err)
+	assert.Equal(t, "second", served.Subject.CommonName)
+
+	// Битый файл не должен ронять listener
+	require.NoError(t, os.WriteFile(certPath, []byte("garbage"), 0o644))
+	served, err = peerCertificate(t, listener.Addr().String(), client)
+	require.NoError(t, err)
+	assert.Equal(t, "second", served.Subject.CommonName)
+}
+
+func TestListener_RequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "backend-ca", nil, true)
+	caPath := filepath.Join(dir, "ca.crt")
+	ca.write(t, caPath, "")
+	certPath, keyPath := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	issue(t, "matchmaker", ca, false).write(t, certPath, keyPath)
+
+	listener := listen(t, config.TLS{Cert: certPath, Key: keyPath, ClientCA: caPath, ClientAuth: "require"})
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	_, err := peerCertificate(t, listener.Addr().String(), &tls.Config{RootCAs: roots})
+	assert.Error(t, err, "client without certificate must be rejected")
+
+	stranger := issue(t, "stranger", nil, false)
+	_, err = peerCertificate(t, listener.Addr().String(), &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{stranger.tlsCertificate()}})
+	assert.Error(t, err, "certificate from another CA must be rejected")
+
+	agent := issue(t, "launcher-agent", ca, false)
+	_, err = peerCertificate(t, listener.Addr().String(), &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{agent.tlsCertificate()}})
+	assert.NoError(t, err)
+}
+
+func TestListener_RejectsInvalidSettings(t *testing.T) {
+	_, err := startManager.New(&config.Config{TCPServer: config.TCPServer{Address: "127.0.0.1", Port: "0", TLS: config.TLS{Cert: "missing.crt"}}})
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	issue(t, "server", nil, false).write(t, certPath, keyPath)
+	_, err = startManager.New(&config.Config{TCPServer: config.TCPServer{Address: "127.0.0.1", Port: "0", TLS: config.TLS{Cert: certPath, Key: keyPath, ClientAuth: "require"}}})
+	assert.Error(t, err, "client_ca is required")
+}
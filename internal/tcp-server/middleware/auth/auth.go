@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"time"
+)
+
+const (
+	MethodNone     = "none"
+	MethodHMAC     = "hmac"
+	MethodJWT      = "jwt"
+	MethodCallback = "callback"
+
+	CodeUnauthorized = "unauthorized"
+	CodeUnavailable  = "auth_unavailable"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrUnavailable - сервис авторизации не ответил, клиент может повторить запрос.
+	ErrUnavailable = errors.New("auth service unavailable")
+)
+
+// Request - то, что клиент прислал в сокет. Payload - исходные поля сообщения
+// без учётных данных, именно их подписывает клиент в режиме hmac.
+type Request struct {
+	ClientID   string
+	Credential string
+	Payload    string
+	RemoteAddr string
+}
+
+// Authenticator возвращает проверенный идентификатор клиента, он заменяет ClientID из сообщения.
+type Authenticator interface {
+	Authenticate(ctx context.Context, req Request) (string, error)
+}
+
+// None сохраняет старое поведение: ClientID принимается на веру.
+type None struct{}
+
+func (None) Authenticate(ctx context.Context, req Request) (string, error) {
+	return req.ClientID, nil
+}
+
+func New(cfg *config.Config) (Authenticator, error) {
+	switch cfg.Auth.Method {
+	case "", MethodNone:
+		return None{}, nil
+	case MethodHMAC:
+		return NewHMAC(cfg.Auth.Secret, time.Duration(cfg.Auth.MaxSkew)*time.Second)
+	case MethodJWT:
+		return NewJWT(cfg.Auth.PublicKeys, cfg.Auth.Issuer, cfg.Auth.Audience)
+	case MethodCallback:
+		return NewCallback(cfg.Auth.CallbackURL, time.Duration(cfg.Auth.CallbackTimeout)*time.Second)
+	}
+	return nil, fmt.Errorf("unknown auth method %q", cfg.Auth.Method)
+}
+
+// Error - отказ в авторизации. errors.Is различает ErrUnauthorized и ErrUnavailable.
+type Error struct {
+	Cause  error
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return e.Cause.Error() + ": " + e.Reason
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+func (e *Error) ErrorCode() string {
+	if e.Cause == ErrUnavailable {
+		return CodeUnavailable
+	}
+	return CodeUnauthorized
+}
+
+func (e *Error) Retryable() bool {
+	return e.Cause == ErrUnavailable
+}
+
+func unauthorized(reason string) error {
+	return &Error{Cause: ErrUnauthorized, Reason: reason}
+}
+
+func unavailable(format string, args ...any) error {
+	return &Error{Cause: ErrUnavailable, Reason: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,148 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/jwt"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/auth"
+	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func TestHMAC(t *testing.T) {
+	verifier, err := auth.NewHMAC(secret, time.Minute)
+	require.NoError(t, err)
+	payload := "client1:Join:2:Forest:v1"
+
+	clientID, err := verifier.Authenticate(context.Background(), auth.Request{
+		ClientID: "client1", Payload: payload, Credential: verifier.Sign(payload, time.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "client1", clientID)
+
+	cases := map[string]auth.Request{
+		"no signature":      {ClientID: "client1", Payload: payload},
+		"other client":      {ClientID: "client2", Payload: "client2:Join:2:Forest:v1", Credential: verifier.Sign(payload, time.Now())},
+		"expired signature": {ClientID: "client1", Payload: payload, Credential: verifier.Sign(payload, time.Now().Add(-2*time.Minute))},
+	}
+	for name, req := range cases {
+		_, err := verifier.Authenticate(context.Background(), req)
+		assert.ErrorIs(t, err, auth.ErrUnauthorized, name)
+	}
+
+	_, err = auth.NewHMAC("short", time.Minute)
+	assert.Error(t, err)
+}
+
+func writePublicKey(t *testing.T, dir, name string, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644))
+	return path
+}
+
+func TestJWT(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	verifier, err := auth.NewJWT([]string{
+		writePublicKey(t, dir, "accounts-rsa", &rsaKey.PublicKey),
+		writePublicKey(t, dir, "accounts-ec", &ecKey.PublicKey),
+		writePublicKey(t, dir, "accounts-ed", edPublic),
+	}, "accounts", "matchmaking")
+	require.NoError(t, err)
+
+	valid := map[string]any{"sub": "player-42", "iss": "accounts", "aud": []string{"matchmaking"}, "exp": time.Now().Add(time.Hour).Unix()}
+	for alg, key := range map[string]any{jwt.RS256: rsaKey, jwt.ES256: ecKey, jwt.EdDSA: edKey} {
+		token, err := jwt.Sign(valid, alg, key)
+		require.NoError(t, err)
+		clientID, err := verifier.Authenticate(context.Background(), auth.Request{ClientID: "claimed", Credential: token})
+		require.NoError(t, err, alg)
+		assert.Equal(t, "player-42", clientID, "identity comes from the token, not the message")
+	}
+
+	withKid, err := jwt.SignWithKeyID(valid, jwt.EdDSA, "accounts-rsa", edKey)
+	require.NoError(t, err)
+	expired, err := jwt.Sign(map[string]any{"sub": "player-42", "iss": "accounts", "aud": "matchmaking", "exp": time.Now().Add(-time.Minute).Unix()}, jwt.RS256, rsaKey)
+	require.NoError(t, err)
+	otherAudience, err := jwt.Sign(map[string]any{"sub": "player-42", "iss": "accounts", "aud": "chat", "exp": time.Now().Add(time.Hour).Unix()}, jwt.RS256, rsaKey)
+	require.NoError(t, err)
+	hmacToken, err := jwt.Sign(valid, jwt.HS256, []byte(secret))
+	require.NoError(t, err)
+
+	for name, token := range map[string]string{
+		"missing":        "",
+		"garbage":        "not-a-token",
+		"kid mismatch":   withKid,
+		"expired":        expired,
+		"other audience": otherAudience,
+		"symmetric alg":  hmacToken,
+	} {
+		_, err := verifier.Authenticate(context.Background(), auth.Request{Credential: token})
+		assert.ErrorIs(t, err, auth.ErrUnauthorized, name)
+	}
+}
+
+func TestCallback(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Credential string `json:"credential"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.Credential {
+		case "good":
+			json.NewEncoder(w).Encode(map[string]string{"client_id": "player-7"})
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer service.Close()
+
+	verifier, err := auth.NewCallback(service.URL, time.Second)
+	require.NoError(t, err)
+
+	clientID, err := verifier.Authenticate(context.Background(), auth.Request{ClientID: "claimed", Credential: "good"})
+	require.NoError(t, err)
+	assert.Equal(t, "player-7", clientID)
+
+	_, err = verifier.Authenticate(context.Background(), auth.Request{Credential: "bad"})
+	assert.ErrorIs(t, err, auth.ErrUnauthorized)
+	assert.Equal(t, auth.CodeUnauthorized, _type.NewErrorResponse(err).Code)
+
+	_, err = verifier.Authenticate(context.Background(), auth.Request{Credential: "broken"})
+	assert.ErrorIs(t, err, auth.ErrUnavailable)
+	response := _type.NewErrorResponse(err)
+	assert.Equal(t, auth.CodeUnavailable, response.Code)
+	assert.True(t, response.Retryable)
+
+	service.Close()
+	_, err = verifier.Authenticate(context.Background(), auth.Request{Credential: "good"})
+	assert.ErrorIs(t, err, auth.ErrUnavailable)
+}
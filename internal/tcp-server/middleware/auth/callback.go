@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Callback отдаёт учётные данные внешнему сервису авторизации.
+// 200 с {"client_id"} - клиент принят, 401/403 - отказ, остальное - сервис недоступен.
+type Callback struct {
+	url    string
+	client *http.Client
+}
+
+type callbackRequest struct {
+	ClientID   string `json:"client_id"`
+	Credential string `json:"credential"`
+	RemoteAddr string `json:"remote_addr"`
+}
+
+type callbackResponse struct {
+	ClientID string `json:"client_id"`
+}
+
+func NewCallback(url string, timeout time.Duration) (*Callback, error) {
+	if url == "" {
+		return nil, errors.New("auth callback_url is required for callback method")
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &Callback{url: url, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (c *Callback) Authenticate(ctx context.Context, req Request) (string, error) {
+	if req.Credential == "" {
+		return "", unauthorized("credential is required")
+	}
+	body, err := json.Marshal(callbackRequest{ClientID: req.ClientID, Credential: req.Credential, RemoteAddr: req.RemoteAddr})
+	if err != nil {
+		return "", err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	response, err := c.client.Do(httpRequest)
+	if err != nil {
+		return "", unavailable("%v", err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", unauthorized("rejected by auth service")
+	default:
+		return "", unavailable("status %d", response.StatusCode)
+	}
+
+	var verified callbackResponse
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<16)).Decode(&verified); err != nil {
+		return "", unavailable("%v", err)
+	}
+	if verified.ClientID == "" {
+		return "", unauthorized("auth service returned no client_id")
+	}
+	return verified.ClientID, nil
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HMAC проверяет подпись общим секретом. Учётные данные: "<unix>.<hex>",
+// где hex = HMAC-SHA256(secret, Payload + ":" + unix). Метка времени
+// ограничивает повтор перехваченного запроса окном maxSkew.
+type HMAC struct {
+	secret  []byte
+	maxSkew time.Duration
+	now     func() time.Time
+}
+
+func NewHMAC(secret string, maxSkew time.Duration) (*HMAC, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("auth secret must be at least 32 bytes")
+	}
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+	return &HMAC{secret: []byte(secret), maxSkew: maxSkew, now: time.Now}, nil
+}
+
+// Sign - подпись, которую должен прислать клиент. Используется в тестах и клиентских утилитах.
+func (h *HMAC) Sign(payload string, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return timestamp + "." + hex.EncodeToString(h.mac(payload, timestamp))
+}
+
+func (h *HMAC) Authenticate(ctx context.Context, req Request) (string, error) {
+	timestamp, signature, ok := strings.Cut(req.Credential, ".")
+	if !ok {
+		return "", unauthorized("signature is required")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", unauthorized("malformed signature timestamp")
+	}
+	if skew := h.now().Sub(time.Unix(unix, 0)); skew > h.maxSkew || skew < -h.maxSkew {
+		return "", unauthorized("signature expired")
+	}
+	provided, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(provided, h.mac(req.Payload, timestamp)) {
+		return "", unauthorized("invalid signature")
+	}
+	return req.ClientID, nil
+}
+
+func (h *HMAC) mac(payload, timestamp string) []byte {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(payload + ":" + timestamp))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/jwt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type publicKey struct {
+	id  string
+	alg string
+	key any
+}
+
+// JWT проверяет токены, выпущенные сервисом аккаунтов. Ключи - PEM (PKIX) файлы,
+// kid токена сравнивается с именем файла без расширения. Идентификатор клиента - sub.
+type JWT struct {
+	keys     []publicKey
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	Expires   int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+}
+
+// audience - в JWT aud бывает строкой или массивом строк.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func NewJWT(paths []string, issuer, aud string) (*JWT, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("auth public_keys are required for jwt method")
+	}
+	verifier := &JWT{issuer: issuer, audience: aud, now: time.Now}
+	for _, path := range paths {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		verifier.keys = append(verifier.keys, key)
+	}
+	return verifier, nil
+}
+
+func (v *JWT) Authenticate(ctx context.Context, req Request) (string, error) {
+	if req.Credential == "" {
+		return "", unauthorized("token is required")
+	}
+	alg, kid, err := jwt.Parse(req.Credential)
+	if err != nil {
+		return "", unauthorized(err.Error())
+	}
+
+	var verified claims
+	err = jwt.ErrUnsupportedAlg
+	for _, key := range v.keys {
+		if key.alg != alg || (kid != "" && key.id != kid) {
+			continue
+		}
+		if err = jwt.Verify(req.Credential, key.key, &verified); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", unauthorized(err.Error())
+	}
+
+	now := v.now().Unix()
+	switch {
+	case verified.Subject == "":
+		return "", unauthorized("token has no subject")
+	case verified.Expires == 0 || now >= verified.Expires:
+		return "", unauthorized("token expired")
+	case verified.NotBefore != 0 && now < verified.NotBefore:
+		return "", unauthorized("token not valid yet")
+	case v.issuer != "" && verified.Issuer != v.issuer:
+		return "", unauthorized("unexpected issuer")
+	case v.audience != "" && !contains(verified.Audience, v.audience):
+		return "", unauthorized("unexpected audience")
+	}
+	return verified.Subject, nil
+}
+
+func loadPublicKey(path string) (publicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return publicKey{}, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return publicKey{}, fmt.Errorf("%s: no PEM block found", path)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return publicKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	switch key := parsed.(type) {
+	case *rsa.PublicKey:
+		return publicKey{id: id, alg: jwt.RS256, key: key}, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return publicKey{}, fmt.Errorf("%s: only P-256 ECDSA keys are supported", path)
+		}
+		return publicKey{id: id, alg: jwt.ES256, key: key}, nil
+	case ed25519.PublicKey:
+		return publicKey{id: id, alg: jwt.EdDSA, key: key}, nil
+	}
+	return publicKey{}, fmt.Errorf("%s: unsupported key type %T", path, parsed)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
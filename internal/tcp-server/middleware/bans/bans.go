@@ -0,0 +1,274 @@
+package bans
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	KindClient = "client"
+	KindIP     = "ip"
+	KindCIDR   = "cidr"
+
+	Code = "banned"
+)
+
+var ErrNotFound = errors.New("ban not found")
+
+// Ban блокирует ClientID, IP или подсеть. Нулевой ExpiresAt - бессрочно.
+type Ban struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	network *net.IPNet
+}
+
+func (b *Ban) Active(now time.Time) bool {
+	return b.ExpiresAt.IsZero() || now.Before(b.ExpiresAt)
+}
+
+// Error - запрос отклонён баном, причина и срок уходят клиенту.
+type Error struct {
+	Ban Ban
+}
+
+func (e *Error) ErrorCode() string {
+	return Code
+}
+
+func (e *Error) Retryable() bool {
+	return false
+}
+
+func (e *Error) Error() string {
+	if e.Ban.ExpiresAt.IsZero() {
+		return fmt.Sprintf("banned: %s", e.Ban.Reason)
+	}
+	return fmt.Sprintf("banned until %s: %s", e.Ban.ExpiresAt.UTC().Format(time.RFC3339), e.Ban.Reason)
+}
+
+// List - баны в JSON-файле. Файл переписывается целиком через временный файл,
+// поэтому после падения на диске остаётся старая или новая версия, но не обрывок.
+type List struct {
+	path string
+
+	mu   sync.RWMutex
+	bans map[string]*Ban
+}
+
+func New(cfg *config.Config) (*List, error) {
+	path, err := filepath.Abs(cfg.Bans.Path)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &List{path: path, bans: loaded}, nil
+}
+
+// Reload перечитывает файл, в том числе по новому пути из конфига. Если файл
+// не читается, остаётся прежний список.
+func (l *List) Reload(cfg *config.Config) error {
+	apply, err := l.PrepareReload(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// PrepareReload читает файл, но подменяет список только apply.
+func (l *List) PrepareReload(cfg *config.Config) (apply func(), err error) {
+	path, err := filepath.Abs(cfg.Bans.Path)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.path = path
+		l.bans = loaded
+	}, nil
+}
+
+func load(path string) (map[string]*Ban, error) {
+	loaded := make(map[string]*Ban)
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return loaded, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []*Ban
+	if err := json.Unmarshal(content, &stored); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, ban := range stored {
+		if err := ban.parse(); err != nil {
+			return nil, fmt.Errorf("%s: ban %s: %w", path, ban.ID, err)
+		}
+		loaded[ban.ID] = ban
+	}
+	return loaded, nil
+}
+
+func (b *Ban) parse() error {
+	switch b.Kind {
+	case KindClient:
+		if b.Value == "" {
+			return errors.New("client id is required")
+		}
+	case KindIP:
+		ip := net.ParseIP(b.Value)
+		if ip == nil {
+			return fmt.Errorf("invalid ip %q", b.Value)
+		}
+		b.Value = ip.String()
+	case KindCIDR:
+		_, network, err := net.ParseCIDR(b.Value)
+		if err != nil {
+			return err
+		}
+		b.Value = network.String()
+		b.network = network
+	default:
+		return fmt.Errorf("unknown ban kind %q", b.Kind)
+	}
+	return nil
+}
+
+func (l *List) Add(ban Ban) (Ban, error) {
+	if err := ban.parse(); err != nil {
+		return Ban{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Ban{}, err
+	}
+	ban.ID = hex.EncodeToString(id)
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now().UTC()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bans[ban.ID] = &ban
+	if err := l.saveLocked(); err != nil {
+		delete(l.bans, ban.ID)
+		return Ban{}, err
+	}
+	return ban, nil
+}
+
+func (l *List) Remove(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ban, ok := l.bans[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(l.bans, id)
+	if err := l.saveLocked(); err != nil {
+		l.bans[id] = ban
+		return err
+	}
+	return nil
+}
+
+// List - действующие баны, старые сначала.
+func (l *List) List(now time.Time) []Ban {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	active := make([]Ban, 0, len(l.bans))
+	for _, ban := range l.bans {
+		if ban.Active(now) {
+			active = append(active, *ban)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+	return active
+}
+
+func (l *List) CheckIP(ip net.IP, now time.Time) error {
+	if ip == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, ban := range l.bans {
+		if !ban.Active(now) {
+			continue
+		}
+		if (ban.Kind == KindIP && ban.Value == ip.String()) || (ban.Kind == KindCIDR && ban.network.Contains(ip)) {
+			return &Error{Ban: *ban}
+		}
+	}
+	return nil
+}
+
+func (l *List) CheckClient(clientID string, now time.Time) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, ban := range l.bans {
+		if ban.Kind == KindClient && ban.Value == clientID && ban.Active(now) {
+			return &Error{Ban: *ban}
+		}
+	}
+	return nil
+}
+
+// saveLocked заодно выбрасывает истёкшие баны, чтобы файл не рос бесконечно.
+func (l *List) saveLocked() error {
+	now := time.Now()
+	stored := make([]*Ban, 0, len(l.bans))
+	for id, ban := range l.bans {
+		if !ban.Active(now) {
+			delete(l.bans, id)
+			continue
+		}
+		stored = append(stored, ban)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.Before(stored[j].CreatedAt) })
+
+	content, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), "."+filepath.Base(l.path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
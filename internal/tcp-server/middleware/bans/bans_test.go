@@ -0,0 +1,72 @@
+package bans_test
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/bans"
+)
+
+func TestList_ChecksAndPersists(t *testing.T) {
+	cfg := &config.Config{Bans: config.Bans{Path: filepath.Join(t.TempDir(), "bans.json")}}
+	list, err := bans.New(cfg)
+	require.NoError(t, err)
+	now := time.Now()
+
+	cheater, err := list.Add(bans.Ban{Kind: bans.KindClient, Value: "cheater", Reason: "aimbot"})
+	require.NoError(t, err)
+	_, err = list.Add(bans.Ban{Kind: bans.KindIP, Value: "203.0.113.7", Reason: "flood", ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = list.Add(bans.Ban{Kind: bans.KindCIDR, Value: "198.51.100.0/24", Reason: "botnet"})
+	require.NoError(t, err)
+
+	var banned *bans.Error
+	require.True(t, errors.As(list.CheckClient("cheater", now), &banned))
+	assert.Equal(t, "aimbot", banned.Ban.Reason)
+	assert.NoError(t, list.CheckClient("fair-player", now))
+
+	assert.Error(t, list.CheckIP(net.ParseIP("203.0.113.7"), now))
+	assert.NoError(t, list.CheckIP(net.ParseIP("203.0.113.7"), now.Add(2*time.Hour)), "ban expires")
+	assert.Error(t, list.CheckIP(net.ParseIP("198.51.100.42"), now), "address inside banned range")
+	assert.NoError(t, list.CheckIP(net.ParseIP("198.51.101.1"), now))
+
+	reloaded, err := bans.New(cfg)
+	require.NoError(t, err)
+	assert.Len(t, reloaded.List(now), 3)
+	assert.Error(t, reloaded.CheckIP(net.ParseIP("198.51.100.42"), now), "CIDR survives reload")
+
+	require.NoError(t, reloaded.Remove(cheater.ID))
+	assert.NoError(t, reloaded.CheckClient("cheater", now))
+	assert.ErrorIs(t, reloaded.Remove(cheater.ID), bans.ErrNotFound)
+
+	reloaded, err = bans.New(cfg)
+	require.NoError(t, err)
+	assert.Len(t, reloaded.List(now), 2)
+
+	// Перечитывание по SIGHUP подхватывает правки, сделанные другим экземпляром
+	require.NoError(t, list.Reload(cfg))
+	assert.NoError(t, list.CheckClient("cheater", now))
+}
+
+func TestList_RejectsInvalidBans(t *testing.T) {
+	list, err := bans.New(&config.Config{Bans: config.Bans{Path: filepath.Join(t.TempDir(), "bans.json")}})
+	require.NoError(t, err)
+
+	for _, ban := range []bans.Ban{
+		{Kind: bans.KindIP, Value: "not-an-ip"},
+		{Kind: bans.KindCIDR, Value: "10.0.0.0/99"},
+		{Kind: bans.KindClient},
+		{Kind: "hwid", Value: "abc"},
+	} {
+		_, err := list.Add(ban)
+		assert.Error(t, err, "%+v", ban)
+	}
+	assert.Empty(t, list.List(time.Now()))
+}
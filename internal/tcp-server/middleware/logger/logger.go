@@ -1 +1,183 @@
 package logger
+
+import (
+	"context"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Поля корреляции: по ним в логах собирается путь одного запроса.
+const (
+	KeyComponent = "component"
+	KeyClientID  = "client_id"
+	KeyRoomID    = "room_id"
+	KeyRoomUUID  = "room_uuid"
+	KeyTaskID    = "task_id"
+	KeyPID       = "pid"
+	KeyRemote    = "remote_addr"
+	KeyError     = "error"
+	KeyTraceID   = "trace_id"
+)
+
+func ClientID(id string) slog.Attr   { return slog.String(KeyClientID, id) }
+func RoomID(id int) slog.Attr        { return slog.Int(KeyRoomID, id) }
+func RoomUUID(uuid string) slog.Attr { return slog.String(KeyRoomUUID, uuid) }
+func TaskID(id int) slog.Attr        { return slog.Int(KeyTaskID, id) }
+func PID(pid int) slog.Attr          { return slog.Int(KeyPID, pid) }
+func Remote(addr string) slog.Attr   { return slog.String(KeyRemote, addr) }
+func Err(err error) slog.Attr        { return slog.Any(KeyError, err) }
+func TraceID(id string) slog.Attr    { return slog.String(KeyTraceID, id) }
+
+var (
+	base atomic.Pointer[slog.Handler]
+
+	mu           sync.Mutex
+	defaultLevel = new(slog.LevelVar)
+	overrides    = map[string]slog.Level{}
+	levels       = map[string]*slog.LevelVar{}
+)
+
+func init() {
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	base.Store(&handler)
+}
+
+// Setup выбирает формат и уровни. По умолчанию они следуют config.Env: в prod - JSON и info,
+// в остальных окружениях - текст и debug. Секция logging переопределяет и то, и другое.
+// Повторный вызов применяет новые настройки к уже созданным логгерам.
+func Setup(cfg *config.Config, output io.Writer) error {
+	apply, err := Prepare(cfg, output)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// Prepare проверяет настройки Setup, ничего не меняя; apply применяет их и не может
+// завершиться ошибкой. Так перечитанный конфиг применяется целиком или не применяется совсем.
+func Prepare(cfg *config.Config, output io.Writer) (apply func(), err error) {
+	format, level := "text", slog.LevelDebug
+	if isProduction(cfg.Env) {
+		format, level = "json", slog.LevelInfo
+	}
+	if cfg.Logging.Format != "" {
+		format = cfg.Logging.Format
+	}
+	if cfg.Logging.Level != "" {
+		parsed, err := parseLevel(cfg.Logging.Level)
+		if err != nil {
+			return nil, err
+		}
+		level = parsed
+	}
+	parsedOverrides := make(map[string]slog.Level, len(cfg.Logging.Components))
+	for component, text := range cfg.Logging.Components {
+		parsed, err := parseLevel(text)
+		if err != nil {
+			return nil, fmt.Errorf("logging.components.%s: %w", component, err)
+		}
+		parsedOverrides[component] = parsed
+	}
+
+	// Фильтрует componentHandler, базовый обработчик пропускает всё
+	options := &slog.HandlerOptions{Level: slog.Level(-8)}
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(output, options)
+	case "text":
+		handler = slog.NewTextHandler(output, options)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		base.Store(&handler)
+		defaultLevel.Set(level)
+		overrides = parsedOverrides
+		for component, componentLevel := range levels {
+			componentLevel.Set(levelFor(component))
+		}
+		slog.SetDefault(slog.New(&componentHandler{level: defaultLevel}))
+	}, nil
+}
+
+// For - логгер подсистемы. Его уровень задаётся в logging.components по имени компонента.
+func For(component string) *slog.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+	level, ok := levels[component]
+	if !ok {
+		level = new(slog.LevelVar)
+		level.Set(levelFor(component))
+		levels[component] = level
+	}
+	return slog.New(&componentHandler{level: level}).With(KeyComponent, component)
+}
+
+func levelFor(component string) slog.Level {
+	if level, ok := overrides[component]; ok {
+		return level
+	}
+	return defaultLevel.Level()
+}
+
+func isProduction(env string) bool {
+	switch strings.ToLower(env) {
+	case "prod", "production":
+		return true
+	}
+	return false
+}
+
+func parseLevel(text string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(text)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", text)
+	}
+	return level, nil
+}
+
+// componentHandler фильтрует по уровню компонента и пишет в текущий базовый обработчик,
+// поэтому логгеры, созданные до Setup, подхватывают формат и вывод после него.
+// WithAttrs и WithGroup откладываются и применяются к базовому обработчику в момент записи.
+type componentHandler struct {
+	level slog.Leveler
+	ops   []func(slog.Handler) slog.Handler
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *componentHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := *base.Load()
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *componentHandler) with(op func(slog.Handler) slog.Handler) *componentHandler {
+	return &componentHandler{level: h.level, ops: append(append([]func(slog.Handler) slog.Handler{}, h.ops...), op)}
+}
@@ -0,0 +1,105 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+)
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestSetup_ProductionJSON(t *testing.T) {
+	// Логгер создан до Setup и всё равно пишет в новый вывод
+	log := logger.For("matchmaker").With(logger.RoomID(7))
+
+	var buf bytes.Buffer
+	require.NoError(t, logger.Setup(&config.Config{Env: "prod"}, &buf))
+
+	log.Debug("hidden")
+	log.Info("room created", logger.ClientID("player-1"), logger.Err(errors.New("boom")))
+
+	records := lines(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "matchmaker", records[0]["component"])
+	assert.Equal(t, float64(7), records[0]["room_id"])
+	assert.Equal(t, "player-1", records[0]["client_id"])
+	assert.Equal(t, "boom", records[0]["error"])
+}
+
+func TestSetup_DevelopmentText(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, logger.Setup(&config.Config{Env: "local"}, &buf))
+
+	logger.For("launcher").Debug("process started", logger.PID(42))
+	assert.Contains(t, buf.String(), "level=DEBUG")
+	assert.Contains(t, buf.String(), "component=launcher")
+	assert.Contains(t, buf.String(), "pid=42")
+}
+
+func TestSetup_ComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := &config.Config{Env: "prod", Logging: config.Logging{
+		Format:     "json",
+		Level:      "warn",
+		Components: map[string]string{"handler": "debug"},
+	}}
+	require.NoError(t, logger.Setup(cfg, &buf))
+
+	logger.For("handler").Debug("verbose handler")
+	logger.For("workers").Info("quiet workers")
+	logger.For("workers").Warn("loud workers")
+
+	records := lines(t, &buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "verbose handler", records[0]["msg"])
+	assert.Equal(t, "loud workers", records[1]["msg"])
+
+	// Повторный Setup меняет уровень уже созданных логгеров
+	buf.Reset()
+	cfg.Logging.Components = nil
+	require.NoError(t, logger.Setup(cfg, &buf))
+	logger.For("handler").Debug("verbose handler")
+	assert.Empty(t, buf.String())
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, logger.Setup(&config.Config{Logging: config.Logging{Level: "loud"}}, &buf))
+	assert.Error(t, logger.Setup(&config.Config{Logging: config.Logging{Format: "xml"}}, &buf))
+	assert.Error(t, logger.Setup(&config.Config{Logging: config.Logging{Components: map[string]string{"room": "chatty"}}}, &buf))
+}
+
+func TestPrepare_AppliesOnlyOnApply(t *testing.T) {
+	var before, after bytes.Buffer
+	require.NoError(t, logger.Setup(&config.Config{Env: "prod"}, &before))
+
+	apply, err := logger.Prepare(&config.Config{Env: "local"}, &after)
+	require.NoError(t, err)
+	logger.For("handler").Info("still old settings")
+	assert.Empty(t, after.String())
+	assert.Contains(t, before.String(), "still old settings")
+
+	apply()
+	logger.For("handler").Debug("new settings")
+	assert.Contains(t, after.String(), "new settings")
+}
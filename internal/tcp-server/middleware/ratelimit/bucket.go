@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval - как часто выбрасываются заполнившиеся bucket'ы: они ничего не ограничивают.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Buckets - token bucket на ключ: rate токенов в секунду, не больше burst в запасе.
+type Buckets struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewBuckets(rate float64, burst int) *Buckets {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Buckets{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+}
+
+// Take забирает токен. Если токенов нет, возвращает время до появления следующего.
+func (b *Buckets) Take(key string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	b.sweep(now)
+
+	current, ok := b.buckets[key]
+	if !ok {
+		current = &bucket{tokens: b.burst, last: now}
+		b.buckets[key] = current
+	}
+	current.tokens = min(b.burst, current.tokens+now.Sub(current.last).Seconds()*b.rate)
+	current.last = now
+
+	if current.tokens < 1 {
+		return time.Duration((1 - current.tokens) / b.rate * float64(time.Second))
+	}
+	current.tokens--
+	return 0
+}
+
+// SetRate меняет лимит на ходу. Накопленные токены сохраняются, но не больше нового burst.
+func (b *Buckets) SetRate(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = rate
+	b.burst = float64(burst)
+	for _, current := range b.buckets {
+		current.tokens = min(current.tokens, b.burst)
+	}
+}
+
+func (b *Buckets) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+	b.lastSweep = now
+	full := time.Duration(b.burst / b.rate * float64(time.Second))
+	for key, current := range b.buckets {
+		if now.Sub(current.last) >= full {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+func (b *Buckets) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.buckets)
+}
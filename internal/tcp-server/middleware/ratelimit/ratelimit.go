@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+const Code = "rate_limited"
+
+// Error - запрос отклонён лимитом. RetryAfter отдаётся клиенту.
+type Error struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rate limited (%s), retry after %d", e.Reason, e.RetryAfterSeconds())
+}
+
+func (e *Error) ErrorCode() string {
+	return Code
+}
+
+func (e *Error) Retryable() bool {
+	return true
+}
+
+// RetryAfterSeconds округляет вверх: клиент, повторивший запрос через указанное время, не упрётся в лимит снова.
+func (e *Error) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limiter объединяет token bucket по IP и по ClientID и лимиты на открытые соединения.
+// Нулевое значение в конфиге отключает соответствующий лимит.
+type Limiter struct {
+	byIP     *Buckets
+	byClient *Buckets
+
+	maxConnections      int
+	maxConnectionsPerIP int
+	retryAfter          time.Duration
+
+	mu          sync.Mutex
+	connections int
+	perIP       map[string]int
+}
+
+func New(cfg *config.Config) *Limiter {
+	return &Limiter{
+		byIP:                NewBuckets(cfg.RateLimit.PerIP, cfg.RateLimit.PerIPBurst),
+		byClient:            NewBuckets(cfg.RateLimit.PerClient, cfg.RateLimit.PerClientBurst),
+		maxConnections:      cfg.RateLimit.MaxConnections,
+		maxConnectionsPerIP: cfg.RateLimit.MaxConnectionsPerIP,
+		retryAfter:          time.Second,
+		perIP:               make(map[string]int),
+	}
+}
+
+// Update применяет новые лимиты из конфига. Уже открытые соединения не закрываются,
+// даже если их теперь больше нового лимита.
+func (l *Limiter) Update(cfg *config.Config) {
+	l.byIP.SetRate(cfg.RateLimit.PerIP, cfg.RateLimit.PerIPBurst)
+	l.byClient.SetRate(cfg.RateLimit.PerClient, cfg.RateLimit.PerClientBurst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxConnections = cfg.RateLimit.MaxConnections
+	l.maxConnectionsPerIP = cfg.RateLimit.MaxConnectionsPerIP
+}
+
+// Connect учитывает соединение до его закрытия. Возвращённый net.Conn освобождает слот в Close.
+func (l *Limiter) Connect(conn net.Conn) (net.Conn, error) {
+	ip := IP(conn.RemoteAddr())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxConnections > 0 && l.connections >= l.maxConnections {
+		return conn, &Error{Reason: "too many connections", RetryAfter: l.retryAfter}
+	}
+	if l.maxConnectionsPerIP > 0 && l.perIP[ip] >= l.maxConnectionsPerIP {
+		return conn, &Error{Reason: "too many connections from " + ip, RetryAfter: l.retryAfter}
+	}
+	l.connections++
+	l.perIP[ip]++
+	return &trackedConn{Conn: conn, release: func() { l.release(ip) }}, nil
+}
+
+func (l *Limiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connections--
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+func (l *Limiter) Connections() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.connections
+}
+
+func (l *Limiter) AllowIP(addr net.Addr) error {
+	if wait := l.byIP.Take(IP(addr), time.Now()); wait > 0 {
+		return &Error{Reason: "requests per IP", RetryAfter: wait}
+	}
+	return nil
+}
+
+func (l *Limiter) AllowClient(clientID string) error {
+	if wait := l.byClient.Take(clientID, time.Now()); wait > 0 {
+		return &Error{Reason: "requests per client", RetryAfter: wait}
+	}
+	return nil
+}
+
+// IP - адрес без порта, чтобы все соединения одного хоста делили лимит.
+func IP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
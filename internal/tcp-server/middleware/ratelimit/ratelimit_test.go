@@ -0,0 +1,97 @@
+package ratelimit_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/ratelimit"
+)
+
+type addrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func connFrom(t *testing.T, ip string, port int) net.Conn {
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	return addrConn{Conn: server, addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: port}}
+}
+
+func TestBuckets(t *testing.T) {
+	buckets := ratelimit.NewBuckets(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, buckets.Take("a", now), "burst must pass")
+	}
+	wait := buckets.Take("a", now)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	assert.Zero(t, buckets.Take("b", now), "keys are independent")
+
+	assert.Zero(t, buckets.Take("a", now.Add(wait)), "token refills at rate")
+	assert.NotZero(t, buckets.Take("a", now.Add(wait)))
+
+	// Заполнившиеся bucket'ы выбрасываются, память не растёт от разовых клиентов
+	buckets.Take("c", now.Add(2*time.Minute))
+	assert.Equal(t, 1, buckets.Len())
+
+	assert.Zero(t, ratelimit.NewBuckets(0, 0).Take("a", now), "zero rate disables the limit")
+}
+
+func TestLimiter_Connections(t *testing.T) {
+	limiter := ratelimit.New(&config.Config{RateLimit: config.RateLimit{MaxConnections: 3, MaxConnectionsPerIP: 2}})
+
+	first, err := limiter.Connect(connFrom(t, "10.0.0.1", 1))
+	require.NoError(t, err)
+	_, err = limiter.Connect(connFrom(t, "10.0.0.1", 2))
+	require.NoError(t, err)
+
+	_, err = limiter.Connect(connFrom(t, "10.0.0.1", 3))
+	var limited *ratelimit.Error
+	require.True(t, errors.As(err, &limited), "per-IP limit")
+	assert.Equal(t, 1, limited.RetryAfterSeconds())
+	assert.Contains(t, err.Error(), "retry after 1")
+
+	_, err = limiter.Connect(connFrom(t, "10.0.0.2", 1))
+	require.NoError(t, err)
+	_, err = limiter.Connect(connFrom(t, "10.0.0.3", 1))
+	assert.Error(t, err, "global limit")
+
+	require.NoError(t, first.Close())
+	first.Close()
+	assert.Equal(t, 2, limiter.Connections(), "double close releases the slot once")
+	_, err = limiter.Connect(connFrom(t, "10.0.0.1", 4))
+	assert.NoError(t, err)
+}
+
+func TestLimiter_Requests(t *testing.T) {
+	limiter := ratelimit.New(&config.Config{RateLimit: config.RateLimit{PerIP: 1, PerIPBurst: 2, PerClient: 0.1, PerClientBurst: 1}})
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1}
+	otherPort := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 2}
+
+	assert.NoError(t, limiter.AllowIP(addr))
+	assert.NoError(t, limiter.AllowIP(otherPort))
+	assert.Error(t, limiter.AllowIP(addr), "ports of one host share the bucket")
+
+	assert.NoError(t, limiter.AllowClient("client1"))
+	err := limiter.AllowClient("client1")
+	var limited *ratelimit.Error
+	require.True(t, errors.As(err, &limited))
+	assert.Equal(t, 10, limited.RetryAfterSeconds())
+
+	// Ноль после перезагрузки конфига снимает лимит
+	limiter.Update(&config.Config{RateLimit: config.RateLimit{PerIP: 1, PerIPBurst: 2}})
+	assert.NoError(t, limiter.AllowClient("client1"))
+	assert.Error(t, limiter.AllowIP(addr), "tokens already taken are kept")
+}
@@ -0,0 +1,85 @@
+package _type
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Коды ошибок, которые видит клиент. Retryable подсказывает клиенту, есть ли смысл
+// повторить тот же запрос позже.
+const (
+	CodeBadRequest       = "bad_request"
+	CodePoolFull         = "pool_full"
+	CodePoolClosed       = "pool_closed"
+	CodeRoomCreateFailed = "room_create_failed"
+	CodeLaunchFailed     = "launch_failed"
+	CodeServerCrashed    = "server_crashed"
+	CodeTokenFailed      = "token_failed"
+	CodeInternal         = "internal"
+)
+
+// ClientError - ошибка, которую можно отдать клиенту как есть.
+// Её реализуют ошибки подсистем: баны, лимиты, версии, авторизация.
+type ClientError interface {
+	error
+	ErrorCode() string
+	Retryable() bool
+}
+
+// Error - ClientError для случаев без собственного типа ошибки.
+type Error struct {
+	Code        string
+	Message     string
+	IsRetryable bool
+}
+
+func NewError(code string, retryable bool, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), IsRetryable: retryable}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+func (e *Error) Retryable() bool {
+	return e.IsRetryable
+}
+
+// ErrorResponse - ответ клиенту при любой ошибке. Поля code, message и retryable есть всегда.
+type ErrorResponse struct {
+	Status     string `json:"status"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Retryable  bool   `json:"retryable"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+	Redirect   string `json:"redirect,omitempty"`
+	MapName    string `json:"map_name,omitempty"`
+}
+
+// NewErrorResponse раскладывает ошибку по схеме ответа. Ошибка без ClientError
+// считается внутренней: её текст клиенту не показывается.
+func NewErrorResponse(err error) ErrorResponse {
+	var clientErr ClientError
+	if !errors.As(err, &clientErr) {
+		return ErrorResponse{Status: "error", Code: CodeInternal, Message: "internal error", Retryable: true}
+	}
+	response := ErrorResponse{
+		Status:    "error",
+		Code:      clientErr.ErrorCode(),
+		Message:   err.Error(),
+		Retryable: clientErr.Retryable(),
+	}
+	var delayed interface{ RetryAfterSeconds() int }
+	if errors.As(err, &delayed) {
+		response.RetryAfter = delayed.RetryAfterSeconds()
+	}
+	var redirected interface{ RedirectAddress() string }
+	if errors.As(err, &redirected) {
+		response.Redirect = redirected.RedirectAddress()
+	}
+	return response
+}
@@ -1,10 +1,38 @@
 package _type
 
-import "net"
+import (
+	"github.com/Tagakama/ServerManager/internal/tracing"
+	"net"
+	"sync/atomic"
+	"time"
+)
 
 type PendingConnection struct {
 	Conn             net.Conn
 	ConnectedMessage Message
+	Team             int
+	// TicketID - идентификатор запроса в журнале (store), назначается при приёме
+	TicketID string
+	// AcceptedAt - время приёма соединения, от него считается время подбора матча
+	AcceptedAt time.Time
+	// Trace - span всего запроса, закрывается вместе с ответом клиенту.
+	// Stage - текущий этап: ожидание в очереди, InviteInRoom или ожидание заполнения комнаты.
+	Trace *tracing.Span
+	Stage *tracing.Span
+	// Warning уходит клиенту вместе с адресом сервера, например об устаревшей версии
+	Warning string
+
+	// cancelled - клиент ушёл или замолчал, пока запрос ждал в очереди; 1 - отменён
+	cancelled int32
+}
+
+// Cancel отмечает запрос, клиенту которого уже ответили: воркер и матчмейкер его пропустят.
+func (c *PendingConnection) Cancel() {
+	atomic.StoreInt32(&c.cancelled, 1)
+}
+
+func (c *PendingConnection) Cancelled() bool {
+	return atomic.LoadInt32(&c.cancelled) == 1
 }
 
 type Message struct {
@@ -19,7 +47,9 @@ type RoomSettings struct {
 	ID         int
 	CurrentMap string
 	AppVersion string
+	Mode       string
 	MaxPlayers int
+	Teams      int
 }
 
 type Response struct {
@@ -29,4 +59,7 @@ type Response struct {
 	Port       int    `json:"-"`
 	MapName    string `json:"map_name"`
 	AppVersion string `json:"-"`
+	Token      string `json:"token,omitempty"`
+	Team       int    `json:"team,omitempty"`
+	Warning    string `json:"warning,omitempty"`
 }
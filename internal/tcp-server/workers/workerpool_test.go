@@ -1,12 +1,14 @@
 package workers_test
 
 import (
-	_ "errors"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/Tagakama/ServerManager/internal/config"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/type"
 	"github.com/Tagakama/ServerManager/internal/tcp-server/workers"
 )
@@ -22,11 +24,44 @@ func (d dummyConn) SetDeadline(t time.Time) error      { return nil }
 func (d dummyConn) SetReadDeadline(t time.Time) error  { return nil }
 func (d dummyConn) SetWriteDeadline(t time.Time) error { return nil }
 
-func makeFakeTask() _type.PendingConnection {
-	return _type.PendingConnection{
+// fakeMatchmaker запоминает обработанных клиентов. С release воркер ждёт, пока тест
+// его отпустит, и очередь можно заполнить.
+type fakeMatchmaker struct {
+	mu      sync.Mutex
+	handled []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingMatchmaker() *fakeMatchmaker {
+	return &fakeMatchmaker{started: make(chan struct{}, 1000), release: make(chan struct{})}
+}
+
+func (m *fakeMatchmaker) InviteInRoom(connection *_type.PendingConnection) {
+	if m.release != nil {
+		m.started <- struct{}{}
+		<-m.release
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handled = append(m.handled, connection.ConnectedMessage.ClientID)
+}
+
+func (m *fakeMatchmaker) Handled() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.handled...)
+}
+
+func makeFakeTask() *_type.PendingConnection {
+	return makeTask("test-client")
+}
+
+func makeTask(clientID string) *_type.PendingConnection {
+	return &_type.PendingConnection{
 		Conn: dummyConn{},
 		ConnectedMessage: _type.Message{
-			ClientID:        "test-client",
+			ClientID:        clientID,
 			Message:         "test-msg",
 			NumberOfPlayers: 2,
 			MapName:         "TestMap",
@@ -35,8 +70,16 @@ func makeFakeTask() _type.PendingConnection {
 	}
 }
 
+func newPool(t *testing.T, m workers.Matchmaker, queue config.Queue) *workers.WorkerPool {
+	pool, err := workers.New(&config.Config{TCPServer: config.TCPServer{WorkerCount: 1, Queue: queue}}, m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return pool
+}
+
 func TestNewWorkerPool_InvalidWorkerCount(t *testing.T) {
-	pool, err := workers.NewWorkerPool(0)
+	pool, err := workers.NewWorkerPool(0, &fakeMatchmaker{})
 	if err == nil {
 		t.Error("Expected error for 0 workers, got nil")
 	}
@@ -46,7 +89,7 @@ func TestNewWorkerPool_InvalidWorkerCount(t *testing.T) {
 }
 
 func TestNewWorkerPool_ValidWorkerCount(t *testing.T) {
-	pool, err := workers.NewWorkerPool(2)
+	pool, err := workers.NewWorkerPool(2, &fakeMatchmaker{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -57,7 +100,7 @@ func TestNewWorkerPool_ValidWorkerCount(t *testing.T) {
 }
 
 func TestAddTask_Success(t *testing.T) {
-	pool, _ := workers.NewWorkerPool(1)
+	pool, _ := workers.NewWorkerPool(1, &fakeMatchmaker{})
 	defer pool.Close()
 
 	err := pool.AddTask(makeFakeTask())
@@ -67,7 +110,7 @@ func TestAddTask_Success(t *testing.T) {
 }
 
 func TestAddTask_AfterClose(t *testing.T) {
-	pool, _ := workers.NewWorkerPool(1)
+	pool, _ := workers.NewWorkerPool(1, &fakeMatchmaker{})
 	_ = pool.Close()
 
 	err := pool.AddTask(makeFakeTask())
@@ -77,28 +120,96 @@ func TestAddTask_AfterClose(t *testing.T) {
 }
 
 func TestAddTask_PoolFull(t *testing.T) {
-	pool, _ := workers.NewWorkerPool(1)
+	m := newBlockingMatchmaker()
+	pool, _ := workers.NewWorkerPool(1, m)
 	defer pool.Close()
+	defer close(m.release)
 
+	// Единственный воркер занят первой задачей
+	_ = pool.AddTask(makeFakeTask())
+	<-m.started
 	// заполним канал
 	for i := 0; i < 100; i++ {
-		_ = pool.AddTask(makeFakeTask())
+		if err := pool.AddTask(makeFakeTask()); err != nil {
+			t.Fatalf("task %d must fit into the queue: %v", i, err)
+		}
 	}
-
 	// 101-я задача должна не влезть
 	err := pool.AddTask(makeFakeTask())
 	if err == nil || err.Error() != "Worker pool is full" {
 		t.Errorf("Expected 'Worker pool is full', got: %v", err)
 	}
+	if !errors.Is(err, workers.ErrFull) {
+		t.Errorf("Expected ErrFull, got: %v", err)
+	}
+}
+
+func TestAddTask_BlockWaitsForRoom(t *testing.T) {
+	m := newBlockingMatchmaker()
+	pool := newPool(t, m, config.Queue{Size: 1, Overflow: workers.OverflowBlock, BlockTimeout: 1})
+	defer pool.Close()
+
+	_ = pool.AddTask(makeTask("first"))
+	<-m.started
+	_ = pool.AddTask(makeTask("queued"))
+
+	// Место освобождается, пока третий ждёт
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		m.release <- struct{}{}
+	}()
+	if err := pool.AddTask(makeTask("waited")); err != nil {
+		t.Fatalf("Expected the task to wait for room, got: %v", err)
+	}
+
+	// Без свободного места ждёт не дольше block_timeout
+	<-m.started
+	started := time.Now()
+	err := pool.AddTask(makeTask("late"))
+	if !errors.Is(err, workers.ErrFull) {
+		t.Errorf("Expected ErrFull after timeout, got: %v", err)
+	}
+	if waited := time.Since(started); waited < time.Second {
+		t.Errorf("Expected to wait for block_timeout, waited %v", waited)
+	}
+	close(m.release)
+}
+
+func TestAddTask_SpillKeepsOrder(t *testing.T) {
+	m := newBlockingMatchmaker()
+	pool := newPool(t, m, config.Queue{Size: 1, Overflow: workers.OverflowSpill, SpillSize: 2})
+
+	_ = pool.AddTask(makeTask("c0"))
+	<-m.started
+	for i := 1; i <= 3; i++ {
+		if err := pool.AddTask(makeTask(fmt.Sprintf("c%d", i))); err != nil {
+			t.Fatalf("task c%d must fit into the queues: %v", i, err)
+		}
+	}
+	if err := pool.AddTask(makeTask("c4")); !errors.Is(err, workers.ErrFull) {
+		t.Errorf("Expected ErrFull when both queues are full, got: %v", err)
+	}
+
+	close(m.release)
+	// Close дожидается, пока вторая очередь перельётся в основную
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(m.Handled()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if handled := fmt.Sprint(m.Handled()); handled != "[c0 c1 c2 c3]" {
+		t.Errorf("Expected spilled tasks in order, got %s", handled)
+	}
 }
 
 func TestClose_Twice(t *testing.T) {
-	pool, _ := workers.NewWorkerPool(1)
+	pool, _ := workers.NewWorkerPool(1, &fakeMatchmaker{})
 	err := pool.Close()
 	if err != nil {
 		t.Fatalf("unexpected error on first close: %v", err)
 	}
-
 	err = pool.Close()
 	if err == nil || err.Error() != "Worker pool is closed" {
 		t.Errorf("Expected error on second close, got: %v", err)
@@ -106,7 +217,8 @@ func TestClose_Twice(t *testing.T) {
 }
 
 func TestWorkerPool_ProcessesTasks(t *testing.T) {
-	pool, _ := workers.NewWorkerPool(2)
+	m := &fakeMatchmaker{}
+	pool, _ := workers.NewWorkerPool(2, m)
 	defer pool.Close()
 
 	for i := 0; i < 5; i++ {
@@ -116,19 +228,22 @@ func TestWorkerPool_ProcessesTasks(t *testing.T) {
 		}
 	}
 
-	// Дать немного времени на выполнение (worker просто fmt.Sprintf пишет)
+	// Дать немного времени на выполнение
 	time.Sleep(100 * time.Millisecond)
+	if handled := len(m.Handled()); handled != 5 {
+		t.Errorf("Expected 5 handled tasks, got %d", handled)
+	}
 }
 
 func TestWorkerPool_Stress(t *testing.T) {
-	wp, _ := workers.NewWorkerPool(4)
-	defer wp.Close()
+	m := &fakeMatchmaker{}
+	wp, _ := workers.NewWorkerPool(4, m)
 
 	mockConn := dummyConn{}
-
+	rejected := 0
 	for i := 0; i < 1000; i++ {
 		task := workers.Task{ID: i,
-			Request: _type.PendingConnection{
+			Request: &_type.PendingConnection{
 				Conn: mockConn,
 				ConnectedMessage: _type.Message{
 					ClientID: fmt.Sprintf("Client-%d", i),
@@ -136,7 +251,76 @@ func TestWorkerPool_Stress(t *testing.T) {
 				},
 			},
 		}
-		wp.Submit(task)
+		if err := wp.Submit(task); err != nil {
+			rejected++
+		}
+	}
+	wp.Close()
+
+	// Каждая задача либо обработана, либо получила отказ - ни одна не теряется
+	deadline := time.Now().Add(time.Second)
+	for len(m.Handled())+rejected < 1000 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
 	}
+	if handled := len(m.Handled()); handled+rejected != 1000 {
+		t.Errorf("Expected 1000 handled or rejected tasks, got %d handled and %d rejected", handled, rejected)
+	}
+}
 
+func TestShutdown_FinishesQueueAndRejectsWithReason(t *testing.T) {
+	m := newBlockingMatchmaker()
+	pool := newPool(t, m, config.Queue{Size: 2, Overflow: workers.OverflowReject})
+
+	_ = pool.AddTask(makeTask("c0"))
+	<-m.started
+	_ = pool.AddTask(makeTask("c1"))
+	if pending := pool.Pending(); pending != 2 {
+		t.Fatalf("Expected 2 pending tasks, got %d", pending)
+	}
+
+	reason := errors.New("maintenance")
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- pool.Shutdown(reason) }()
+	close(m.release)
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return after the queue was processed")
+	}
+
+	// Shutdown возвращается, только когда очередь доработана
+	if handled := fmt.Sprint(m.Handled()); handled != "[c0 c1]" {
+		t.Errorf("Expected queued tasks to be handled before Shutdown returns, got %s", handled)
+	}
+	if pending := pool.Pending(); pending != 0 {
+		t.Errorf("Expected no pending tasks, got %d", pending)
+	}
+	if err := pool.AddTask(makeTask("c2")); !errors.Is(err, reason) {
+		t.Errorf("Expected the shutdown reason for a late task, got: %v", err)
+	}
+}
+
+func TestWorkerPool_SkipsCancelledTasks(t *testing.T) {
+	m := newBlockingMatchmaker()
+	pool := newPool(t, m, config.Queue{Size: 2, Overflow: workers.OverflowReject})
+
+	_ = pool.AddTask(makeTask("c0"))
+	<-m.started
+	gone := makeTask("gone")
+	_ = pool.AddTask(gone)
+	gone.Cancel()
+	close(m.release)
+
+	if err := pool.Shutdown(workers.ErrClosed); err != nil {
+		t.Fatal(err)
+	}
+	if handled := fmt.Sprint(m.Handled()); handled != "[c0]" {
+		t.Errorf("Expected cancelled task to be skipped, got %s", handled)
+	}
+	if pending := pool.Pending(); pending != 0 {
+		t.Errorf("Expected no pending tasks, got %d", pending)
+	}
 }
@@ -1,15 +1,36 @@
 package workers
 
 import (
-	"errors"
 	"fmt"
-	"github.com/Tagakama/ServerManager/internal/matchmaking/matchmaker"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
 	_type "github.com/Tagakama/ServerManager/internal/tcp-server/type"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Политики переполнения очереди, config.Queue.Overflow.
+const (
+	OverflowReject = "reject"
+	OverflowBlock  = "block"
+	OverflowSpill  = "spill"
+)
+
+// Ошибки AddTask уходят клиенту как есть: оба отказа можно повторить позже.
+var (
+	ErrFull   = _type.NewError(_type.CodePoolFull, true, "Worker pool is full")
+	ErrClosed = _type.NewError(_type.CodePoolClosed, true, "Worker pool is closed")
 )
 
 type TaskSubmitter interface {
-	AddTask(task *_type.PendingConnection)
+	AddTask(task *_type.PendingConnection) error
+}
+
+// Matchmaker обрабатывает задачи пула.
+type Matchmaker interface {
+	InviteInRoom(connection *_type.PendingConnection)
 }
 
 type Task struct {
@@ -17,104 +38,213 @@ type Task struct {
 	Request *_type.PendingConnection
 }
 
+// WorkerPool - очередь запросов перед воркерами. Когда она полна, запрос по политике
+// Overflow сразу получает ErrFull, ждёт места до blockTimeout или уходит во вторую
+// очередь spill, которая переливается в основную по мере её освобождения.
 type WorkerPool struct {
-	isClosed   bool
-	tasks      chan Task
-	results    chan Result
-	mu         sync.Mutex
-	matchMaker *matchmaker.Matchmaker
+	isClosed bool
+	// closedErr получают задачи после закрытия: ErrClosed или причина из Shutdown
+	closedErr error
+	// mu: AddTask держит на чтение, Close - на запись, поэтому в закрытый канал никто не пишет
+	mu        sync.RWMutex
+	tasks     chan Task
+	spill     chan Task
+	spilled   atomic.Int64
+	spillDone chan struct{}
+	// pending - принятые задачи, которые ещё не обработал матчмейкер
+	pending      atomic.Int64
+	done         chan struct{}
+	overflow     string
+	blockTimeout time.Duration
+	matchMaker   Matchmaker
 }
 
-type Result struct {
-	TaskID int
-	Output string
-	Err    error
-}
+// taskCount - последний выданный ID задачи, общий для всех пулов процесса.
+var taskCount atomic.Int64
+
+var log = logger.For("workers")
 
-var TaskCount int = 1
+var (
+	tasksQueued  = metrics.Default.Counter("sm_tasks_queued_total", "Requests accepted into the worker pool queue.")
+	tasksDropped = metrics.Default.Counter("sm_tasks_dropped_total", "Requests rejected by the worker pool.", "reason")
+	queueDepth   = metrics.Default.Gauge("sm_task_queue_depth", "Requests waiting for a free worker.")
+	spillDepth   = metrics.Default.Gauge("sm_task_spill_depth", "Requests waiting in the overflow queue for room in the main one.")
+)
+
+// NewWorkerPool - пул с очередью на 100 запросов, отказывающий при её переполнении.
+func NewWorkerPool(numWorkers int, m Matchmaker) (*WorkerPool, error) {
+	return New(&config.Config{TCPServer: config.TCPServer{
+		WorkerCount: numWorkers,
+		Queue:       config.Queue{Size: 100, Overflow: OverflowReject},
+	}}, m)
+}
 
-func NewWorkerPool(numWorkers int, m *matchmaker.Matchmaker) *WorkerPool {
-	if numWorkers <= 0 {
-		//return nil, errors.New("Invalid number of workers")
+func New(cfg *config.Config, m Matchmaker) (*WorkerPool, error) {
+	settings := cfg.Queue
+	if cfg.WorkerCount <= 0 {
+		return nil, fmt.Errorf("invalid number of workers %d", cfg.WorkerCount)
+	}
+	if settings.Size <= 0 {
+		return nil, fmt.Errorf("invalid queue size %d", settings.Size)
+	}
+	spillSize := 0
+	switch settings.Overflow {
+	case "", OverflowReject, OverflowBlock:
+	case OverflowSpill:
+		spillSize = settings.SpillSize
+	default:
+		return nil, fmt.Errorf("unknown queue overflow policy %q", settings.Overflow)
 	}
 
 	pool := &WorkerPool{
-		isClosed:   false,
-		tasks:      make(chan Task, 100),
-		results:    make(chan Result, 100),
-		mu:         sync.Mutex{},
-		matchMaker: m,
+		tasks:        make(chan Task, settings.Size),
+		spill:        make(chan Task, spillSize),
+		spillDone:    make(chan struct{}),
+		done:         make(chan struct{}),
+		overflow:     settings.Overflow,
+		blockTimeout: time.Duration(settings.BlockTimeout) * time.Second,
+		matchMaker:   m,
 	}
-
-	go pool.Proccess(numWorkers)
-	fmt.Printf("Worker pool created with %d workers.\n", numWorkers)
-	return pool
+	go func() {
+		pool.Proccess(cfg.WorkerCount)
+		close(pool.done)
+	}()
+	go pool.drainSpill()
+	log.Info("worker pool created", "workers", cfg.WorkerCount, "queue", settings.Size, "overflow", pool.overflow)
+	return pool, nil
 }
 
+// Proccess запускает воркеры и возвращается, когда Close закрыл очередь и они её доработали.
+// Ответ клиенту отправляет матчмейкер, воркеру возвращать нечего.
 func (wp *WorkerPool) Proccess(numWorkers int) {
 	wg := sync.WaitGroup{}
 	wg.Add(numWorkers)
-
 	for i := 0; i < numWorkers; i++ {
 		go func() {
 			defer wg.Done()
 			for task := range wp.tasks {
-
-				wp.matchMaker.InviteInRoom(task.Request)
-
-				wp.results <- Result{
-					TaskID: task.ID,
-					Output: fmt.Sprintf("handled %d", task.ID),
+				queueDepth.Dec()
+				task.Request.Stage.End()
+				// Клиент ушёл, пока запрос ждал в очереди: ответ ему уже отправлен
+				if task.Request.Cancelled() {
+					log.Debug("task skipped, request was cancelled", logger.TaskID(task.ID), logger.ClientID(task.Request.ConnectedMessage.ClientID))
+					wp.pending.Add(-1)
+					continue
 				}
+				log.Debug("task started", logger.TaskID(task.ID), logger.ClientID(task.Request.ConnectedMessage.ClientID))
+				wp.matchMaker.InviteInRoom(task.Request)
+				wp.pending.Add(-1)
 			}
 		}()
 	}
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for result := range wp.results {
-			if result.Err != nil {
-				fmt.Printf("Task results %d returned an error: %s\n", result.TaskID, result.Err)
-			}
-			//TODO Возврат ответа клиенту о статусе запроса
-		}
-	}()
-
 	wg.Wait()
-	//close(wp.results)
 }
 
-func (wp *WorkerPool) AddTask(task *_type.PendingConnection) {
-	wp.mu.Lock()
-	defer wp.mu.Unlock()
+// AddTask ставит запрос в очередь под новым ID. Ошибку вызывающий отдаёт клиенту.
+func (wp *WorkerPool) AddTask(task *_type.PendingConnection) error {
+	return wp.Submit(Task{ID: int(taskCount.Add(1)), Request: task})
+}
+
+func (wp *WorkerPool) Submit(task Task) error {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
 	if wp.isClosed {
-		errors.New("Worker pool is closed")
+		tasksDropped.Inc("closed")
+		return wp.closedErr
+	}
+	// Задача считается сразу, чтобы Pending не терял её между очередями; отказ её вычитает
+	wp.pending.Add(1)
+
+	// Пока во второй очереди кто-то ждёт, новые запросы встают за ними, а не обгоняют
+	if wp.overflow != OverflowSpill || wp.spilled.Load() == 0 {
+		// Глубину увеличиваем до отправки, иначе воркер может уменьшить её раньше
+		queueDepth.Inc()
+		select {
+		case wp.tasks <- task:
+			tasksQueued.Inc()
+			return nil
+		default:
+			queueDepth.Dec()
+		}
 	}
 
-	select {
-	case wp.tasks <- Task{TaskCount, task}:
-
-	default:
-		errors.New("Worker pool is full")
+	switch wp.overflow {
+	case OverflowBlock:
+		timer := time.NewTimer(wp.blockTimeout)
+		defer timer.Stop()
+		queueDepth.Inc()
+		select {
+		case wp.tasks <- task:
+			tasksQueued.Inc()
+			return nil
+		case <-timer.C:
+			wp.pending.Add(-1)
+			queueDepth.Dec()
+			tasksDropped.Inc("timeout")
+			log.Warn("task queue is still full, request rejected", logger.TaskID(task.ID), "waited", wp.blockTimeout, "depth", len(wp.tasks))
+			return ErrFull
+		}
+	case OverflowSpill:
+		// Счётчик учитывает и задачу, которую drainSpill уже вынул, но ещё не переложил,
+		// поэтому место в канале есть всегда, когда он не превышен
+		if wp.spilled.Add(1) <= int64(cap(wp.spill)) {
+			spillDepth.Inc()
+			wp.spill <- task
+			tasksQueued.Inc()
+			return nil
+		}
+		wp.spilled.Add(-1)
 	}
+	wp.pending.Add(-1)
+	tasksDropped.Inc("full")
+	log.Warn("task queue is full, request rejected", logger.TaskID(task.ID), "depth", len(wp.tasks), "spilled", wp.spilled.Load())
+	return ErrFull
 }
 
-func (p *WorkerPool) Submit(task Task) {
-	p.tasks <- task
+// drainSpill переливает вторую очередь в основную по порядку. Завершается, когда
+// Close закрыл вторую очередь и она опустела.
+func (wp *WorkerPool) drainSpill() {
+	defer close(wp.spillDone)
+	for task := range wp.spill {
+		queueDepth.Inc()
+		wp.tasks <- task
+		spillDepth.Dec()
+		wp.spilled.Add(-1)
+	}
 }
 
-func (p *WorkerPool) GetResults() <-chan Result {
-	return p.results
+// Pending - сколько принятых задач ждёт в очередях или обрабатывается воркерами.
+func (wp *WorkerPool) Pending() int {
+	return int(wp.pending.Load())
 }
 
+// Close больше не принимает задачи; уже принятые, в том числе из второй очереди, будут обработаны.
 func (p *WorkerPool) Close() error {
+	return p.close(ErrClosed)
+}
+
+// Shutdown закрывает пул, как Close, но новые задачи получают reason, и возвращается,
+// только когда воркеры обработали все принятые задачи.
+func (p *WorkerPool) Shutdown(reason error) error {
+	if err := p.close(reason); err != nil {
+		return err
+	}
+	<-p.done
+	return nil
+}
+
+func (p *WorkerPool) close(reason error) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
 	if p.isClosed {
-		return errors.New("Worker pool is closed")
+		p.mu.Unlock()
+		return ErrClosed
 	}
 	p.isClosed = true
+	p.closedErr = reason
+	p.mu.Unlock()
+
+	close(p.spill)
+	<-p.spillDone
 	close(p.tasks)
 	return nil
 }
@@ -0,0 +1,225 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ExporterNone = "none"
+	ExporterFile = "file"
+	ExporterOTLP = "otlp"
+
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// Exporter получает каждый завершённый span. Export не должен блокировать пайплайн надолго.
+type Exporter interface {
+	Export(span SpanData)
+	Close() error
+}
+
+// Tracer создаёт корневые span'ы. Дочерние создаются от родителя, поэтому дальше
+// по пайплайну передаётся только *Span. Nil Tracer и nil Span ничего не делают.
+type Tracer struct {
+	service  string
+	exporter Exporter
+}
+
+// New возвращает nil, если трассировка выключена.
+func New(cfg *config.Config) (*Tracer, error) {
+	var exporter Exporter
+	var err error
+	switch cfg.Tracing.Exporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterFile:
+		exporter, err = NewFileExporter(cfg.Tracing.Path)
+	case ExporterOTLP:
+		exporter, err = NewOTLPExporter(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName)
+	default:
+		err = fmt.Errorf("unknown tracing exporter %q", cfg.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewTracer(cfg.Tracing.ServiceName, exporter), nil
+}
+
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter}
+}
+
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.Close()
+}
+
+// Start открывает новый trace.
+func (t *Tracer) Start(name string) *Span {
+	return t.StartAt(name, time.Now())
+}
+
+func (t *Tracer) StartAt(name string, start time.Time) *Span {
+	if t == nil {
+		return nil
+	}
+	return t.newSpan(name, newID(16), "", start)
+}
+
+// StartRemote продолжает trace, пришедший в заголовке traceparent.
+func (t *Tracer) StartRemote(name, traceparent string) (*Span, error) {
+	if t == nil {
+		return nil, nil
+	}
+	traceID, parentID, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return nil, err
+	}
+	return t.newSpan(name, traceID, parentID, time.Now()), nil
+}
+
+func (t *Tracer) newSpan(name, traceID, parentID string, start time.Time) *Span {
+	return &Span{tracer: t, data: SpanData{
+		TraceID:      traceID,
+		SpanID:       newID(8),
+		ParentSpanID: parentID,
+		Name:         name,
+		Service:      t.service,
+		Start:        start,
+	}}
+}
+
+// SpanData - то, что уходит в экспортёр.
+type SpanData struct {
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Name          string         `json:"name"`
+	Service       string         `json:"service,omitempty"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        string         `json:"status,omitempty"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *Span) Child(name string) *Span {
+	return s.ChildAt(name, time.Now())
+}
+
+// ChildAt - span этапа, начало которого известно заранее, например ожидание в очереди.
+func (s *Span) ChildAt(name string, start time.Time) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.newSpan(name, s.data.TraceID, s.data.SpanID, start)
+}
+
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError помечает span ошибкой. Nil err ставит статус ok.
+func (s *Span) SetError(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.data.Status, s.data.StatusMessage = StatusOK, ""
+		return
+	}
+	s.data.Status, s.data.StatusMessage = StatusError, err.Error()
+}
+
+// End отправляет span в экспортёр. Повторный вызов ничего не делает.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.exporter.Export(data)
+}
+
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID
+}
+
+// Traceparent - W3C trace context для передачи дальше, например игровому серверу.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.data.TraceID + "-" + s.data.SpanID + "-01"
+}
+
+var errTraceparent = errors.New("invalid traceparent")
+
+func ParseTraceparent(value string) (traceID, spanID string, err error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || parts[0] != "00" || !isHexID(parts[1], 16) || !isHexID(parts[2], 8) || len(parts[3]) != 2 {
+		return "", "", fmt.Errorf("%w: %q", errTraceparent, value)
+	}
+	return parts[1], parts[2], nil
+}
+
+func isHexID(value string, size int) bool {
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != size {
+		return false
+	}
+	for _, b := range decoded {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func newID(size int) string {
+	buf := make([]byte, size)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,166 @@
+package tracing_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/tracing"
+)
+
+func readSpans(t *testing.T, path string) map[string]tracing.SpanData {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	spans := make(map[string]tracing.SpanData)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var span tracing.SpanData
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans[span.Name] = span
+	}
+	require.NoError(t, scanner.Err())
+	return spans
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces", "spans.jsonl")
+	tracer, err := tracing.New(&config.Config{Tracing: config.Tracing{Exporter: tracing.ExporterFile, Path: path, ServiceName: "sm"}})
+	require.NoError(t, err)
+
+	accepted := time.Now().Add(-time.Second)
+	ticket := tracer.StartAt("matchmaking.ticket", accepted)
+	queue := ticket.ChildAt("queue.wait", accepted)
+	queue.End()
+	launch := ticket.Child("LaunchGameServer")
+	launch.SetAttr("version", "v1")
+	launch.SetError(errors.New("timeout"))
+	launch.End()
+	launch.End()
+	ticket.End()
+	require.NoError(t, tracer.Close())
+
+	spans := readSpans(t, path)
+	require.Len(t, spans, 3)
+	root := spans["matchmaking.ticket"]
+	assert.Empty(t, root.ParentSpanID)
+	assert.Equal(t, "sm", root.Service)
+	assert.GreaterOrEqual(t, root.Duration(), time.Second)
+	for _, name := range []string{"queue.wait", "LaunchGameServer"} {
+		assert.Equal(t, root.TraceID, spans[name].TraceID, name)
+		assert.Equal(t, root.SpanID, spans[name].ParentSpanID, name)
+	}
+	assert.Equal(t, "v1", spans["LaunchGameServer"].Attributes["version"])
+	assert.Equal(t, tracing.StatusError, spans["LaunchGameServer"].Status)
+	assert.Equal(t, "timeout", spans["LaunchGameServer"].StatusMessage)
+}
+
+func TestTraceparent(t *testing.T) {
+	tracer := tracing.NewTracer("sm", &discard{})
+	span := tracer.Start("LaunchGameServer")
+
+	traceID, spanID, err := tracing.ParseTraceparent(span.Traceparent())
+	require.NoError(t, err)
+	assert.Equal(t, span.TraceID(), traceID)
+
+	// Игровой сервер продолжает trace от span'а запуска
+	remote, err := tracer.StartRemote("match", span.Traceparent())
+	require.NoError(t, err)
+	assert.Equal(t, span.TraceID(), remote.TraceID())
+	_, remoteSpanID, err := tracing.ParseTraceparent(remote.Traceparent())
+	require.NoError(t, err)
+	assert.NotEqual(t, spanID, remoteSpanID)
+
+	for _, invalid := range []string{
+		"",
+		"00-abc-def-01",
+		"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-zzad6b7169203331-01",
+	} {
+		_, _, err := tracing.ParseTraceparent(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	tracer, err := tracing.New(&config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, tracer)
+
+	// Выключенная трассировка не требует проверок на nil по всему пайплайну
+	span := tracer.Start("matchmaking.ticket")
+	child := span.Child("parse")
+	child.SetAttr("client_id", "c1")
+	child.SetError(errors.New("boom"))
+	child.End()
+	span.End()
+	assert.Empty(t, span.Traceparent())
+	assert.NoError(t, tracer.Close())
+
+	_, err = tracing.New(&config.Config{Tracing: config.Tracing{Exporter: "zipkin"}})
+	assert.Error(t, err)
+	_, err = tracing.New(&config.Config{Tracing: config.Tracing{Exporter: tracing.ExporterOTLP}})
+	assert.Error(t, err)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var requests []map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		requests = append(requests, body)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter, err := tracing.NewOTLPExporter(collector.URL, "sm")
+	require.NoError(t, err)
+	tracer := tracing.NewTracer("sm", exporter)
+	ticket := tracer.Start("matchmaking.ticket")
+	child := ticket.Child("InviteInRoom")
+	child.SetAttr("players", 2)
+	child.SetError(errors.New("pool is full"))
+	child.End()
+	ticket.End()
+	require.NoError(t, tracer.Close(), "close flushes the queue")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 1)
+	resourceSpans := requests[0]["resourceSpans"].([]any)[0].(map[string]any)
+	resource := resourceSpans["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	assert.Equal(t, "service.name", resource["key"])
+	assert.Equal(t, "sm", resource["value"].(map[string]any)["stringValue"])
+
+	spans := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	require.Len(t, spans, 2)
+	invite := spans[0].(map[string]any)
+	assert.Equal(t, "InviteInRoom", invite["name"])
+	assert.Equal(t, ticket.TraceID(), invite["traceId"])
+	assert.NotEmpty(t, invite["parentSpanId"])
+	assert.Equal(t, map[string]any{"code": float64(2), "message": "pool is full"}, invite["status"])
+	attribute := invite["attributes"].([]any)[0].(map[string]any)
+	assert.Equal(t, map[string]any{"intValue": "2"}, attribute["value"])
+}
+
+type discard struct{}
+
+func (discard) Export(tracing.SpanData) {}
+func (discard) Close() error            { return nil }
@@ -0,0 +1,103 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"github.com/Tagakama/ServerManager/internal/journal"
+)
+
+// После стольких записей файл переписывается с одними неотправленными событиями.
+const compactEvery = 1000
+
+// entry - строка outbox: последнее состояние доставки или ID законченной доставки в Done.
+type entry struct {
+	Delivery *delivery `json:"delivery,omitempty"`
+	Done     string    `json:"done,omitempty"`
+}
+
+// outbox - неотправленные события в файле, по записи на строку. Пустой путь - только память.
+type outbox struct {
+	journal *journal.Journal
+	pending map[string]*delivery
+	written int
+}
+
+func openOutbox(path string) (*outbox, []*delivery, error) {
+	out := &outbox{pending: make(map[string]*delivery)}
+	if path == "" {
+		return out, nil, nil
+	}
+	file, err := journal.Open(path, out.apply)
+	if err != nil {
+		return nil, nil, err
+	}
+	out.journal = file
+	if err := out.compact(); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	restored := make([]*delivery, 0, len(out.pending))
+	for _, d := range out.pending {
+		restored = append(restored, d)
+	}
+	return out, restored, nil
+}
+
+func (o *outbox) apply(line []byte) error {
+	var record entry
+	if err := json.Unmarshal(line, &record); err != nil {
+		return err
+	}
+	switch {
+	case record.Done != "":
+		delete(o.pending, record.Done)
+	case record.Delivery != nil:
+		o.pending[record.Delivery.ID] = record.Delivery
+	}
+	return nil
+}
+
+func (o *outbox) save(d *delivery) error {
+	copied := *d
+	o.pending[d.ID] = &copied
+	return o.write(entry{Delivery: d})
+}
+
+func (o *outbox) done(id string) error {
+	delete(o.pending, id)
+	return o.write(entry{Done: id})
+}
+
+func (o *outbox) write(record entry) error {
+	if o.journal == nil {
+		return nil
+	}
+	if err := o.journal.Append(record); err != nil {
+		return err
+	}
+	o.written++
+	if o.written >= compactEvery {
+		return o.compact()
+	}
+	return nil
+}
+
+// compact оставляет в файле одни неотправленные события.
+func (o *outbox) compact() error {
+	records := make([]entry, 0, len(o.pending))
+	for _, d := range o.pending {
+		records = append(records, entry{Delivery: d})
+	}
+	if err := journal.Rewrite(o.journal, records); err != nil {
+		return err
+	}
+	o.written = 0
+	return nil
+}
+
+func (o *outbox) close() error {
+	if o.journal == nil {
+		return nil
+	}
+	return o.journal.Close()
+}
@@ -0,0 +1,473 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Tagakama/ServerManager/internal/bus"
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/events"
+	"github.com/Tagakama/ServerManager/internal/matchmaking/room"
+	"github.com/Tagakama/ServerManager/internal/metrics"
+	"github.com/Tagakama/ServerManager/internal/tcp-server/middleware/logger"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Типы событий.
+const (
+	RoomCreated   = "room.created"
+	RoomFilled    = "room.filled"
+	ServerReady   = "server.ready"
+	LaunchFailed  = "server.launch_failed"
+	MatchEnded    = "match.ended"
+	ServerCrashed = "server.crashed"
+)
+
+// Заголовки запроса. Подпись: "<unix>.<hex>", hex = HMAC-SHA256(secret, body + ":" + unix).
+const (
+	HeaderEvent     = "X-SM-Event"
+	HeaderDelivery  = "X-SM-Delivery"
+	HeaderSignature = "X-SM-Signature"
+)
+
+// Исходы попытки для sm_webhook_deliveries_total.
+const (
+	OutcomeDelivered = "delivered"
+	OutcomeRetried   = "retried"
+	OutcomeDropped   = "dropped"
+)
+
+var log = logger.For("webhooks")
+
+var (
+	deliveries = metrics.Default.Counter("sm_webhook_deliveries_total", "Webhook delivery attempts, per event and outcome.", "event", "outcome")
+	outboxSize = metrics.Default.Gauge("sm_webhook_outbox", "Webhook deliveries waiting to be sent.")
+)
+
+type Player struct {
+	ClientID string `json:"client_id"`
+	Team     int    `json:"team"`
+	Size     int    `json:"size"`
+}
+
+// Event - тело запроса. ID общий для всех получателей события.
+type Event struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Time     time.Time       `json:"time"`
+	RoomUUID string          `json:"room_uuid"`
+	RoomID   int             `json:"room_id"`
+	Mode     string          `json:"mode"`
+	Map      string          `json:"map"`
+	Version  string          `json:"version"`
+	Players  []Player        `json:"players,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Results  json.RawMessage `json:"results,omitempty"`
+}
+
+// RoomEvent - событие комнаты; err - причина для launch_failed и crashed. Игроки
+// перечисляются только после закрытия набора: до него состав ещё меняется.
+func RoomEvent(kind string, target *room.Room, err error) Event {
+	event := Event{
+		Type:     kind,
+		RoomUUID: target.UUID,
+		RoomID:   target.ID,
+		Mode:     target.Mode,
+		Map:      target.CurrentMap,
+		Version:  target.AppVersion,
+	}
+	if kind == RoomFilled || kind == MatchEnded || kind == ServerCrashed {
+		for _, player := range target.PlayersSnapshot() {
+			event.Players = append(event.Players, Player{
+				ClientID: player.ConnectedMessage.ClientID,
+				Team:     player.Team,
+				Size:     player.ConnectedMessage.NumberOfPlayers,
+			})
+		}
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if kind == MatchEnded {
+		event.Results = target.Results()
+	}
+	return event
+}
+
+// delivery - событие для одного получателя.
+type delivery struct {
+	ID       string    `json:"id"`
+	Endpoint string    `json:"endpoint"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	NextAt   time.Time `json:"next_at"`
+}
+
+// Notifier рассылает события получателям из конфига. Notify только кладёт событие
+// в outbox, отправляет Run.
+type Notifier struct {
+	endpoints   []config.WebhookEndpoint
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+
+	mu      sync.Mutex
+	outbox  *outbox
+	pending map[string]*delivery
+	sending map[string]bool
+	// wake - по каналу на получателя: у каждого свой цикл доставки
+	wake map[string]chan struct{}
+}
+
+// New поднимает неотправленные события из outbox. Без получателей возвращает
+// Notifier, который ничего не делает.
+func New(cfg *config.Config) (*Notifier, error) {
+	settings := cfg.Webhooks
+	n := &Notifier{
+		endpoints:   settings.Endpoints,
+		client:      &http.Client{Timeout: seconds(settings.Timeout, 5)},
+		maxAttempts: settings.MaxAttempts,
+		backoff:     seconds(settings.Backoff, 1),
+		maxBackoff:  seconds(settings.MaxBackoff, 600),
+		pending:     make(map[string]*delivery),
+		sending:     make(map[string]bool),
+		wake:        make(map[string]chan struct{}),
+	}
+	if n.maxAttempts <= 0 {
+		n.maxAttempts = 12
+	}
+	for _, endpoint := range n.endpoints {
+		if endpoint.URL == "" {
+			return nil, errors.New("webhook endpoint url is required")
+		}
+		if endpoint.Secret == "" {
+			return nil, fmt.Errorf("webhook endpoint %s: secret is required", endpoint.URL)
+		}
+		n.wake[endpoint.URL] = make(chan struct{}, 1)
+	}
+	if len(n.endpoints) == 0 {
+		return n, nil
+	}
+
+	out, restored, err := openOutbox(settings.OutboxPath)
+	if err != nil {
+		return nil, err
+	}
+	n.outbox = out
+	for _, d := range restored {
+		// Получателя убрали из конфига - доставлять некуда
+		if _, ok := n.endpoint(d.Endpoint); !ok {
+			n.outbox.done(d.ID)
+			continue
+		}
+		n.pending[d.ID] = d
+	}
+	outboxSize.Set(float64(len(n.pending)))
+	if len(n.pending) > 0 {
+		log.Info("webhook outbox restored", "pending", len(n.pending))
+	}
+	return n, nil
+}
+
+func seconds(value, fallback int) time.Duration {
+	if value <= 0 {
+		value = fallback
+	}
+	return time.Duration(value) * time.Second
+}
+
+func (n *Notifier) endpoint(url string) (config.WebhookEndpoint, bool) {
+	for _, endpoint := range n.endpoints {
+		if endpoint.URL == url {
+			return endpoint, true
+		}
+	}
+	return config.WebhookEndpoint{}, false
+}
+
+// Subscribe переводит события матчмейкера в вебхуки. Безопасен для nil.
+func (n *Notifier) Subscribe(b *bus.Bus) {
+	if n == nil || len(n.endpoints) == 0 {
+		return
+	}
+	bus.Subscribe(b, "webhooks", func(e events.RoomCreated) {
+		n.Notify(RoomEvent(RoomCreated, e.Room, nil))
+	})
+	bus.Subscribe(b, "webhooks", func(e room.StateChanged) {
+		if e.State == room.StateReady {
+			n.Notify(RoomEvent(ServerReady, e.Room, nil))
+		}
+	})
+	bus.Subscribe(b, "webhooks", func(e events.LaunchFailed) {
+		n.Notify(RoomEvent(LaunchFailed, e.Room, e.Err))
+	})
+	bus.Subscribe(b, "webhooks", func(e room.Filled) {
+		n.Notify(RoomEvent(RoomFilled, e.Room, nil))
+	})
+	bus.Subscribe(b, "webhooks", func(e room.Ended) {
+		n.notifyEnded(e.Room)
+	})
+}
+
+// notifyEnded: сервер, так и не ставший готовым, уже учтён как launch_failed.
+func (n *Notifier) notifyEnded(ended *room.Room) {
+	select {
+	case <-ended.ReadyC():
+	default:
+		return
+	}
+	if err := ended.ExitErr(); err != nil && !ended.Reported() {
+		n.Notify(RoomEvent(ServerCrashed, ended, err))
+		return
+	}
+	n.Notify(RoomEvent(MatchEnded, ended, nil))
+}
+
+// Notify ставит событие в очередь каждому подписанному получателю. Безопасен для nil.
+func (n *Notifier) Notify(event Event) {
+	if n == nil || len(n.endpoints) == 0 {
+		return
+	}
+	if event.ID == "" {
+		event.ID = newID()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	var woken []chan struct{}
+	n.mu.Lock()
+	for i, endpoint := range n.endpoints {
+		if len(endpoint.Events) > 0 && !slices.Contains(endpoint.Events, event.Type) {
+			continue
+		}
+		woken = append(woken, n.wake[endpoint.URL])
+		d := &delivery{
+			ID:       event.ID + "-" + strconv.Itoa(i),
+			Endpoint: endpoint.URL,
+			Event:    event,
+			NextAt:   event.Time,
+		}
+		if err := n.outbox.save(d); err != nil {
+			log.Error("failed to save webhook to outbox", "event", event.Type, logger.Err(err))
+		}
+		n.pending[d.ID] = d
+	}
+	outboxSize.Set(float64(len(n.pending)))
+	n.mu.Unlock()
+
+	for _, wake := range woken {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run отправляет события, пока не закрыт stop. События одного получателя уходят по порядку
+// создания, но повтор не задерживает следующие события. Получатели обслуживаются
+// независимо: недоступный не задерживает доставку остальным.
+func (n *Notifier) Run(stop <-chan struct{}) {
+	if n == nil || len(n.endpoints) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for url, wake := range n.wake {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.run(url, wake, stop)
+		}()
+	}
+	wg.Wait()
+}
+
+// run - цикл доставки одному получателю.
+func (n *Notifier) run(url string, wake <-chan struct{}, stop <-chan struct{}) {
+	for {
+		due, wait := n.due(url, time.Now())
+		for _, d := range due {
+			n.deliver(d)
+		}
+		if len(due) > 0 {
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-wake:
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// due - доставки получателю url, которым пора уйти, и сколько ждать до следующей.
+func (n *Notifier) due(url string, now time.Time) ([]*delivery, time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	wait := time.Hour
+	var due []*delivery
+	for _, d := range n.pending {
+		if d.Endpoint != url || n.sending[d.ID] {
+			continue
+		}
+		if until := d.NextAt.Sub(now); until > 0 {
+			wait = min(wait, until)
+			continue
+		}
+		n.sending[d.ID] = true
+		due = append(due, d)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].Event.Time.Equal(due[j].Event.Time) {
+			return due[i].Event.Time.Before(due[j].Event.Time)
+		}
+		return due[i].ID < due[j].ID
+	})
+	return due, wait
+}
+
+func (n *Notifier) deliver(d *delivery) {
+	permanent, err := n.send(d)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.sending, d.ID)
+	d.Attempts++
+	deliveryLog := log.With("event", d.Event.Type, "delivery", d.ID, "endpoint", d.Endpoint, "attempts", d.Attempts)
+
+	switch {
+	case err == nil:
+		deliveries.Inc(d.Event.Type, OutcomeDelivered)
+		deliveryLog.Debug("webhook delivered")
+		n.finishLocked(d)
+	case permanent || d.Attempts >= n.maxAttempts:
+		deliveries.Inc(d.Event.Type, OutcomeDropped)
+		deliveryLog.Error("webhook dropped", logger.Err(err))
+		n.finishLocked(d)
+	default:
+		deliveries.Inc(d.Event.Type, OutcomeRetried)
+		d.NextAt = time.Now().Add(n.delay(d.Attempts))
+		deliveryLog.Warn("webhook delivery failed, will retry", "next_at", d.NextAt, logger.Err(err))
+		if err := n.outbox.save(d); err != nil {
+			deliveryLog.Error("failed to save webhook to outbox", logger.Err(err))
+		}
+	}
+}
+
+func (n *Notifier) finishLocked(d *delivery) {
+	delete(n.pending, d.ID)
+	outboxSize.Set(float64(len(n.pending)))
+	if err := n.outbox.done(d.ID); err != nil {
+		log.Error("failed to update webhook outbox", "delivery", d.ID, logger.Err(err))
+	}
+}
+
+// delay - пауза перед повтором: backoff, 2*backoff, 4*backoff... не больше maxBackoff.
+func (n *Notifier) delay(attempts int) time.Duration {
+	delay := n.backoff
+	for i := 1; i < attempts && delay < n.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, n.maxBackoff)
+}
+
+// send возвращает permanent, если повтор не поможет: получатель отверг событие.
+func (n *Notifier) send(d *delivery) (bool, error) {
+	endpoint, ok := n.endpoint(d.Endpoint)
+	if !ok {
+		return true, errors.New("endpoint is no longer configured")
+	}
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return true, err
+	}
+	request, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, d.Event.Type)
+	request.Header.Set(HeaderDelivery, d.ID)
+	request.Header.Set(HeaderSignature, Sign(endpoint.Secret, body, time.Now()))
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return false, nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return false, fmt.Errorf("endpoint returned %d", response.StatusCode)
+	default:
+		return true, fmt.Errorf("endpoint rejected event with %d", response.StatusCode)
+	}
+}
+
+func (n *Notifier) Close() error {
+	if n == nil || n.outbox == nil {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.outbox.close()
+}
+
+// Sign - значение X-SM-Signature для тела body.
+func Sign(secret string, body []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return timestamp + "." + hex.EncodeToString(mac(secret, body, timestamp))
+}
+
+// Verify проверяет X-SM-Signature на стороне получателя; maxSkew ограничивает повтор запроса.
+func Verify(secret string, body []byte, signature string, maxSkew time.Duration) error {
+	timestamp, signed, ok := strings.Cut(signature, ".")
+	if !ok {
+		return errors.New("malformed signature")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return errors.New("signature expired")
+	}
+	provided, err := hex.DecodeString(signed)
+	if err != nil || !hmac.Equal(provided, mac(secret, body, timestamp)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func mac(secret string, body []byte, timestamp string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	h.Write([]byte(":" + timestamp))
+	return h.Sum(nil)
+}
+
+func newID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,199 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tagakama/ServerManager/internal/config"
+	"github.com/Tagakama/ServerManager/internal/webhooks"
+)
+
+const secret = "webhook-secret"
+
+// receiver - бэкенд, принимающий вебхуки; первые failures запросов получают 503.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	events   []webhooks.Event
+	errors   []error
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+		return
+	}
+	if err := webhooks.Verify(secret, body, req.Header.Get(webhooks.HeaderSignature), time.Minute); err != nil {
+		r.errors = append(r.errors, err)
+	}
+	var event webhooks.Event
+	json.Unmarshal(body, &event)
+	if req.Header.Get(webhooks.HeaderEvent) != event.Type {
+		r.errors = append(r.errors, assert.AnError)
+	}
+	r.events = append(r.events, event)
+}
+
+func (r *receiver) received() []webhooks.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhooks.Event(nil), r.events...)
+}
+
+func newNotifier(t *testing.T, outbox string, endpoints ...config.WebhookEndpoint) *webhooks.Notifier {
+	n, err := webhooks.New(&config.Config{Webhooks: config.Webhooks{
+		Endpoints:   endpoints,
+		OutboxPath:  outbox,
+		Timeout:     1,
+		MaxAttempts: 3,
+		Backoff:     1,
+		MaxBackoff:  1,
+	}})
+	require.NoError(t, err)
+	return n
+}
+
+func run(t *testing.T, n *webhooks.Notifier) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		n.Run(stop)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+		n.Close()
+	})
+}
+
+func TestNotifier_SignedDeliveryWithRetry(t *testing.T) {
+	backend := &receiver{failures: 1}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	filtered := &receiver{}
+	filteredServer := httptest.NewServer(filtered)
+	defer filteredServer.Close()
+
+	n := newNotifier(t, filepath.Join(t.TempDir(), "outbox.jsonl"),
+		config.WebhookEndpoint{URL: server.URL, Secret: secret},
+		config.WebhookEndpoint{URL: filteredServer.URL, Secret: secret, Events: []string{webhooks.MatchEnded}},
+	)
+	run(t, n)
+
+	n.Notify(webhooks.Event{Type: webhooks.RoomFilled, RoomUUID: "room-1", Players: []webhooks.Player{{ClientID: "alice", Team: 1, Size: 1}}})
+	n.Notify(webhooks.Event{Type: webhooks.MatchEnded, RoomUUID: "room-1", Results: json.RawMessage(`{"winner":1}`)})
+
+	require.Eventually(t, func() bool { return len(backend.received()) == 2 }, 5*time.Second, 20*time.Millisecond)
+	require.Eventually(t, func() bool { return len(filtered.received()) == 1 }, 5*time.Second, 20*time.Millisecond)
+	assert.Empty(t, backend.errors)
+	assert.Empty(t, filtered.errors)
+
+	events := backend.received()
+	types := []string{events[0].Type, events[1].Type}
+	assert.ElementsMatch(t, []string{webhooks.RoomFilled, webhooks.MatchEnded}, types, "failed delivery is retried")
+	assert.Equal(t, webhooks.MatchEnded, filtered.received()[0].Type)
+	assert.JSONEq(t, `{"winner":1}`, string(filtered.received()[0].Results))
+	assert.NotEmpty(t, events[0].ID)
+}
+
+func TestNotifier_OutboxSurvivesRestart(t *testing.T) {
+	outbox := filepath.Join(t.TempDir(), "state", "webhooks.jsonl")
+	backend := &receiver{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	endpoint := config.WebhookEndpoint{URL: server.URL, Secret: secret}
+
+	// Менеджер упал, не успев отправить событие
+	stopped := newNotifier(t, outbox, endpoint)
+	stopped.Notify(webhooks.Event{Type: webhooks.ServerCrashed, RoomUUID: "room-2", Error: "exit status 139"})
+	require.NoError(t, stopped.Close())
+
+	n := newNotifier(t, outbox, endpoint)
+	run(t, n)
+	require.Eventually(t, func() bool { return len(backend.received()) == 1 }, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "exit status 139", backend.received()[0].Error)
+	assert.Empty(t, backend.errors)
+}
+
+func TestNotifier_RejectedEventIsDropped(t *testing.T) {
+	outbox := filepath.Join(t.TempDir(), "webhooks.jsonl")
+	backend := &receiver{status: http.StatusBadRequest}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+	endpoint := config.WebhookEndpoint{URL: server.URL, Secret: secret}
+
+	n := newNotifier(t, outbox, endpoint)
+	n.Notify(webhooks.Event{Type: webhooks.RoomCreated, RoomUUID: "room-3"})
+	stop := make(chan struct{})
+	go n.Run(stop)
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	require.NoError(t, n.Close())
+
+	// После отказа получателя событие не возвращается в outbox
+	backend.mu.Lock()
+	backend.status = 0
+	backend.mu.Unlock()
+	n = newNotifier(t, outbox, endpoint)
+	run(t, n)
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, backend.received())
+}
+
+func TestNotifier_SlowEndpointDoesNotDelayOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	backend := &receiver{}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	n := newNotifier(t, "",
+		config.WebhookEndpoint{URL: slow.URL, Secret: secret},
+		config.WebhookEndpoint{URL: server.URL, Secret: secret},
+	)
+	run(t, n)
+	for _, room := range []string{"room-1", "room-2", "room-3"} {
+		n.Notify(webhooks.Event{Type: webhooks.RoomCreated, RoomUUID: room})
+	}
+
+	// Таймаут доставки - секунда: последовательная рассылка не уложилась бы
+	require.Eventually(t, func() bool { return len(backend.received()) == 3 }, 500*time.Millisecond, 10*time.Millisecond)
+	assert.Empty(t, backend.errors)
+}
+
+func TestNew_RequiresSecret(t *testing.T) {
+	_, err := webhooks.New(&config.Config{Webhooks: config.Webhooks{Endpoints: []config.WebhookEndpoint{{URL: "http://backend.example.com/hooks"}}}})
+	assert.ErrorContains(t, err, "secret is required")
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"room.created"}`)
+	signature := webhooks.Sign(secret, body, time.Now())
+	assert.NoError(t, webhooks.Verify(secret, body, signature, time.Minute))
+	assert.Error(t, webhooks.Verify("other", body, signature, time.Minute))
+	assert.Error(t, webhooks.Verify(secret, []byte(`{}`), signature, time.Minute))
+	assert.Error(t, webhooks.Verify(secret, body, webhooks.Sign(secret, body, time.Now().Add(-time.Hour)), time.Minute))
+}